@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli/v2"
+
+	"guardian/internal/guardian/infrastructure/apikey"
+)
+
+func apikeyCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "apikey",
+		Usage: "API key tools",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "generate",
+				Usage:  "Prints new API key and its hash for keys file",
+				Action: executeAPIKeyGenerate,
+			},
+		},
+	}
+}
+
+func executeAPIKeyGenerate(*cli.Context) error {
+	key, hash, err := apikey.Generate()
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(os.Stdout, "key:  %s\nhash: %s\n", key, hash)
+	return nil
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/UsingCoding/fpgo/pkg/maybe"
+	"github.com/UsingCoding/fpgo/pkg/slices"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+
+	"guardian/internal/guardian/app/config"
+	"guardian/internal/guardian/app/proxy/downstream"
+	"guardian/internal/guardian/app/proxy/upstream"
+	"guardian/internal/guardian/app/session"
+	"guardian/internal/guardian/app/user"
+	infraconfig "guardian/internal/guardian/infrastructure/config"
+)
+
+func configCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "config",
+		Usage: "Config tools",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "validate",
+				Usage:  "Validates config and prints diagnostics",
+				Action: executeConfigValidate,
+				Flags:  configFlags(),
+			},
+			{
+				Name:   "render",
+				Usage:  "Prints resolved config as JSON",
+				Action: executeConfigRender,
+				Flags:  configFlags(),
+			},
+			{
+				Name:   "schema",
+				Usage:  "Prints JSON Schema of config in JSON syntax",
+				Action: executeConfigSchema,
+			},
+		},
+	}
+}
+
+func executeConfigValidate(ctx *cli.Context) error {
+	parser, err := configParser(ctx)
+	if err != nil {
+		return err
+	}
+
+	c, err := loadConfig(parser, ctx.String("config"))
+	if err != nil {
+		diags, ok := infraconfig.Diagnostics(err)
+		if !ok {
+			return err
+		}
+
+		writeDiagnostics(diags)
+		return errors.New("config is invalid")
+	}
+
+	if diags := infraconfig.Validate(c); len(diags) != 0 {
+		// only warnings left since config loaded
+		writeDiagnostics(diags)
+	}
+
+	_, _ = fmt.Fprintln(os.Stdout, "config is valid")
+	return nil
+}
+
+func executeConfigRender(ctx *cli.Context) error {
+	parser, err := configParser(ctx)
+	if err != nil {
+		return err
+	}
+
+	c, err := loadConfig(parser, ctx.String("config"))
+	if err != nil {
+		if diags, ok := infraconfig.Diagnostics(err); ok {
+			writeDiagnostics(diags)
+			return errors.New("config is invalid")
+		}
+		return err
+	}
+
+	return writeJSON(renderConfig(c))
+}
+
+func executeConfigSchema(*cli.Context) error {
+	return writeJSON(infraconfig.Schema())
+}
+
+func writeJSON(v any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// writeDiagnostics prints diagnostics with snippets of config files they refer to
+func writeDiagnostics(diags hcl.Diagnostics) {
+	files := map[string]*hcl.File{}
+	for _, diag := range diags {
+		if diag.Subject == nil {
+			continue
+		}
+
+		filename := diag.Subject.Filename
+		if _, ok := files[filename]; ok {
+			continue
+		}
+
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			// snippet is omitted for unreadable sources like stdin
+			continue
+		}
+		files[filename] = &hcl.File{Bytes: data}
+	}
+
+	w := hcl.NewDiagnosticTextWriter(os.Stderr, files, 0, false)
+	_ = w.WriteDiagnostics(diags)
+}
+
+type renderedConfig struct {
+	Healthcheck   renderedHealthcheck      `json:"healthcheck"`
+	Admin         *renderedAdmin           `json:"admin,omitempty"`
+	UserProviders map[string]user.Provider `json:"userProviders,omitempty"`
+	SessionStores map[string]session.Store `json:"sessionStores,omitempty"`
+	TCPProxies    []renderedTCPProxy       `json:"tcpProxies"`
+	HTTPProxies   []renderedHTTPProxy      `json:"httpProxies"`
+}
+
+// renderedAdmin omits token
+type renderedAdmin struct {
+	Address string `json:"address"`
+}
+
+type renderedHealthcheck struct {
+	Address string `json:"address"`
+	Path    string `json:"path"`
+}
+
+type renderedTCPProxy struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+}
+
+type renderedHTTPProxy struct {
+	Address    string               `json:"address"`
+	TLS        bool                 `json:"tls,omitempty"`
+	Limit      renderedLimit        `json:"limit"`
+	Downstream []renderedDownstream `json:"downstream"`
+	Upstream   []renderedUpstream   `json:"upstream"`
+}
+
+type renderedLimit struct {
+	RPS   int `json:"rps"`
+	Burst int `json:"burst"`
+}
+
+type renderedDownstream struct {
+	ID                string                      `json:"id"`
+	Rules             []downstream.Rule           `json:"rules"`
+	UpstreamID        string                      `json:"upstream"`
+	Authorizer        downstream.Authorizer       `json:"authorizer,omitempty"`
+	AuthOptional      bool                        `json:"authOptional,omitempty"`
+	OnUnauthenticated *downstream.Unauthenticated `json:"onUnauthenticated,omitempty"`
+	Policy            *downstream.Policy          `json:"policy,omitempty"`
+	SecondFactor      downstream.SecondFactor     `json:"mfa,omitempty"`
+}
+
+type renderedUpstream struct {
+	ID         string              `json:"id"`
+	Address    string              `json:"address"`
+	Authorizer upstream.Authorizer `json:"authorizer,omitempty"`
+}
+
+func renderConfig(c config.AppConfig) renderedConfig {
+	return renderedConfig{
+		Healthcheck: renderedHealthcheck{
+			Address: c.Healthcheck.Address,
+			Path:    c.Healthcheck.Path,
+		},
+		Admin:         renderAdmin(c.Admin),
+		UserProviders: c.UserProviders,
+		SessionStores: c.SessionStores,
+		TCPProxies: slices.Map(c.TCPProxies, func(p config.TCPProxy) renderedTCPProxy {
+			return renderedTCPProxy{
+				Source:      p.SrcAddress,
+				Destination: p.DstAddress,
+			}
+		}),
+		HTTPProxies: slices.Map(c.HTTPProxies, func(p config.HTTPProxy) renderedHTTPProxy {
+			return renderedHTTPProxy{
+				Address: p.Address,
+				TLS:     p.TLS != nil,
+				Limit: renderedLimit{
+					RPS:   p.Limit.RPS,
+					Burst: p.Limit.Burst,
+				},
+				Downstream: slices.Map(p.Downstream, func(d downstream.Downstream) renderedDownstream {
+					return renderedDownstream{
+						ID:                d.ID,
+						Rules:             d.Rules,
+						UpstreamID:        d.UpstreamID,
+						Authorizer:        maybe.Just(d.Authorizer),
+						AuthOptional:      d.AuthOptional,
+						OnUnauthenticated: onUnauthenticated(d.OnUnauthenticated),
+						Policy:            policy(d.Policy),
+						SecondFactor:      maybe.Just(d.SecondFactor),
+					}
+				}),
+				Upstream: slices.Map(p.Upstream, func(u upstream.Upstream) renderedUpstream {
+					return renderedUpstream{
+						ID:         u.ID,
+						Address:    u.Address.String(),
+						Authorizer: maybe.Just(u.Authorizer),
+					}
+				}),
+			}
+		}),
+	}
+}
+
+func renderAdmin(a maybe.Maybe[config.Admin]) *renderedAdmin {
+	if a, ok := maybe.JustValid(a); ok {
+		return &renderedAdmin{Address: a.Address}
+	}
+	return nil
+}
+
+func onUnauthenticated(u maybe.Maybe[downstream.Unauthenticated]) *downstream.Unauthenticated {
+	if unauthenticated, ok := maybe.JustValid(u); ok {
+		return &unauthenticated
+	}
+	return nil
+}
+
+func policy(p maybe.Maybe[downstream.Policy]) *downstream.Policy {
+	if policy, ok := maybe.JustValid(p); ok {
+		return &policy
+	}
+	return nil
+}
@@ -28,6 +28,8 @@ func main() {
 	err := runApp(ctx, os.Args)
 
 	switch errors.Cause(err) {
+	case nil:
+		return
 	case proc.ErrStopped:
 		stdlog.Println(err.Error())
 		return
@@ -44,6 +46,10 @@ func runApp(ctx context.Context, args []string) error {
 		Name: appID,
 		Commands: []*cli.Command{
 			proxy(),
+			configCmd(),
+			sessionCmd(),
+			apikeyCmd(),
+			totpCmd(),
 		},
 	}
 
@@ -83,12 +89,7 @@ func httpServer(
 
 	hub.AddProc(proc.NewProc(
 		func() error {
-			server = &http.Server{
-				Handler:      handler,
-				Addr:         serveAddr,
-				WriteTimeout: 15 * time.Second,
-				ReadTimeout:  15 * time.Second,
-			}
+			server = newHTTPServer(serveAddr, handler)
 
 			return server.ListenAndServe()
 		},
@@ -100,3 +101,12 @@ func httpServer(
 		},
 	))
 }
+
+func newHTTPServer(serveAddr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Handler:      handler,
+		Addr:         serveAddr,
+		WriteTimeout: 15 * time.Second,
+		ReadTimeout:  15 * time.Second,
+	}
+}
@@ -4,16 +4,18 @@ import (
 	"io"
 	"os"
 
+	"github.com/UsingCoding/fpgo/pkg/maybe"
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 	"github.com/urfave/cli/v2"
 
+	"guardian/internal/common/infrastructure/filewatch"
+	"guardian/internal/common/infrastructure/logger"
 	commonserver "guardian/internal/common/infrastructure/server"
 	"guardian/internal/common/proc"
 	"guardian/internal/guardian/app/config"
+	"guardian/internal/guardian/infrastructure/admin"
 	infraconfig "guardian/internal/guardian/infrastructure/config"
-	infraproxy "guardian/internal/guardian/infrastructure/httpproxy"
-	"guardian/internal/guardian/infrastructure/tcpproxy"
 )
 
 func proxy() *cli.Command {
@@ -21,26 +23,43 @@ func proxy() *cli.Command {
 		Name:   "proxy",
 		Usage:  "Runs proxy",
 		Action: executeProxy,
-		Flags: []cli.Flag{
-			&cli.StringFlag{
-				Name:    "config",
-				Aliases: []string{"c"},
-				Usage:   "Path to config file",
-				EnvVars: []string{"GUARDIAN_CONFIG"},
-			},
+		Flags:  configFlags(),
+	}
+}
+
+func configFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "config",
+			Aliases: []string{"c"},
+			Usage:   "Path to config file, directory with config files or glob",
+			EnvVars: []string{"GUARDIAN_CONFIG"},
+		},
+		&cli.StringFlag{
+			Name:    "config-format",
+			Usage:   "Syntax of config files: hcl or json, by default chosen by file extension",
+			EnvVars: []string{"GUARDIAN_CONFIG_FORMAT"},
 		},
 	}
 }
 
 func executeProxy(ctx *cli.Context) error {
 	l := initLogger()
+	filewatch.SetLogger(l)
 
 	configPath := ctx.String("config")
+	parser, err := configParser(ctx)
+	if err != nil {
+		return err
+	}
+	parser.SessionStores = infraconfig.NewSessionStores()
+	parser.TOTPAttempts = infraconfig.NewTOTPAttempts()
 
-	c, err := loadConfig(configPath)
+	c, err := loadConfig(parser, configPath)
 	if err != nil {
 		return err
 	}
+	logConfigWarnings(c, l)
 
 	hub := proc.NewHub(ctx.Context)
 
@@ -52,39 +71,55 @@ func executeProxy(ctx *cli.Context) error {
 		router,
 	)
 
-	for _, server := range c.HTTPProxies {
-		p := infraproxy.NewProxy(
-			server.Downstream,
-			server.Upstream,
-			server.Limit,
-			l,
-		)
+	rt := newProxyRuntime(l)
+	err = rt.Apply(c)
+	if err != nil {
+		return err
+	}
+	hub.AddProc(rt)
 
+	// admin listener started once, token and session stores follow reloaded config, moving it requires restart
+	if a, ok := maybe.JustValid(c.Admin); ok {
+		adminRouter := mux.NewRouter()
+		admin.RegisterHandlers(adminRouter, rt, l)
 		httpServer(
 			hub,
-			server.Address,
-			p.Proxy(),
+			a.Address,
+			adminRouter,
 		)
 	}
 
-	if len(c.TCPProxies) != 0 {
-		p := tcpproxy.Proxy{}
-		hub.AddProc(proc.NewProc(
-			func() error {
-				return p.Proxy(ctx.Context, c.TCPProxies)
-			},
-			func() error {
-				return nil
-			},
-		))
-	}
+	hub.AddProc(newReloader(
+		func() (config.AppConfig, error) {
+			if configPath == "" {
+				return config.AppConfig{}, errors.New("config read from stdin can not be reloaded")
+			}
+
+			newConfig, err2 := loadConfig(parser, configPath)
+			if err2 != nil {
+				return config.AppConfig{}, err2
+			}
+			logConfigWarnings(newConfig, l)
+			return newConfig, nil
+		},
+		c.Sources,
+		rt,
+		l,
+	))
 
 	return hub.Wait()
 }
 
-func loadConfig(p string) (config.AppConfig, error) {
+func configParser(ctx *cli.Context) (infraconfig.Parser, error) {
+	format, err := infraconfig.ParseFormat(ctx.String("config-format"))
+	return infraconfig.Parser{
+		Format: format,
+	}, err
+}
+
+func loadConfig(parser infraconfig.Parser, p string) (config.AppConfig, error) {
 	if p != "" {
-		return infraconfig.Parser{}.Parse(p)
+		return parser.Parse(p)
 	}
 
 	data, err := io.ReadAll(os.Stdin)
@@ -96,6 +131,14 @@ func loadConfig(p string) (config.AppConfig, error) {
 		return config.AppConfig{}, errors.New("empty stdin")
 	}
 
-	c, err := infraconfig.Parser{}.ParseData("stdin.hcl", data)
+	c, err := parser.ParseData("stdin", data)
 	return c, err
 }
+
+func logConfigWarnings(c config.AppConfig, l logger.Logger) {
+	for _, diag := range infraconfig.Validate(c) {
+		l.WithFields(logger.Fields{
+			"subject": diag.Subject.String(),
+		}).Warnf("config warning: %s", diag.Summary)
+	}
+}
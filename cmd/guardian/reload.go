@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"guardian/internal/common/infrastructure/filewatch"
+	"guardian/internal/common/infrastructure/logger"
+	"guardian/internal/guardian/app/config"
+)
+
+func newReloader(
+	load func() (config.AppConfig, error),
+	files []string,
+	rt *proxyRuntime,
+	l logger.Logger,
+) *reloader {
+	return &reloader{
+		load:    load,
+		files:   files,
+		rt:      rt,
+		logger:  l,
+		stopped: make(chan struct{}),
+	}
+}
+
+// reloader re-reads config on SIGHUP or when config files changed and applies it to runtime.
+// Invalid config is rejected and logged, runtime keeps serving previous one
+type reloader struct {
+	load   func() (config.AppConfig, error)
+	files  []string
+	rt     *proxyRuntime
+	logger logger.Logger
+
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+func (r *reloader) Start() error {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	changed := make(chan struct{}, 1)
+	stopWatch := r.watch(r.files, changed)
+	defer func() {
+		stopWatch()
+	}()
+
+	for {
+		var reason string
+		select {
+		case <-r.stopped:
+			return nil
+		case <-hup:
+			reason = "signal"
+		case <-changed:
+			reason = "config changed"
+		}
+
+		files, ok := r.reload(reason)
+		if ok {
+			// set of config files may change after reload
+			stopWatch()
+			stopWatch = r.watch(files, changed)
+		}
+	}
+}
+
+func (r *reloader) watch(files []string, changed chan<- struct{}) context.CancelFunc {
+	ctx, cancel := context.WithCancel(context.Background())
+	if len(files) == 0 {
+		return cancel
+	}
+
+	go filewatch.Watch(ctx, filewatch.DefaultInterval, files, func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+
+	return cancel
+}
+
+func (r *reloader) Stop() error {
+	r.stopOnce.Do(func() {
+		close(r.stopped)
+	})
+	return nil
+}
+
+// reload applies new config and returns sources it loaded from
+func (r *reloader) reload(reason string) ([]string, bool) {
+	l := r.logger.WithFields(logger.Fields{
+		"reason": reason,
+	})
+
+	c, err := r.load()
+	if err != nil {
+		l.Error(err, "config rejected")
+		return nil, false
+	}
+
+	err = r.rt.Apply(c)
+	if err != nil {
+		l.Error(err, "config rejected")
+		return nil, false
+	}
+
+	l.Info("config reloaded")
+	return c.Sources, true
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/UsingCoding/fpgo/pkg/maybe"
+	"github.com/gofrs/uuid/v5"
+	"github.com/pkg/errors"
+
+	"guardian/internal/common/infrastructure/logger"
+	"guardian/internal/guardian/app/config"
+	"guardian/internal/guardian/app/session"
+	"guardian/internal/guardian/app/user"
+	infraproxy "guardian/internal/guardian/infrastructure/httpproxy"
+	"guardian/internal/guardian/infrastructure/tcpproxy"
+)
+
+const shutdownTimeout = 15 * time.Second
+
+func newProxyRuntime(l logger.Logger) *proxyRuntime {
+	return &proxyRuntime{
+		logger:  l,
+		servers: map[string]*proxyServer{},
+		tcp:     tcpproxy.NewProxy(),
+		errs:    make(chan error, 1),
+		stopped: make(chan struct{}),
+	}
+}
+
+// proxyRuntime holds listeners of http and tcp proxies and applies config changes to them
+type proxyRuntime struct {
+	logger logger.Logger
+
+	mu      sync.Mutex
+	servers map[string]*proxyServer
+	tcp     *tcpproxy.Proxy
+	// adminToken and sessionStores of actual config used by admin
+	adminToken    string
+	sessionStores map[string]session.Store
+	// userProviders of actual config closed when replaced
+	userProviders map[string]user.Provider
+	// services holds healthcheck and admin listeners started once, nil until first config applied
+	services *serviceListeners
+
+	errs     chan error
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+// serviceListeners of healthcheck and admin, admin address is empty when admin disabled
+type serviceListeners struct {
+	healthcheck  config.Healthcheck
+	adminAddress string
+}
+
+func newServiceListeners(c config.AppConfig) *serviceListeners {
+	s := &serviceListeners{
+		healthcheck: config.Healthcheck{
+			Address: c.Healthcheck.Address,
+			Path:    c.Healthcheck.Path,
+		},
+	}
+	if a, ok := maybe.JustValid(c.Admin); ok {
+		s.adminAddress = a.Address
+	}
+	return s
+}
+
+// checkUnchanged returns error when config adds, moves or removes listeners started once
+func (s *serviceListeners) checkUnchanged(c config.AppConfig) error {
+	actual := newServiceListeners(c)
+	if actual.healthcheck != s.healthcheck {
+		return errors.Errorf(
+			"healthcheck %s%s can't be changed to %s%s without restart",
+			s.healthcheck.Address, s.healthcheck.Path,
+			actual.healthcheck.Address, actual.healthcheck.Path,
+		)
+	}
+	if actual.adminAddress != s.adminAddress {
+		return errors.Errorf(
+			"admin listener %q can't be changed to %q without restart",
+			s.adminAddress, actual.adminAddress,
+		)
+	}
+	return nil
+}
+
+type proxyServer struct {
+	server *http.Server
+	proxy  infraproxy.Proxy
+	// tls of listener swapped on config change, nil for plain http listeners
+	tls *atomic.Pointer[tls.Config]
+}
+
+// Apply swaps routing of running http proxies, starts listeners for added proxies and stops removed ones.
+// When some listener can't be started config rejected, its user providers closed and previous one keeps serving.
+// Healthcheck and admin listeners are started once, so config changing them rejected
+func (rt *proxyRuntime) Apply(c config.AppConfig) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	if rt.services != nil {
+		if err := rt.services.checkUnchanged(c); err != nil {
+			rt.closeUserProviders(c.UserProviders)
+			return err
+		}
+	}
+
+	actual := map[string]config.HTTPProxy{}
+	for _, p := range c.HTTPProxies {
+		actual[p.Address] = p
+	}
+
+	listeners := map[string]net.Listener{}
+	// reject releases resources acquired for rejected config
+	reject := func(err error) error {
+		for _, ln := range listeners {
+			_ = ln.Close()
+		}
+		rt.closeUserProviders(c.UserProviders)
+		return err
+	}
+
+	for address, p := range actual {
+		if s, ok := rt.servers[address]; ok && (s.tls == nil) != (p.TLS == nil) {
+			return reject(errors.Errorf("tls of running proxy %s can't be enabled or disabled without restart", address))
+		}
+	}
+
+	for address := range actual {
+		if _, ok := rt.servers[address]; ok {
+			continue
+		}
+
+		ln, err := net.Listen("tcp", address)
+		if err != nil {
+			return reject(errors.Wrapf(err, "failed to listen %s", address))
+		}
+		listeners[address] = ln
+	}
+
+	err := rt.tcp.Update(c.TCPProxies)
+	if err != nil {
+		return reject(err)
+	}
+
+	for address, s := range rt.servers {
+		p, ok := actual[address]
+		if !ok {
+			go rt.shutdown(s.server)
+			delete(rt.servers, address)
+			continue
+		}
+
+		s.proxy.Update(p.Downstream, p.Upstream, p.Limit)
+		if s.tls != nil {
+			s.tls.Store(p.TLS)
+		}
+	}
+
+	for address, ln := range listeners {
+		httpProxy := actual[address]
+
+		p := infraproxy.NewProxy(
+			httpProxy.Downstream,
+			httpProxy.Upstream,
+			httpProxy.Limit,
+			rt.logger,
+		)
+		server := newHTTPServer(address, p.Proxy())
+
+		s := &proxyServer{
+			server: server,
+			proxy:  p,
+		}
+		if httpProxy.TLS != nil {
+			s.tls = &atomic.Pointer[tls.Config]{}
+			s.tls.Store(httpProxy.TLS)
+			ln = tls.NewListener(ln, &tls.Config{
+				MinVersion: tls.VersionTLS12,
+				GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+					return s.tls.Load(), nil
+				},
+			})
+		}
+
+		rt.servers[address] = s
+		go rt.serve(server, ln)
+	}
+
+	if rt.services == nil {
+		rt.services = newServiceListeners(c)
+	}
+	rt.adminToken = maybe.Just(c.Admin).Token
+	rt.sessionStores = c.SessionStores
+
+	previous := rt.userProviders
+	rt.userProviders = c.UserProviders
+	rt.closeUserProviders(previous)
+
+	return nil
+}
+
+func (rt *proxyRuntime) AdminToken() string {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	return rt.adminToken
+}
+
+func (rt *proxyRuntime) RevokeUserSessions(userID uuid.UUID) (int, error) {
+	rt.mu.Lock()
+	stores := rt.sessionStores
+	rt.mu.Unlock()
+
+	// same store may be referred by several IDs
+	revoked := map[session.Store]bool{}
+	total := 0
+	for _, s := range stores {
+		if revoked[s] {
+			continue
+		}
+		revoked[s] = true
+
+		n, err := s.DeleteUser(userID)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+func (rt *proxyRuntime) Start() error {
+	select {
+	case err := <-rt.errs:
+		return err
+	case <-rt.stopped:
+		return nil
+	}
+}
+
+func (rt *proxyRuntime) Stop() error {
+	rt.stopOnce.Do(func() {
+		close(rt.stopped)
+	})
+
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	for address, s := range rt.servers {
+		rt.shutdown(s.server)
+		delete(rt.servers, address)
+	}
+
+	rt.closeUserProviders(rt.userProviders)
+	rt.userProviders = nil
+
+	return rt.tcp.Close()
+}
+
+// closeUserProviders releases connections of providers replaced by reload
+func (rt *proxyRuntime) closeUserProviders(providers map[string]user.Provider) {
+	for id, p := range providers {
+		c, ok := p.(io.Closer)
+		if !ok {
+			continue
+		}
+
+		err := c.Close()
+		if err != nil {
+			rt.logger.Errorf(err, "failed to close userprovider %s", id)
+		}
+	}
+}
+
+func (rt *proxyRuntime) serve(server *http.Server, ln net.Listener) {
+	err := server.Serve(ln)
+	if err == nil || errors.Is(err, http.ErrServerClosed) {
+		return
+	}
+
+	select {
+	case rt.errs <- errors.Wrapf(err, "failed to serve %s", server.Addr):
+	default:
+	}
+}
+
+func (rt *proxyRuntime) shutdown(server *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	err := server.Shutdown(ctx)
+	if err != nil {
+		rt.logger.Errorf(err, "failed to shutdown %s", server.Addr)
+	}
+}
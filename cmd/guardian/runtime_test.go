@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/UsingCoding/fpgo/pkg/maybe"
+	"github.com/pkg/errors"
+
+	"guardian/internal/common/infrastructure/logger"
+	"guardian/internal/guardian/app/config"
+	"guardian/internal/guardian/app/user"
+)
+
+// closingProvider records whether it was closed
+type closingProvider struct {
+	closed atomic.Bool
+}
+
+func (p *closingProvider) User(context.Context, user.Token) (user.Descriptor, error) {
+	return user.Descriptor{}, errors.WithStack(user.ErrUserNotFound)
+}
+
+func (p *closingProvider) Close() error {
+	p.closed.Store(true)
+	return nil
+}
+
+func newTestRuntime(t *testing.T) *proxyRuntime {
+	t.Helper()
+
+	rt := newProxyRuntime(logger.NewLogger(logger.Config{AppID: "test"}))
+	t.Cleanup(func() { _ = rt.Stop() })
+	return rt
+}
+
+// freeAddress returns address nothing listens on
+func freeAddress(t *testing.T) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := ln.Addr().String()
+	_ = ln.Close()
+	return address
+}
+
+func testConfig(provider user.Provider, addresses ...string) config.AppConfig {
+	c := config.AppConfig{
+		Healthcheck:   config.Healthcheck{Address: "127.0.0.1:0", Path: "/health"},
+		UserProviders: map[string]user.Provider{"users": provider},
+	}
+	for _, address := range addresses {
+		c.HTTPProxies = append(c.HTTPProxies, config.HTTPProxy{Address: address})
+	}
+	return c
+}
+
+func serving(address string) bool {
+	resp, err := (&http.Client{Timeout: time.Second}).Get("http://" + address)
+	if err != nil {
+		return false
+	}
+	_ = resp.Body.Close()
+	return true
+}
+
+func waitStopped(t *testing.T, address string) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for serving(address) {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s still served", address)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRuntimeApplyListeners(t *testing.T) {
+	rt := newTestRuntime(t)
+	first, second := freeAddress(t), freeAddress(t)
+
+	if err := rt.Apply(testConfig(&closingProvider{}, first)); err != nil {
+		t.Fatal(err)
+	}
+	if !serving(first) {
+		t.Fatalf("%s not served", first)
+	}
+
+	if err := rt.Apply(testConfig(&closingProvider{}, first, second)); err != nil {
+		t.Fatal(err)
+	}
+	if !serving(first) || !serving(second) {
+		t.Fatal("added listener not served or running one stopped")
+	}
+
+	if err := rt.Apply(testConfig(&closingProvider{}, second)); err != nil {
+		t.Fatal(err)
+	}
+	waitStopped(t, first)
+	if !serving(second) {
+		t.Fatalf("%s not served", second)
+	}
+}
+
+func TestRuntimeApplyRejected(t *testing.T) {
+	running := freeAddress(t)
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+
+	tlsConfig := testConfig(&closingProvider{}, running)
+	tlsConfig.HTTPProxies[0].TLS = &tls.Config{MinVersion: tls.VersionTLS12}
+
+	movedHealthcheck := testConfig(&closingProvider{}, running)
+	movedHealthcheck.Healthcheck.Address = "127.0.0.1:1"
+
+	addedAdmin := testConfig(&closingProvider{}, running)
+	addedAdmin.Admin = maybe.NewJust(config.Admin{Address: "127.0.0.1:2", Token: "token"})
+
+	tests := []struct {
+		name   string
+		config config.AppConfig
+		err    string
+	}{
+		{"listen failed", testConfig(&closingProvider{}, running, freeAddress(t), busy.Addr().String()), "failed to listen"},
+		{"tls enabled", tlsConfig, "without restart"},
+		{"healthcheck moved", movedHealthcheck, "without restart"},
+		{"admin added", addedAdmin, "without restart"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := newTestRuntime(t)
+			previous := &closingProvider{}
+			if err2 := rt.Apply(testConfig(previous, running)); err2 != nil {
+				t.Fatal(err2)
+			}
+
+			err2 := rt.Apply(tt.config)
+			if err2 == nil || !strings.Contains(err2.Error(), tt.err) {
+				t.Fatalf("got %v, want %q", err2, tt.err)
+			}
+
+			// previous config keeps serving, providers of rejected one released
+			if !serving(running) {
+				t.Error("previous listener stopped")
+			}
+			if len(rt.servers) != 1 {
+				t.Errorf("rejected config left %d listeners", len(rt.servers))
+			}
+			if previous.closed.Load() {
+				t.Error("providers of previous config closed")
+			}
+			if !tt.config.UserProviders["users"].(*closingProvider).closed.Load() {
+				t.Error("providers of rejected config not closed")
+			}
+		})
+	}
+}
+
+func TestRuntimeApplyClosesReplacedProviders(t *testing.T) {
+	rt := newTestRuntime(t)
+	address := freeAddress(t)
+
+	previous, actual := &closingProvider{}, &closingProvider{}
+	if err := rt.Apply(testConfig(previous, address)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rt.Apply(testConfig(actual, address)); err != nil {
+		t.Fatal(err)
+	}
+	if !previous.closed.Load() || actual.closed.Load() {
+		t.Errorf("previous closed %v, actual closed %v", previous.closed.Load(), actual.closed.Load())
+	}
+
+	if err := rt.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if !actual.closed.Load() {
+		t.Error("providers not closed on stop")
+	}
+}
+
+func TestReloaderKeepsConfigOnFailure(t *testing.T) {
+	rt := newTestRuntime(t)
+	address := freeAddress(t)
+	if err := rt.Apply(testConfig(&closingProvider{}, address)); err != nil {
+		t.Fatal(err)
+	}
+
+	next := testConfig(&closingProvider{}, address)
+	next.Sources = []string{"guardian.hcl"}
+	var loadErr error
+	r := newReloader(func() (config.AppConfig, error) {
+		return next, loadErr
+	}, nil, rt, logger.NewLogger(logger.Config{AppID: "test"}))
+
+	loadErr = errors.New("invalid config")
+	if _, ok := r.reload("signal"); ok {
+		t.Error("invalid config applied")
+	}
+
+	loadErr = nil
+	files, ok := r.reload("signal")
+	if !ok || len(files) != 1 {
+		t.Errorf("config not applied: %v", files)
+	}
+	if !serving(address) {
+		t.Error("listener stopped on reload")
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+
+	"guardian/internal/guardian/app/session"
+	"guardian/internal/guardian/app/user"
+	"guardian/internal/guardian/infrastructure/admin"
+	"guardian/internal/guardian/infrastructure/sessioncookie"
+)
+
+func sessionCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "session",
+		Usage: "Session cookie tools",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "issue",
+				Usage:  "Prints session cookie value for user",
+				Action: executeSessionIssue,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "user",
+						Usage:    "User ID",
+						Required: true,
+					},
+					&cli.DurationFlag{
+						Name:  "ttl",
+						Usage: "Session lifetime",
+						Value: 12 * time.Hour,
+					},
+					&cli.StringFlag{
+						Name:     "key-id",
+						Usage:    "ID of session key",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "secret",
+						Usage:    "Secret of session key",
+						EnvVars:  []string{"GUARDIAN_SESSION_SECRET"},
+						Required: true,
+					},
+					&cli.BoolFlag{
+						Name:  "encrypt",
+						Usage: "Encrypt session",
+					},
+				},
+			},
+			{
+				Name:   "revoke",
+				Usage:  "Revokes every server-side session of user on running proxy",
+				Action: executeSessionRevoke,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "user",
+						Usage:    "User ID",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "admin",
+						Usage: "URL of admin listener",
+						Value: "http://127.0.0.1:8081",
+					},
+					&cli.StringFlag{
+						Name:     "token",
+						Usage:    "Admin token",
+						EnvVars:  []string{"GUARDIAN_ADMIN_TOKEN"},
+						Required: true,
+					},
+				},
+			},
+		},
+	}
+}
+
+func executeSessionIssue(ctx *cli.Context) error {
+	userID, err := uuid.FromString(ctx.String("user"))
+	if err != nil {
+		return errors.Wrap(err, "invalid user id")
+	}
+
+	codec, err := sessioncookie.NewCodec([]sessioncookie.Key{
+		{
+			ID:     ctx.String("key-id"),
+			Secret: []byte(ctx.String("secret")),
+		},
+	}, ctx.Bool("encrypt"))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	value, err := codec.Encode(session.Session{
+		User:      user.Descriptor{ID: userID},
+		IssuedAt:  now,
+		ExpiresAt: now.Add(ctx.Duration("ttl")),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintln(os.Stdout, value)
+	return nil
+}
+
+func executeSessionRevoke(ctx *cli.Context) error {
+	userID, err := uuid.FromString(ctx.String("user"))
+	if err != nil {
+		return errors.Wrap(err, "invalid user id")
+	}
+
+	u := strings.TrimSuffix(ctx.String("admin"), "/") +
+		strings.ReplaceAll(admin.UserSessionsPath, "{id}", userID.String())
+	req, err := http.NewRequestWithContext(ctx.Context, http.MethodDelete, u, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+ctx.String("token"))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to request admin")
+	}
+	defer resp.Body.Close()
+
+	var res struct {
+		Revoked int    `json:"revoked"`
+		Error   string `json:"error"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&res)
+	if err != nil {
+		return errors.Wrapf(err, "admin responded with status %d", resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("admin responded with status %d: %s", resp.StatusCode, res.Error)
+	}
+
+	_, _ = fmt.Fprintf(os.Stdout, "revoked %d sessions\n", res.Revoked)
+	return nil
+}
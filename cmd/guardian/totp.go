@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+
+	"guardian/internal/guardian/infrastructure/totp"
+)
+
+func totpCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "totp",
+		Usage: "TOTP second factor tools",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "enroll",
+				Usage:  "Generates TOTP secret of user, writes it to secrets file and prints URI for authenticator app",
+				Action: executeTOTPEnroll,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "file",
+						Aliases:  []string{"f"},
+						Usage:    "Secrets file, created when missing",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "user",
+						Usage:    "User ID",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "username",
+						Usage: "Username shown in authenticator app",
+					},
+					&cli.StringFlag{
+						Name:  "issuer",
+						Usage: "Issuer shown in authenticator app",
+						Value: appID,
+					},
+					&cli.BoolFlag{
+						Name:  "replace",
+						Usage: "Replace secret of already enrolled user",
+					},
+				},
+			},
+		},
+	}
+}
+
+func executeTOTPEnroll(ctx *cli.Context) error {
+	userID, err := uuid.FromString(ctx.String("user"))
+	if err != nil {
+		return errors.Wrap(err, "invalid user id")
+	}
+
+	e, err := totp.Enroll(ctx.String("file"), userID, ctx.String("username"), ctx.Bool("replace"))
+	if err != nil {
+		return err
+	}
+
+	account := e.Username
+	if account == "" {
+		account = userID.String()
+	}
+
+	_, _ = fmt.Fprintf(os.Stdout, "secret: %s\nuri:    %s\n", e.Secret, totp.URI(ctx.String("issuer"), account, e.Secret))
+	return nil
+}
@@ -3,8 +3,27 @@ healthcheck {
     path    = "/healthz"
 }
 
-userprovider ldap {
-    address = "glauth:8080"
+userprovider ldap main {
+    # ldap://host:389, ldaps://host:636 or host:port
+    address  = "glauth:389"
+    startTLS = false
+    # tls {
+    #     caFile = "ca.pem"
+    # }
+
+    bindDN       = "cn=guardian,dc=example,dc=com"
+    bindPassword = env("LDAP_PASSWORD", "")
+
+    baseDN = "dc=example,dc=com"
+    # {id} replaced with user ID from cookie
+    filter = "(entryUUID={id})"
+    attributes {
+        id       = "entryUUID"
+        username = "uid"
+    }
+
+    poolSize = 4
+    timeout  = "5s"
 }
 
 tcpproxy ":80" {
@@ -15,7 +34,12 @@ tcpproxy ":443" {
     destination = "127.0.0.1:3000"
 }
 
-httproxy ":8000" {
+httpproxy ":8000" {
+    limit {
+        rps   = 100
+        burst = 200
+    }
+
     downstream filebrowser {
         upstream = "filebrowser"
 
@@ -24,15 +48,29 @@ httproxy ":8000" {
         }
         authorizer cookie {
             key = "access"
+            # may be omitted when only one userprovider configured
+            userprovider = "main"
+
+            session {
+                encrypt = false
+                # first key signs new sessions, others only verify, so keep old key until its sessions expire
+                key "2024-06" {
+                    secret = env("SESSION_SECRET", "change-me-to-at-least-32-bytes-secret")
+                }
+            }
         }
     }
 
     upstream filebrowser {
-        address = "filebrowser:80"
+        address = "http://filebrowser:80"
 
         authorizer header {
             userID   = "X-User-ID"
             username = "X-Username"
+            headers = {
+                "X-User-Email"  = "email"
+                "X-User-Groups" = "groups"
+            }
         }
     }
 }
\ No newline at end of file
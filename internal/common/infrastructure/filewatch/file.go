@@ -0,0 +1,125 @@
+package filewatch
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"guardian/internal/common/infrastructure/logger"
+)
+
+var reloadLogger atomic.Pointer[logger.Logger]
+
+// SetLogger sets logger of files failed to reload, failures aren't logged until it's set
+func SetLogger(l logger.Logger) {
+	reloadLogger.Store(&l)
+}
+
+// NewFile reads and parses file. Returns error when file can't be loaded
+func NewFile[T any](path string, interval time.Duration, parse func(data []byte) (T, error)) (*File[T], error) {
+	f := &File[T]{
+		path:     path,
+		interval: interval,
+		parse:    parse,
+	}
+
+	err := f.load()
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// File holds value parsed from file and reparses it when file changes.
+// Changes checked on access not more often than interval, so no background goroutines needed.
+// When changed file can't be parsed previous value kept, failure logged once per change of file and returned by Err
+type File[T any] struct {
+	path     string
+	interval time.Duration
+	parse    func(data []byte) (T, error)
+
+	mu        sync.Mutex
+	value     T
+	state     fileState
+	checkedAt time.Time
+	// failed holds state of file failed to reload, nil when last reload succeeded
+	failed []fileState
+	err    error
+}
+
+func (f *File[T]) Path() string {
+	return f.path
+}
+
+// Get returns actual value of file
+func (f *File[T]) Get() T {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if time.Since(f.checkedAt) < f.interval {
+		return f.value
+	}
+	f.checkedAt = time.Now()
+
+	cur := stat([]string{f.path})
+	if equal(cur, []fileState{f.state}) {
+		// unchanged or restored after failed change
+		f.failed = nil
+		f.err = nil
+		return f.value
+	}
+	if equal(cur, f.failed) {
+		return f.value
+	}
+
+	err := f.loadLocked()
+	if err != nil {
+		f.failed = cur
+		f.err = err
+		if l := reloadLogger.Load(); l != nil {
+			(*l).WithFields(logger.Fields{"path": f.path}).Error(err, "failed to reload file, previous version kept")
+		}
+		return f.value
+	}
+	f.failed = nil
+	f.err = nil
+
+	return f.value
+}
+
+// Err returns error of last reload, nil when file is up to date
+func (f *File[T]) Err() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.err
+}
+
+func (f *File[T]) load() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.checkedAt = time.Now()
+	return f.loadLocked()
+}
+
+func (f *File[T]) loadLocked() error {
+	state := stat([]string{f.path})[0]
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read %s", f.path)
+	}
+
+	v, err := f.parse(data)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse %s", f.path)
+	}
+
+	f.value = v
+	f.state = state
+	return nil
+}
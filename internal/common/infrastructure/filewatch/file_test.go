@@ -0,0 +1,106 @@
+package filewatch
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"guardian/internal/common/infrastructure/logger"
+)
+
+// recordingLogger records logged errors
+type recordingLogger struct {
+	mu     sync.Mutex
+	errors []error
+}
+
+func (l *recordingLogger) WithFields(logger.Fields) logger.Logger { return l }
+func (l *recordingLogger) Info(...interface{})                    {}
+func (l *recordingLogger) Infof(string, ...interface{})           {}
+func (l *recordingLogger) Warn(...interface{})                    {}
+func (l *recordingLogger) Warnf(string, ...interface{})           {}
+
+func (l *recordingLogger) Error(err error, _ ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.errors = append(l.errors, err)
+}
+
+func (l *recordingLogger) Errorf(err error, _ string, _ ...interface{}) {
+	l.Error(err)
+}
+
+func (l *recordingLogger) count() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.errors)
+}
+
+func TestFileReload(t *testing.T) {
+	l := &recordingLogger{}
+	SetLogger(l)
+	t.Cleanup(func() { reloadLogger.Store(nil) })
+
+	path := filepath.Join(t.TempDir(), "value")
+	write := func(data string, modTime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		// mod time set explicitly, so changes within same tick detected
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Now().Add(-time.Hour)
+	write("1", start)
+
+	f, err := NewFile(path, 0, func(data []byte) (int, error) {
+		n, err2 := strconv.Atoi(string(data))
+		return n, errors.WithStack(err2)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write("2", start.Add(time.Second))
+	if v := f.Get(); v != 2 || f.Err() != nil {
+		t.Fatalf("got %d, %v", v, f.Err())
+	}
+
+	write("broken", start.Add(2*time.Second))
+	for i := 0; i < 3; i++ {
+		if v := f.Get(); v != 2 {
+			t.Fatalf("previous value not kept, got %d", v)
+		}
+	}
+	if f.Err() == nil {
+		t.Error("reload error not reported")
+	}
+	if n := l.count(); n != 1 {
+		t.Errorf("failure logged %d times, want once per change", n)
+	}
+
+	write("still broken", start.Add(3*time.Second))
+	f.Get()
+	if n := l.count(); n != 2 {
+		t.Errorf("failure of next change logged %d times in total", n)
+	}
+
+	write("3", start.Add(4*time.Second))
+	if v := f.Get(); v != 3 || f.Err() != nil {
+		t.Errorf("got %d, %v after fix", v, f.Err())
+	}
+
+	if err = os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if v := f.Get(); v != 3 || f.Err() == nil {
+		t.Errorf("removed file: got %d, %v", v, f.Err())
+	}
+}
@@ -0,0 +1,68 @@
+package filewatch
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+const DefaultInterval = 2 * time.Second
+
+// Watch polls files with interval and calls onChange when any of them modified, created or removed.
+// Blocks until ctx is done
+func Watch(ctx context.Context, interval time.Duration, files []string, onChange func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	prev := stat(files)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		cur := stat(files)
+		if !equal(prev, cur) {
+			onChange()
+		}
+		prev = cur
+	}
+}
+
+type fileState struct {
+	exists  bool
+	modTime time.Time
+	size    int64
+}
+
+func stat(files []string) []fileState {
+	states := make([]fileState, 0, len(files))
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			states = append(states, fileState{})
+			continue
+		}
+		states = append(states, fileState{
+			exists:  true,
+			modTime: info.ModTime(),
+			size:    info.Size(),
+		})
+	}
+	return states
+}
+
+func equal(a, b []fileState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].exists != b[i].exists ||
+			!a[i].modTime.Equal(b[i].modTime) ||
+			a[i].size != b[i].size {
+			return false
+		}
+	}
+	return true
+}
@@ -13,6 +13,8 @@ type Logger interface {
 
 	Info(...interface{})
 	Infof(format string, args ...interface{})
+	Warn(...interface{})
+	Warnf(format string, args ...interface{})
 	Error(error, ...interface{})
 	Errorf(err error, format string, args ...interface{})
 }
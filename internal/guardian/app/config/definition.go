@@ -1,32 +1,60 @@
 package config
 
 import (
+	"crypto/tls"
+
 	"github.com/UsingCoding/fpgo/pkg/maybe"
 
 	"guardian/internal/guardian/app/proxy/downstream"
 	"guardian/internal/guardian/app/proxy/upstream"
+	"guardian/internal/guardian/app/session"
+	"guardian/internal/guardian/app/source"
 	"guardian/internal/guardian/app/user"
 )
 
 type AppConfig struct {
-	Healthcheck  Healthcheck
-	UserProvider maybe.Maybe[user.Provider]
-	TCPProxies   []TCPProxy
-	HTTPProxies  []HTTPProxy
+	Healthcheck Healthcheck
+	Admin       maybe.Maybe[Admin]
+	// UserProviders holds user providers by ID
+	UserProviders map[string]user.Provider
+	// SessionStores holds stores of server-side sessions by ID
+	SessionStores map[string]session.Store
+	TCPProxies    []TCPProxy
+	HTTPProxies   []HTTPProxy
+
+	// Sources holds files and directories config loaded from
+	Sources []string
 }
 
 type TCPProxy struct {
 	SrcAddress string
 	DstAddress string
+
+	Source source.Range
 }
 
 type Healthcheck struct {
 	Address string
 	Path    string
+
+	Source source.Range
+}
+
+// Admin serves operations on running proxy
+type Admin struct {
+	Address string
+	// Token authenticates admin requests
+	Token string
+
+	Source source.Range
 }
 
 type HTTPProxy struct {
 	Address string
+	Source  source.Range
+
+	// TLS of listener, plain http served when nil
+	TLS *tls.Config
 
 	Limit Limit
 
@@ -34,6 +62,15 @@ type HTTPProxy struct {
 	Upstream   []upstream.Upstream
 }
 
+func (p HTTPProxy) FindUpstream(id string) maybe.Maybe[upstream.Upstream] {
+	for _, u := range p.Upstream {
+		if u.ID == id {
+			return maybe.NewJust(u)
+		}
+	}
+	return maybe.Maybe[upstream.Upstream]{}
+}
+
 type Limit struct {
 	RPS   int
 	Burst int
@@ -0,0 +1,203 @@
+package config
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/UsingCoding/fpgo/pkg/maybe"
+
+	"guardian/internal/guardian/app/proxy/downstream"
+	"guardian/internal/guardian/app/source"
+)
+
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+type Problem struct {
+	Severity Severity
+	Summary  string
+	Detail   string
+	Source   source.Range
+}
+
+type Problems []Problem
+
+func (problems Problems) HasErrors() bool {
+	for _, p := range problems {
+		if p.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks semantic of config and returns all found problems
+func Validate(c AppConfig) Problems {
+	var problems Problems
+
+	problems = append(problems, validateListeners(c)...)
+
+	for _, p := range c.HTTPProxies {
+		problems = append(problems, validateHTTPProxy(p)...)
+	}
+
+	return problems
+}
+
+type listener struct {
+	kind    string
+	address string
+	source  source.Range
+}
+
+// validateListeners reports listeners which can't be bound together
+func validateListeners(c AppConfig) Problems {
+	listeners := []listener{
+		{
+			kind:    "healthcheck",
+			address: c.Healthcheck.Address,
+			source:  c.Healthcheck.Source,
+		},
+	}
+	if a, ok := maybe.JustValid(c.Admin); ok {
+		listeners = append(listeners, listener{
+			kind:    "admin",
+			address: a.Address,
+			source:  a.Source,
+		})
+	}
+	for _, p := range c.TCPProxies {
+		listeners = append(listeners, listener{
+			kind:    "tcpproxy",
+			address: p.SrcAddress,
+			source:  p.Source,
+		})
+	}
+	for _, p := range c.HTTPProxies {
+		listeners = append(listeners, listener{
+			kind:    "httpproxy",
+			address: p.Address,
+			source:  p.Source,
+		})
+	}
+
+	var problems Problems
+	for i, l := range listeners {
+		for _, prev := range listeners[:i] {
+			if !addressesClash(prev.address, l.address) {
+				continue
+			}
+
+			problems = append(problems, Problem{
+				Severity: SeverityError,
+				Summary:  fmt.Sprintf("%s %s clashes with %s %s", l.kind, l.address, prev.kind, prev.address),
+				Detail:   fmt.Sprintf("Listeners defined in %s and %s use same port", prev.source, l.source),
+				Source:   l.source,
+			})
+		}
+	}
+	return problems
+}
+
+func validateHTTPProxy(p HTTPProxy) Problems {
+	var problems Problems
+
+	referenced := map[string]bool{}
+	downstreamIDs := map[string]source.Range{}
+	var catchAll maybe.Maybe[downstream.Downstream]
+
+	for _, d := range p.Downstream {
+		if prev, ok := downstreamIDs[d.ID]; ok {
+			problems = append(problems, Problem{
+				Severity: SeverityError,
+				Summary:  fmt.Sprintf("downstream %s of httpproxy %s defined twice", d.ID, p.Address),
+				Detail:   fmt.Sprintf("Defined in %s and %s", prev, d.Source),
+				Source:   d.Source,
+			})
+		}
+		downstreamIDs[d.ID] = d.Source
+
+		if shadow, ok := maybe.JustValid(catchAll); ok {
+			problems = append(problems, Problem{
+				Severity: SeverityWarning,
+				Summary:  fmt.Sprintf("downstream %s is unreachable", d.ID),
+				Detail:   fmt.Sprintf("Downstream %s defined in %s has no rules and matches all requests before %s", shadow.ID, shadow.Source, d.ID),
+				Source:   d.Source,
+			})
+		} else if len(d.Rules) == 0 {
+			catchAll = maybe.NewJust(d)
+		}
+
+		referenced[d.UpstreamID] = true
+
+		u, ok := maybe.JustValid(p.FindUpstream(d.UpstreamID))
+		if !ok {
+			problems = append(problems, Problem{
+				Severity: SeverityError,
+				Summary:  fmt.Sprintf("upstream %s for downstream %s not found", d.UpstreamID, d.ID),
+				Source:   d.Source,
+			})
+			continue
+		}
+
+		if maybe.Valid(u.Authorizer) && !maybe.Valid(d.Authorizer) {
+			problems = append(problems, Problem{
+				Severity: SeverityError,
+				Summary:  fmt.Sprintf("downstream %s has no authorizer but upstream %s requires user", d.ID, u.ID),
+				Detail:   fmt.Sprintf("Every request to downstream %s will be rejected, add authorizer to downstream", d.ID),
+				Source:   d.Source,
+			})
+		}
+	}
+
+	upstreamIDs := map[string]source.Range{}
+	for _, u := range p.Upstream {
+		if prev, ok := upstreamIDs[u.ID]; ok {
+			problems = append(problems, Problem{
+				Severity: SeverityError,
+				Summary:  fmt.Sprintf("upstream %s of httpproxy %s defined twice", u.ID, p.Address),
+				Detail:   fmt.Sprintf("Defined in %s and %s", prev, u.Source),
+				Source:   u.Source,
+			})
+		}
+		upstreamIDs[u.ID] = u.Source
+
+		if !referenced[u.ID] {
+			problems = append(problems, Problem{
+				Severity: SeverityWarning,
+				Summary:  fmt.Sprintf("upstream %s is not used by any downstream", u.ID),
+				Source:   u.Source,
+			})
+		}
+	}
+
+	return problems
+}
+
+// addressesClash reports whether listeners on addresses can't be bound at same time.
+// Listener on unspecified host clashes with any listener on same port
+func addressesClash(a, b string) bool {
+	hostA, portA, errA := net.SplitHostPort(a)
+	hostB, portB, errB := net.SplitHostPort(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+
+	if portA != portB {
+		return false
+	}
+
+	return hostA == hostB || unspecifiedHost(hostA) || unspecifiedHost(hostB)
+}
+
+func unspecifiedHost(host string) bool {
+	if host == "" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsUnspecified()
+}
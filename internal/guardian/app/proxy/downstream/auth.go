@@ -2,31 +2,105 @@ package downstream
 
 import (
 	"context"
+	"encoding/json"
 	stderrors "errors"
 	"net/http"
+	"net/url"
 
 	"github.com/UsingCoding/fpgo/pkg/maybe"
-	"github.com/gofrs/uuid/v5"
 	"github.com/pkg/errors"
 
+	"guardian/internal/guardian/app/session"
 	"guardian/internal/guardian/app/user"
 )
 
 var (
 	ErrAuthDataNotFound = stderrors.New("auth data not found")
 	ErrAuthDataInvalid  = stderrors.New("auth data invalid")
+	// ErrAuthDenied returned when valid credentials don't grant access to downstream
+	ErrAuthDenied = stderrors.New("auth denied")
 )
 
 type Authorizer interface {
 	Auth(ctx context.Context, r http.Request) (user.Descriptor, error)
 }
 
-func NewCookieAuthorizer(cookieName string, userProvider user.Provider) Authorizer {
-	return &cookieAuthorizer{cookieName: cookieName, userProvider: userProvider}
+// Handler implemented by authorizers serving own endpoints like login callback
+type Handler interface {
+	// ServeAuth serves request when it's addressed to authorizer and reports whether request served.
+	// Error returned when request addressed to authorizer failed and response isn't written
+	ServeAuth(w http.ResponseWriter, r *http.Request) (bool, error)
+}
+
+// ResponseModifier implemented by authorizers changing upstream response of authorized user, like renewing session
+type ResponseModifier interface {
+	ModifyResponse(r *http.Request, resp *http.Response, descriptor user.Descriptor) error
+}
+
+// CredentialsStripper implemented by authorizers reading secrets upstream must not receive, like API keys in query
+type CredentialsStripper interface {
+	// StripCredentials removes credentials from URL and header of request in place, so they're neither proxied nor logged
+	StripCredentials(u *url.URL, header http.Header)
+}
+
+// ErrRedirect returned by authorizer when user should be redirected, like to login page
+type ErrRedirect struct {
+	URL     string
+	Cookies []*http.Cookie
+	// Header added to redirect response
+	Header http.Header
+}
+
+func (e ErrRedirect) Error() string {
+	return "redirect to " + e.URL
+}
+
+// ErrChallenge wraps authorizer error with challenge sent to client in WWW-Authenticate header
+type ErrChallenge struct {
+	Challenge string
+	Err       error
+}
+
+func (e *ErrChallenge) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ErrChallenge) Cause() error {
+	return e.Err
+}
+
+func (e *ErrChallenge) Unwrap() error {
+	return e.Err
+}
+
+// ErrResponse wraps authorizer error with response written to client as is, like denial of external auth service
+type ErrResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	Err        error
+}
+
+func (e *ErrResponse) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ErrResponse) Cause() error {
+	return e.Err
+}
+
+func (e *ErrResponse) Unwrap() error {
+	return e.Err
+}
+
+// NewCookieAuthorizer returns authorizer of users by session cookie encoded with codec
+func NewCookieAuthorizer(cookieName string, codec session.Codec, userProvider user.Provider) Authorizer {
+	return &cookieAuthorizer{cookieName: cookieName, codec: codec, userProvider: userProvider}
 }
 
 type cookieAuthorizer struct {
 	cookieName string
+	codec      session.Codec
 
 	userProvider user.Provider
 }
@@ -44,23 +118,62 @@ func (a *cookieAuthorizer) Auth(ctx context.Context, r http.Request) (user.Descr
 		return user.Descriptor{}, errors.WithStack(ErrAuthDataNotFound)
 	}
 
-	userIDStr := cook.Value
-	if userIDStr == "" {
+	if cook.Value == "" {
 		return user.Descriptor{}, errors.WithStack(ErrAuthDataNotFound)
 	}
 
-	userID, err := uuid.FromString(userIDStr)
+	s, err := a.codec.Decode(cook.Value)
 	if err != nil {
+		if !errors.Is(err, session.ErrInvalid) && !errors.Is(err, session.ErrExpired) {
+			// store of sessions failed, client isn't asked to log in again
+			return user.Descriptor{}, errors.Wrapf(err, "cookie %s", a.cookieName)
+		}
 		return user.Descriptor{}, errors.Wrapf(
 			ErrAuthDataInvalid,
-			"err: %s: userID %s",
+			"cookie %s: %s",
+			a.cookieName,
 			err.Error(),
-			userIDStr,
 		)
 	}
 
 	descriptor, err := a.userProvider.User(ctx, user.Token{
-		ID: userID,
+		ID: s.User.ID,
 	})
 	return descriptor, errors.WithStack(err)
 }
+
+func (a *cookieAuthorizer) SessionID(r http.Request) (string, bool) {
+	c, err := r.Cookie(a.cookieName)
+	if err != nil || c.Value == "" {
+		return "", false
+	}
+
+	s, err := a.codec.Decode(c.Value)
+	if err != nil || s.ID == "" {
+		return "", false
+	}
+	return s.ID, true
+}
+
+// ModifyResponse renews session of active user when codec keeps sessions on server
+func (a *cookieAuthorizer) ModifyResponse(r *http.Request, _ *http.Response, _ user.Descriptor) error {
+	renewer, ok := a.codec.(session.Renewer)
+	if !ok {
+		return nil
+	}
+
+	c, err := r.Cookie(a.cookieName)
+	if err != nil || c.Value == "" {
+		return nil
+	}
+	return renewer.Renew(c.Value)
+}
+
+func (a *cookieAuthorizer) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"type":         "cookie",
+		"key":          a.cookieName,
+		"session":      a.codec,
+		"userProvider": a.userProvider,
+	})
+}
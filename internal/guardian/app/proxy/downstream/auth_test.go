@@ -0,0 +1,87 @@
+package downstream
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/pkg/errors"
+
+	"guardian/internal/guardian/app/session"
+	"guardian/internal/guardian/app/user"
+)
+
+var errStore = errors.New("store i/o failed")
+
+// codecFunc decodes every session with function
+type codecFunc func(value string) (session.Session, error)
+
+func (f codecFunc) Encode(session.Session) (string, error) {
+	return "", errors.New("not supported")
+}
+
+func (f codecFunc) Decode(value string) (session.Session, error) {
+	return f(value)
+}
+
+type providerFunc func(ctx context.Context, token user.Token) (user.Descriptor, error)
+
+func (f providerFunc) User(ctx context.Context, token user.Token) (user.Descriptor, error) {
+	return f(ctx, token)
+}
+
+func TestCookieAuthorizerAuth(t *testing.T) {
+	userID := uuid.Must(uuid.NewV4())
+	codec := codecFunc(func(value string) (session.Session, error) {
+		switch value {
+		case "valid":
+			return session.Session{ID: "s1", User: user.Descriptor{ID: userID}}, nil
+		case "expired":
+			return session.Session{}, errors.WithStack(session.ErrExpired)
+		case "store-failed":
+			return session.Session{}, errors.WithStack(errStore)
+		default:
+			return session.Session{}, errors.WithStack(session.ErrInvalid)
+		}
+	})
+	a := NewCookieAuthorizer("session", codec, providerFunc(func(_ context.Context, token user.Token) (user.Descriptor, error) {
+		return user.Descriptor{ID: token.ID, Username: "alice"}, nil
+	}))
+
+	tests := []struct {
+		name   string
+		cookie string
+		err    error
+	}{
+		{name: "valid", cookie: "valid"},
+		{name: "no cookie", err: ErrAuthDataNotFound},
+		{name: "tampered", cookie: "tampered", err: ErrAuthDataInvalid},
+		{name: "expired", cookie: "expired", err: ErrAuthDataInvalid},
+		// client isn't sent to login when sessions can't be read
+		{name: "store failed", cookie: "store-failed", err: errStore},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: "session", Value: tt.cookie})
+			}
+
+			descriptor, err := a.Auth(context.Background(), *r)
+			if tt.err == nil {
+				if err != nil || descriptor.ID != userID {
+					t.Errorf("unexpected result %+v, %v", descriptor, err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("got %v, want %v", err, tt.err)
+			}
+			if tt.err == errStore && errors.Is(err, ErrAuthDataInvalid) {
+				t.Error("store failure reported as invalid session")
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package downstream
 import (
 	"github.com/UsingCoding/fpgo/pkg/maybe"
 
+	"guardian/internal/guardian/app/session"
 	"guardian/internal/guardian/app/user"
 )
 
@@ -12,6 +13,7 @@ type AuthProvider interface {
 
 type AuthorizerFactoryConfig struct {
 	cookie maybe.Maybe[string]
+	codec  session.Codec
 }
 
 func NewAuthorizerFactory(
@@ -44,6 +46,7 @@ func buildAuthorizers(
 	if c, ok := maybe.JustValid(config.cookie); ok {
 		authorizers[CookieAuthorizer] = &cookieAuthorizer{
 			cookieName:   c,
+			codec:        config.codec,
 			userProvider: userProvider,
 		}
 	}
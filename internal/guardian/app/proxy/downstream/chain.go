@@ -0,0 +1,152 @@
+package downstream
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/pkg/errors"
+
+	"guardian/internal/guardian/app/user"
+)
+
+type ChainMode string
+
+const (
+	// ChainFirst authorizes user by first authorizer succeeded
+	ChainFirst = ChainMode("first")
+	// ChainAll requires every authorizer to succeed, user resolved by first one
+	ChainAll = ChainMode("all")
+)
+
+// IsUnauthenticated reports whether err means request has no valid credentials, unlike failures of user sources
+func IsUnauthenticated(err error) bool {
+	var redirect *ErrRedirect
+	if errors.As(err, &redirect) {
+		return true
+	}
+
+	switch errors.Cause(err) {
+	case ErrAuthDataNotFound, ErrAuthDataInvalid, user.ErrUserNotFound:
+		return true
+	}
+	return false
+}
+
+// NewChain returns authorizer combining authorizers in order
+func NewChain(mode ChainMode, authorizers []Authorizer) Authorizer {
+	return &chain{mode: mode, authorizers: authorizers}
+}
+
+type chain struct {
+	mode        ChainMode
+	authorizers []Authorizer
+}
+
+func (c *chain) Auth(ctx context.Context, r http.Request) (user.Descriptor, error) {
+	if c.mode == ChainAll {
+		return c.all(ctx, r)
+	}
+	return c.first(ctx, r)
+}
+
+// first returns error of first authorizer found credentials when none succeeded,
+// so invalid credentials reported instead of missing ones of other authorizers
+func (c *chain) first(ctx context.Context, r http.Request) (user.Descriptor, error) {
+	var firstErr, credentialsErr error
+	for _, auth := range c.authorizers {
+		descriptor, err := auth.Auth(ctx, r)
+		if err == nil {
+			return descriptor, nil
+		}
+		if !IsUnauthenticated(err) && errors.Cause(err) != ErrAuthDenied {
+			return user.Descriptor{}, err
+		}
+
+		if firstErr == nil {
+			firstErr = err
+		}
+		if credentialsErr == nil && errors.Cause(err) != ErrAuthDataNotFound {
+			credentialsErr = err
+		}
+	}
+
+	if credentialsErr != nil {
+		return user.Descriptor{}, credentialsErr
+	}
+	return user.Descriptor{}, firstErr
+}
+
+func (c *chain) all(ctx context.Context, r http.Request) (user.Descriptor, error) {
+	var res user.Descriptor
+	for i, auth := range c.authorizers {
+		descriptor, err := auth.Auth(ctx, r)
+		if err != nil {
+			return user.Descriptor{}, err
+		}
+		if i == 0 {
+			res = descriptor
+		}
+	}
+	return res, nil
+}
+
+func (c *chain) ServeAuth(w http.ResponseWriter, r *http.Request) (bool, error) {
+	for _, auth := range c.authorizers {
+		handler, ok := auth.(Handler)
+		if !ok {
+			continue
+		}
+
+		served, err := handler.ServeAuth(w, r)
+		if served {
+			return served, err
+		}
+	}
+	return false, nil
+}
+
+// ModifyResponse lets every authorizer modify response, they check own credentials of request
+func (c *chain) ModifyResponse(r *http.Request, resp *http.Response, descriptor user.Descriptor) error {
+	for _, auth := range c.authorizers {
+		modifier, ok := auth.(ResponseModifier)
+		if !ok {
+			continue
+		}
+
+		err := modifier.ModifyResponse(r, resp, descriptor)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SessionID returns session of first authorizer supporting sessions found it
+func (c *chain) SessionID(r http.Request) (string, bool) {
+	for _, auth := range c.authorizers {
+		if s, ok := auth.(SessionAuthorizer); ok {
+			if id, found := s.SessionID(r); found {
+				return id, true
+			}
+		}
+	}
+	return "", false
+}
+
+func (c *chain) StripCredentials(u *url.URL, header http.Header) {
+	for _, auth := range c.authorizers {
+		if stripper, ok := auth.(CredentialsStripper); ok {
+			stripper.StripCredentials(u, header)
+		}
+	}
+}
+
+func (c *chain) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"type":        "chain",
+		"mode":        c.mode,
+		"authorizers": c.authorizers,
+	})
+}
@@ -0,0 +1,164 @@
+package downstream
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"guardian/internal/guardian/app/user"
+)
+
+// stubAuthorizer returns descriptor of username or err, counting calls
+type stubAuthorizer struct {
+	username string
+	err      error
+	calls    int
+}
+
+func (a *stubAuthorizer) Auth(context.Context, http.Request) (user.Descriptor, error) {
+	a.calls++
+	if a.err != nil {
+		return user.Descriptor{}, errors.WithStack(a.err)
+	}
+	return user.Descriptor{Username: a.username}, nil
+}
+
+func TestChainAuth(t *testing.T) {
+	tests := []struct {
+		name  string
+		mode  ChainMode
+		stubs []*stubAuthorizer
+		// username of resolved user, err expected when empty
+		username string
+		err      error
+		// calls of each authorizer
+		calls []int
+		// optional reports whether optional downstream lets request through anonymously
+		optional bool
+	}{
+		{
+			name:     "first succeeded",
+			mode:     ChainFirst,
+			stubs:    []*stubAuthorizer{{username: "alice"}, {username: "bob"}},
+			username: "alice",
+			calls:    []int{1, 0},
+		},
+		{
+			name:     "first falls through missing credentials",
+			mode:     ChainFirst,
+			stubs:    []*stubAuthorizer{{err: ErrAuthDataNotFound}, {username: "bob"}},
+			username: "bob",
+			calls:    []int{1, 1},
+		},
+		{
+			name:     "first falls through invalid credentials and unknown user",
+			mode:     ChainFirst,
+			stubs:    []*stubAuthorizer{{err: ErrAuthDataInvalid}, {err: user.ErrUserNotFound}, {username: "carol"}},
+			username: "carol",
+			calls:    []int{1, 1, 1},
+		},
+		{
+			name:     "first reports none found",
+			mode:     ChainFirst,
+			stubs:    []*stubAuthorizer{{err: ErrAuthDataNotFound}, {err: ErrAuthDataNotFound}},
+			err:      ErrAuthDataNotFound,
+			calls:    []int{1, 1},
+			optional: true,
+		},
+		{
+			// invalid credentials reported instead of missing ones of other authorizers
+			name:     "first reports invalid over missing",
+			mode:     ChainFirst,
+			stubs:    []*stubAuthorizer{{err: ErrAuthDataNotFound}, {err: ErrAuthDataInvalid}, {err: ErrAuthDataNotFound}},
+			err:      ErrAuthDataInvalid,
+			calls:    []int{1, 1, 1},
+			optional: true,
+		},
+		{
+			name:  "first reports denied",
+			mode:  ChainFirst,
+			stubs: []*stubAuthorizer{{err: ErrAuthDenied}, {err: ErrAuthDataNotFound}},
+			err:   ErrAuthDenied,
+			calls: []int{1, 1},
+		},
+		{
+			// failure of user source isn't hidden by other authorizers
+			name:  "first stops on unavailable provider",
+			mode:  ChainFirst,
+			stubs: []*stubAuthorizer{{err: user.ErrProviderUnavailable}, {username: "bob"}},
+			err:   user.ErrProviderUnavailable,
+			calls: []int{1, 0},
+		},
+		{
+			name:     "all resolves user by first",
+			mode:     ChainAll,
+			stubs:    []*stubAuthorizer{{username: "alice"}, {username: "service"}},
+			username: "alice",
+			calls:    []int{1, 1},
+		},
+		{
+			name:     "all stops on first failed",
+			mode:     ChainAll,
+			stubs:    []*stubAuthorizer{{username: "alice"}, {err: ErrAuthDataNotFound}, {username: "bob"}},
+			err:      ErrAuthDataNotFound,
+			calls:    []int{1, 1, 0},
+			optional: true,
+		},
+		{
+			name:  "all reports denied",
+			mode:  ChainAll,
+			stubs: []*stubAuthorizer{{err: ErrAuthDenied}, {username: "bob"}},
+			err:   ErrAuthDenied,
+			calls: []int{1, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authorizers := make([]Authorizer, 0, len(tt.stubs))
+			for _, stub := range tt.stubs {
+				authorizers = append(authorizers, stub)
+			}
+
+			descriptor, err := NewChain(tt.mode, authorizers).Auth(context.Background(), *httptest.NewRequest(http.MethodGet, "/", nil))
+			if tt.err == nil {
+				if err != nil || descriptor.Username != tt.username {
+					t.Errorf("got %+v, %v, want %s", descriptor, err, tt.username)
+				}
+			} else if !errors.Is(err, tt.err) {
+				t.Errorf("got %v, want %v", err, tt.err)
+			}
+
+			for i, stub := range tt.stubs {
+				if stub.calls != tt.calls[i] {
+					t.Errorf("authorizer %d called %d times, want %d", i, stub.calls, tt.calls[i])
+				}
+			}
+
+			if tt.err != nil && IsUnauthenticated(err) != tt.optional {
+				t.Errorf("optional downstream lets %v through: %v", err, IsUnauthenticated(err))
+			}
+		})
+	}
+}
+
+func TestIsUnauthenticated(t *testing.T) {
+	for err, want := range map[error]bool{
+		errors.WithStack(ErrAuthDataNotFound):                                      true,
+		errors.Wrap(ErrAuthDataInvalid, "token expired"):                           true,
+		errors.WithStack(user.ErrUserNotFound):                                     true,
+		&ErrRedirect{URL: "https://idp.example.com/authorize"}:                     true,
+		errors.WithStack(&ErrRedirect{URL: "https://idp.example"}):                 true,
+		errors.WithStack(ErrAuthDenied):                                            false,
+		errors.WithStack(user.ErrProviderUnavailable):                              false,
+		errors.Wrap(errStore, "failed to read session"):                            false,
+		&ErrResponse{StatusCode: http.StatusUnauthorized, Err: ErrAuthDataInvalid}: true,
+		&ErrResponse{StatusCode: http.StatusForbidden, Err: ErrAuthDenied}:         false,
+	} {
+		if got := IsUnauthenticated(err); got != want {
+			t.Errorf("%v: got %v, want %v", err, got, want)
+		}
+	}
+}
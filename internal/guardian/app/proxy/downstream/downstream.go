@@ -2,6 +2,8 @@ package downstream
 
 import (
 	"github.com/UsingCoding/fpgo/pkg/maybe"
+
+	"guardian/internal/guardian/app/source"
 )
 
 type AuthorizerType string
@@ -17,4 +19,14 @@ type Downstream struct {
 
 	UpstreamID string
 	Authorizer maybe.Maybe[Authorizer]
+	// AuthOptional lets unauthenticated requests through without user
+	AuthOptional bool
+	// OnUnauthenticated answers requests failed authentication instead of plain 401
+	OnUnauthenticated maybe.Maybe[Unauthenticated]
+	// Policy applied to users resolved by Authorizer
+	Policy maybe.Maybe[Policy]
+	// SecondFactor required from users passed Policy before request proxied
+	SecondFactor maybe.Maybe[SecondFactor]
+
+	Source source.Range
 }
@@ -0,0 +1,113 @@
+package downstream
+
+import (
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	"guardian/internal/guardian/app/user"
+)
+
+type Effect string
+
+const (
+	EffectAllow = Effect("allow")
+	EffectDeny  = Effect("deny")
+)
+
+// DefaultPolicyRule names decision made when no rule matched
+const DefaultPolicyRule = "default"
+
+// Policy decides whether authorized user may access downstream.
+// Matched deny rule wins over allow rules, Default applied when no rule matched
+type Policy struct {
+	Default Effect       `json:"default"`
+	Rules   []PolicyRule `json:"rules"`
+}
+
+// PolicyRule matches request when every non-empty condition matches
+type PolicyRule struct {
+	Name   string `json:"name"`
+	Effect Effect `json:"effect"`
+
+	// Paths are path prefixes matched by whole segments
+	Paths   []string `json:"paths,omitempty"`
+	Methods []string `json:"methods,omitempty"`
+
+	Users []string `json:"users,omitempty"`
+	// Groups matched when user is member of any of them
+	Groups []string `json:"groups,omitempty"`
+	// Attributes matched when user has all of them
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+type Decision struct {
+	Effect Effect
+	// Rule is name of matched rule or DefaultPolicyRule
+	Rule string
+}
+
+func (p Policy) Evaluate(r http.Request, descriptor user.Descriptor) Decision {
+	var allow *PolicyRule
+	for i, rule := range p.Rules {
+		if !rule.match(r, descriptor) {
+			continue
+		}
+
+		if rule.Effect == EffectDeny {
+			return Decision{Effect: EffectDeny, Rule: rule.Name}
+		}
+		if allow == nil {
+			allow = &p.Rules[i]
+		}
+	}
+
+	if allow != nil {
+		return Decision{Effect: EffectAllow, Rule: allow.Name}
+	}
+	return Decision{Effect: p.Default, Rule: DefaultPolicyRule}
+}
+
+func (rule PolicyRule) match(r http.Request, descriptor user.Descriptor) bool {
+	// path cleaned, so //admin and /./admin can't bypass rule of /admin
+	p := path.Clean("/" + r.URL.Path)
+	if len(rule.Paths) != 0 && !slices.ContainsFunc(rule.Paths, func(prefix string) bool {
+		return hasPathPrefix(p, prefix)
+	}) {
+		return false
+	}
+
+	if len(rule.Methods) != 0 && !slices.ContainsFunc(rule.Methods, func(method string) bool {
+		return strings.EqualFold(method, r.Method)
+	}) {
+		return false
+	}
+
+	if len(rule.Users) != 0 && !slices.Contains(rule.Users, descriptor.Username) {
+		return false
+	}
+
+	if len(rule.Groups) != 0 && !slices.ContainsFunc(rule.Groups, func(group string) bool {
+		return slices.Contains(descriptor.Groups, group)
+	}) {
+		return false
+	}
+
+	for name, value := range rule.Attributes {
+		if v, ok := descriptor.Attributes[name]; !ok || v != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// hasPathPrefix reports whether p equals prefix or continues it with next segment, so /admin doesn't match /administrator
+func hasPathPrefix(p, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" || p == prefix {
+		return true
+	}
+	return strings.HasPrefix(p, prefix+"/")
+}
@@ -0,0 +1,92 @@
+package downstream
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"guardian/internal/guardian/app/user"
+)
+
+func TestPolicyEvaluate(t *testing.T) {
+	policy := Policy{
+		Default: EffectAllow,
+		Rules: []PolicyRule{
+			{
+				Name:   "admin-only",
+				Effect: EffectDeny,
+				Paths:  []string{"/admin"},
+				Groups: []string{"users"},
+			},
+			{
+				Name:    "readonly",
+				Effect:  EffectDeny,
+				Paths:   []string{"/api/"},
+				Methods: []string{"POST", "DELETE"},
+				Attributes: map[string]string{
+					"role": "viewer",
+				},
+			},
+			{
+				Name:   "admins",
+				Effect: EffectAllow,
+				Users:  []string{"root"},
+			},
+		},
+	}
+
+	alice := user.Descriptor{Username: "alice", Groups: []string{"users"}}
+	viewer := user.Descriptor{Username: "bob", Attributes: map[string]string{"role": "viewer"}}
+	root := user.Descriptor{Username: "root", Groups: []string{"admins"}}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		descriptor user.Descriptor
+		effect     Effect
+		rule       string
+	}{
+		{"prefix denied", "GET", "/admin", alice, EffectDeny, "admin-only"},
+		{"nested path denied", "GET", "/admin/users", alice, EffectDeny, "admin-only"},
+		{"double slash denied", "GET", "//admin", alice, EffectDeny, "admin-only"},
+		{"dot segment denied", "GET", "/./admin", alice, EffectDeny, "admin-only"},
+		{"dot dot segment denied", "GET", "/public/../admin/x", alice, EffectDeny, "admin-only"},
+		{"trailing slash denied", "GET", "/admin/", alice, EffectDeny, "admin-only"},
+		{"longer segment not matched", "GET", "/administrator", alice, EffectAllow, DefaultPolicyRule},
+		{"other group allowed", "GET", "/admin", root, EffectAllow, "admins"},
+		{"prefix with slash matches root", "POST", "/api", viewer, EffectDeny, "readonly"},
+		{"method case insensitive", "delete", "/api/items", viewer, EffectDeny, "readonly"},
+		{"other method allowed", "GET", "/api/items", viewer, EffectAllow, DefaultPolicyRule},
+		{"missing attribute allowed", "POST", "/api/items", alice, EffectAllow, DefaultPolicyRule},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := http.Request{Method: tt.method, URL: &url.URL{Path: tt.path}}
+
+			d := policy.Evaluate(r, tt.descriptor)
+			if d.Effect != tt.effect || d.Rule != tt.rule {
+				t.Errorf("got %s by %s, want %s by %s", d.Effect, d.Rule, tt.effect, tt.rule)
+			}
+		})
+	}
+}
+
+func TestPolicyDenyWins(t *testing.T) {
+	policy := Policy{
+		Default: EffectDeny,
+		Rules: []PolicyRule{
+			{Name: "all", Effect: EffectAllow},
+			{Name: "blocked", Effect: EffectDeny, Users: []string{"mallory"}},
+		},
+	}
+
+	r := http.Request{Method: "GET", URL: &url.URL{Path: "/"}}
+	if d := policy.Evaluate(r, user.Descriptor{Username: "mallory"}); d.Effect != EffectDeny || d.Rule != "blocked" {
+		t.Errorf("got %s by %s, want deny by blocked", d.Effect, d.Rule)
+	}
+	if d := policy.Evaluate(r, user.Descriptor{Username: "alice"}); d.Effect != EffectAllow || d.Rule != "all" {
+		t.Errorf("got %s by %s, want allow by all", d.Effect, d.Rule)
+	}
+}
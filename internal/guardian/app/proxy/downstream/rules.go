@@ -2,6 +2,7 @@ package downstream
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"strings"
 )
@@ -18,6 +19,13 @@ func (h HostDownstreamRule) Match(_ context.Context, r http.Request) bool {
 	return r.Host == h.Host
 }
 
+func (h HostDownstreamRule) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{
+		"type": "host",
+		"host": h.Host,
+	})
+}
+
 type PathPrefix struct {
 	Prefix string
 }
@@ -25,3 +33,10 @@ type PathPrefix struct {
 func (p PathPrefix) Match(_ context.Context, r http.Request) bool {
 	return strings.HasPrefix(r.URL.Path, p.Prefix)
 }
+
+func (p PathPrefix) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{
+		"type": "path-prefix",
+		"path": p.Prefix,
+	})
+}
@@ -0,0 +1,40 @@
+package downstream
+
+import (
+	stderrors "errors"
+	"net/http"
+
+	"guardian/internal/guardian/app/user"
+)
+
+// ErrSecondFactorRequired returned when authenticated user hasn't passed second factor
+var ErrSecondFactorRequired = stderrors.New("second factor required")
+
+// SecondFactor verifies second factor of users authenticated by downstream authorizer.
+// Passed check bound to session of first factor, so it's dropped on logout and revocation of session
+type SecondFactor interface {
+	// Verify returns error wrapping ErrSecondFactorRequired when user hasn't passed second factor within session,
+	// like redirect to challenge page
+	Verify(r http.Request, descriptor user.Descriptor, sessionID string) error
+	// Challenges reports whether request addressed to challenge endpoint
+	Challenges(r *http.Request) bool
+	// ServeChallenge serves challenge endpoint to authenticated user.
+	// Error returned when response isn't written
+	ServeChallenge(w http.ResponseWriter, r *http.Request, descriptor user.Descriptor, sessionID string) error
+}
+
+// SessionAuthorizer implemented by authorizers of users by sessions, second factor bound to them
+type SessionAuthorizer interface {
+	// SessionID returns ID of valid session request carries
+	SessionID(r http.Request) (string, bool)
+}
+
+// SessionID returns ID of session request authenticated by when authorizer supports sessions
+func SessionID(auth Authorizer, r http.Request) string {
+	s, ok := auth.(SessionAuthorizer)
+	if !ok {
+		return ""
+	}
+	id, _ := s.SessionID(r)
+	return id
+}
@@ -0,0 +1,154 @@
+package downstream
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultReturnToParam is query parameter of login URL holding original URL
+const DefaultReturnToParam = "return_to"
+
+// UnauthenticatedMode chooses how requests without valid credentials answered
+type UnauthenticatedMode string
+
+const (
+	// ModeNegotiate redirects browsers navigating to page and answers other requests with JSON 401
+	ModeNegotiate UnauthenticatedMode = "negotiate"
+	// ModeRedirect redirects every request to login page
+	ModeRedirect UnauthenticatedMode = "redirect"
+	// ModeUnauthorized answers every request with JSON 401
+	ModeUnauthorized UnauthenticatedMode = "unauthorized"
+)
+
+// Unauthenticated answers requests without valid credentials:
+// browsers redirected to login page, API clients get JSON 401
+type Unauthenticated struct {
+	LoginURL      *url.URL
+	ReturnToParam string
+	// Mode is ModeNegotiate when empty
+	Mode UnauthenticatedMode
+}
+
+// Respond wraps auth error with response to client,
+// redirects and responses of authorizers, like denial of external auth service, and other errors returned as is
+func (u Unauthenticated) Respond(r http.Request, err error) error {
+	var redirect *ErrRedirect
+	var response *ErrResponse
+	if !IsUnauthenticated(err) || errors.As(err, &redirect) || errors.As(err, &response) {
+		return err
+	}
+
+	login := u.loginURL(r)
+	header := http.Header{}
+	var toLogin bool
+	switch u.Mode {
+	case ModeRedirect:
+		toLogin = true
+	case ModeUnauthorized:
+	default:
+		// response depends on Accept, so caches must not serve it to other clients
+		header.Set("Vary", "Accept")
+		toLogin = AcceptsHTML(r)
+	}
+
+	if toLogin {
+		return &ErrRedirect{URL: login, Header: header}
+	}
+
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	encoder.SetEscapeHTML(false)
+	if encodeErr := encoder.Encode(map[string]string{
+		"error":    "unauthenticated",
+		"loginURL": login,
+	}); encodeErr != nil {
+		return err
+	}
+
+	header.Set("Content-Type", "application/json")
+	return &ErrResponse{
+		StatusCode: http.StatusUnauthorized,
+		Header:     header,
+		Body:       body.Bytes(),
+		Err:        err,
+	}
+}
+
+func (u Unauthenticated) loginURL(r http.Request) string {
+	param := u.ReturnToParam
+	if param == "" {
+		param = DefaultReturnToParam
+	}
+	return WithReturnTo(u.LoginURL, param, r)
+}
+
+// WithReturnTo returns target with absolute URL of request in param of query
+func WithReturnTo(target *url.URL, param string, r http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	original := url.URL{
+		Scheme:   scheme,
+		Host:     r.Host,
+		Path:     r.URL.Path,
+		RawPath:  r.URL.RawPath,
+		RawQuery: r.URL.RawQuery,
+	}
+
+	res := *target
+	query := res.Query()
+	query.Set(param, original.String())
+	res.RawQuery = query.Encode()
+	return res.String()
+}
+
+// ReturnTo returns target of redirect back after login or challenge, it must be local path or URL on same host
+func ReturnTo(r *http.Request, target string) string {
+	if strings.HasPrefix(target, "/") && !strings.HasPrefix(target, "//") && !strings.HasPrefix(target, "/\\") {
+		return target
+	}
+
+	u, err := url.Parse(target)
+	if err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host == r.Host {
+		return target
+	}
+	return "/"
+}
+
+// AcceptsHTML reports whether request made by browser navigating to page, only GET and HEAD redirected since other methods lose body
+func AcceptsHTML(r http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err == nil && (mediaType == "text/html" || mediaType == "application/xhtml+xml") {
+			return true
+		}
+	}
+	return false
+}
+
+func (u Unauthenticated) MarshalJSON() ([]byte, error) {
+	param := u.ReturnToParam
+	if param == "" {
+		param = DefaultReturnToParam
+	}
+	mode := u.Mode
+	if mode == "" {
+		mode = ModeNegotiate
+	}
+	return json.Marshal(map[string]string{
+		"loginURL":      u.LoginURL.String(),
+		"returnToParam": param,
+		"mode":          string(mode),
+	})
+}
@@ -0,0 +1,96 @@
+package downstream
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestUnauthenticatedRespond(t *testing.T) {
+	loginURL := &url.URL{Path: "/login"}
+	authErr := errors.WithStack(ErrAuthDataNotFound)
+
+	tests := []struct {
+		name     string
+		mode     UnauthenticatedMode
+		method   string
+		accept   string
+		redirect bool
+		vary     bool
+	}{
+		{name: "browser", method: http.MethodGet, accept: "text/html,*/*;q=0.8", redirect: true, vary: true},
+		{name: "api", method: http.MethodGet, accept: "application/json", vary: true},
+		{name: "browser post", method: http.MethodPost, accept: "text/html", vary: true},
+		{name: "negotiate", mode: ModeNegotiate, method: http.MethodGet, accept: "text/html", redirect: true, vary: true},
+		{name: "redirect api", mode: ModeRedirect, method: http.MethodGet, accept: "application/json", redirect: true},
+		{name: "unauthorized browser", mode: ModeUnauthorized, method: http.MethodGet, accept: "text/html"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "http://app.example.com/page?x=1", nil)
+			r.Header.Set("Accept", tt.accept)
+
+			err := Unauthenticated{LoginURL: loginURL, Mode: tt.mode}.Respond(*r, authErr)
+
+			var header http.Header
+			var redirect *ErrRedirect
+			var response *ErrResponse
+			switch {
+			case errors.As(err, &redirect):
+				if !tt.redirect {
+					t.Fatalf("unexpected redirect to %s", redirect.URL)
+				}
+				if !strings.HasPrefix(redirect.URL, "/login?return_to=http%3A%2F%2Fapp.example.com%2Fpage%3Fx%3D1") {
+					t.Errorf("unexpected login URL %s", redirect.URL)
+				}
+				header = redirect.Header
+			case errors.As(err, &response):
+				if tt.redirect {
+					t.Fatal("expected redirect")
+				}
+				if response.StatusCode != http.StatusUnauthorized || !strings.Contains(string(response.Body), `"loginURL"`) {
+					t.Errorf("unexpected response %d %s", response.StatusCode, response.Body)
+				}
+				if !errors.Is(err, ErrAuthDataNotFound) {
+					t.Error("auth error not wrapped")
+				}
+				header = response.Header
+			default:
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			if vary := header.Get("Vary") == "Accept"; vary != tt.vary {
+				t.Errorf("Vary: Accept set %v, want %v", vary, tt.vary)
+			}
+		})
+	}
+}
+
+func TestUnauthenticatedRespondKeepsOtherErrors(t *testing.T) {
+	u := Unauthenticated{LoginURL: &url.URL{Path: "/login"}}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	authRedirect := &ErrRedirect{URL: "https://idp.example.com/authorize"}
+	if err := u.Respond(*r, authRedirect); err != authRedirect {
+		t.Errorf("redirect of authorizer replaced by %v", err)
+	}
+
+	// response of external auth service passed to client as is
+	serviceResponse := &ErrResponse{
+		StatusCode: http.StatusUnauthorized,
+		Header:     http.Header{"Location": {"https://auth.example.com/login"}},
+		Err:        errors.WithStack(ErrAuthDataInvalid),
+	}
+	if err := u.Respond(*r, serviceResponse); err != serviceResponse {
+		t.Errorf("response of authorizer replaced by %v", err)
+	}
+
+	denied := errors.WithStack(ErrAuthDenied)
+	if err := u.Respond(*r, denied); err != denied {
+		t.Errorf("denial replaced by %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package upstream
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 
 	"guardian/internal/guardian/app/user"
@@ -11,16 +12,34 @@ type Authorizer interface {
 	Authorize(ctx context.Context, r *http.Request, token user.Descriptor)
 }
 
-func NewAuthHeaderAuthorizer(headerID, headerUsername string) Authorizer {
-	return &authHeaderAuthorizer{headerID: headerID, headerUsername: headerUsername}
+// NewAuthHeaderAuthorizer forwards descriptor fields in headers, headers maps header name to field
+func NewAuthHeaderAuthorizer(headers map[string]user.Field) Authorizer {
+	canonical := make(map[string]user.Field, len(headers))
+	for name, field := range headers {
+		canonical[http.CanonicalHeaderKey(name)] = field
+	}
+	return &authHeaderAuthorizer{headers: canonical}
 }
 
 type authHeaderAuthorizer struct {
-	headerID       string
-	headerUsername string
+	headers map[string]user.Field
 }
 
 func (auth *authHeaderAuthorizer) Authorize(_ context.Context, r *http.Request, descriptor user.Descriptor) {
-	r.Header.Set(auth.headerID, descriptor.ID.String())
-	r.Header.Set(auth.headerUsername, descriptor.Username)
+	for name, field := range auth.headers {
+		value := descriptor.Field(field)
+		if value == "" {
+			// header sent by client must not reach upstream
+			r.Header.Del(name)
+			continue
+		}
+		r.Header.Set(name, value)
+	}
+}
+
+func (auth *authHeaderAuthorizer) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"type":    HeaderAuthorizerType,
+		"headers": auth.headers,
+	})
 }
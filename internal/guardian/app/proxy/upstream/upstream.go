@@ -4,6 +4,8 @@ import (
 	"net/url"
 
 	"github.com/UsingCoding/fpgo/pkg/maybe"
+
+	"guardian/internal/guardian/app/source"
 )
 
 type AuthorizerType string
@@ -17,4 +19,6 @@ type Upstream struct {
 	Address *url.URL
 
 	Authorizer maybe.Maybe[Authorizer]
+
+	Source source.Range
 }
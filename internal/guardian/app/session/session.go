@@ -0,0 +1,35 @@
+package session
+
+import (
+	stderrors "errors"
+	"time"
+
+	"guardian/internal/guardian/app/user"
+)
+
+var (
+	// ErrInvalid returned by Codec when session is malformed, tampered or signed by unknown key
+	ErrInvalid = stderrors.New("session invalid")
+	// ErrExpired returned by Codec when session expired
+	ErrExpired = stderrors.New("session expired")
+)
+
+// Session is authenticated user state kept by client
+type Session struct {
+	// ID identifies session, codec assigns it when session encoded first time and keeps it on renewal
+	ID string
+	// Binding is ID of session this one valid with, like second factor pass bound to session of first factor
+	Binding string
+	// User holds ID of user, other fields kept for users not backed by user.Provider
+	User user.Descriptor
+	// IssuedAt is time user authenticated, it's kept when session renewed
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// Codec encodes session into tamper-proof value and decodes it back
+type Codec interface {
+	Encode(s Session) (string, error)
+	// Decode returns ErrInvalid or ErrExpired when session can't be trusted
+	Decode(value string) (Session, error)
+}
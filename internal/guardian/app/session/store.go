@@ -0,0 +1,44 @@
+package session
+
+import (
+	stderrors "errors"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+)
+
+// ErrNotFound returned by Store when session doesn't exist or revoked
+var ErrNotFound = stderrors.New("session not found")
+
+// Record is session kept by server, client holds only its ID
+type Record struct {
+	ID           string    `json:"id"`
+	UserID       uuid.UUID `json:"user"`
+	CreatedAt    time.Time `json:"createdAt"`
+	LastAccessAt time.Time `json:"lastAccessAt"`
+	// ExpiresAt is absolute expiration time, store may drop record after it
+	ExpiresAt time.Time `json:"expiresAt"`
+	// Binding is ID of session record valid with
+	Binding string `json:"binding,omitempty"`
+}
+
+// Store keeps server-side sessions
+type Store interface {
+	Create(r Record) error
+	Get(id string) (Record, error)
+	// Touch updates last access time of session
+	Touch(id string, at time.Time) error
+	Delete(id string) error
+	// DeleteUser deletes every session of user and returns number of deleted sessions
+	DeleteUser(userID uuid.UUID) (int, error)
+}
+
+// Renewer implemented by codecs extending sessions of active users
+type Renewer interface {
+	Renew(value string) error
+}
+
+// Revoker implemented by codecs able to revoke session before expiration, like on logout
+type Revoker interface {
+	Revoke(value string) error
+}
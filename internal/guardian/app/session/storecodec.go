@@ -0,0 +1,163 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/pkg/errors"
+
+	"guardian/internal/guardian/app/user"
+)
+
+// maxTouchInterval limits how often last access time of active session written to store
+const maxTouchInterval = time.Minute
+
+type StoreConfig struct {
+	// AbsoluteTimeout limits lifetime of session since login, lifetime set by issuer used when zero
+	AbsoluteTimeout time.Duration
+	// IdleTimeout expires session not used for this time, sessions don't expire by inactivity when zero
+	IdleTimeout time.Duration
+}
+
+// NewStoreCodec returns codec keeping sessions in store, encoded value is opaque session ID.
+// Store keeps only ID of user, so sessions of codec must be used with user provider resolving user by ID
+func NewStoreCodec(store Store, config StoreConfig) *StoreCodec {
+	return &StoreCodec{
+		store:  store,
+		config: config,
+	}
+}
+
+type StoreCodec struct {
+	store  Store
+	config StoreConfig
+}
+
+func (c *StoreCodec) Encode(s Session) (string, error) {
+	now := time.Now()
+	expiresAt := s.ExpiresAt
+	if c.config.AbsoluteTimeout > 0 && (expiresAt.IsZero() || now.Add(c.config.AbsoluteTimeout).Before(expiresAt)) {
+		expiresAt = now.Add(c.config.AbsoluteTimeout)
+	}
+	if expiresAt.IsZero() {
+		return "", errors.New("session without expiration can't be stored")
+	}
+
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	r := Record{
+		ID:           base64.RawURLEncoding.EncodeToString(b),
+		UserID:       s.User.ID,
+		CreatedAt:    now,
+		LastAccessAt: now,
+		ExpiresAt:    expiresAt,
+		Binding:      s.Binding,
+	}
+	err = c.store.Create(r)
+	if err != nil {
+		return "", err
+	}
+	return r.ID, nil
+}
+
+// Decode returns session with user holding only ID
+func (c *StoreCodec) Decode(value string) (Session, error) {
+	r, err := c.record(value)
+	if err != nil {
+		return Session{}, err
+	}
+
+	expiresAt := r.ExpiresAt
+	if c.config.IdleTimeout > 0 && r.LastAccessAt.Add(c.config.IdleTimeout).Before(expiresAt) {
+		expiresAt = r.LastAccessAt.Add(c.config.IdleTimeout)
+	}
+
+	return Session{
+		ID:        r.ID,
+		Binding:   r.Binding,
+		User:      user.Descriptor{ID: r.UserID},
+		IssuedAt:  r.CreatedAt,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// Renew updates last access time of session, writes to store throttled
+func (c *StoreCodec) Renew(value string) error {
+	if c.config.IdleTimeout <= 0 {
+		return nil
+	}
+
+	r, err := c.record(value)
+	if err != nil {
+		// session expired or revoked while request proxied
+		return nil
+	}
+
+	now := time.Now()
+	if now.Sub(r.LastAccessAt) < c.touchInterval() {
+		return nil
+	}
+	err = c.store.Touch(r.ID, now)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (c *StoreCodec) Revoke(value string) error {
+	err := c.store.Delete(value)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// RevokeUser deletes every session of user
+func (c *StoreCodec) RevokeUser(userID uuid.UUID) (int, error) {
+	return c.store.DeleteUser(userID)
+}
+
+// record returns session which is neither expired nor idle, expired sessions deleted
+func (c *StoreCodec) record(id string) (Record, error) {
+	r, err := c.store.Get(id)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return Record{}, errors.WithStack(ErrInvalid)
+		}
+		return Record{}, err
+	}
+
+	now := time.Now()
+	expired := !now.Before(r.ExpiresAt) ||
+		(c.config.IdleTimeout > 0 && now.Sub(r.LastAccessAt) >= c.config.IdleTimeout)
+	if expired {
+		_ = c.store.Delete(r.ID)
+		return Record{}, errors.WithStack(ErrExpired)
+	}
+	return r, nil
+}
+
+// touchInterval keeps error of idle expiration within tenth of idle timeout
+func (c *StoreCodec) touchInterval() time.Duration {
+	return min(c.config.IdleTimeout/10, maxTouchInterval)
+}
+
+func (c *StoreCodec) MarshalJSON() ([]byte, error) {
+	res := map[string]any{
+		"store": c.store,
+	}
+	if c.config.AbsoluteTimeout > 0 {
+		res["absoluteTimeout"] = c.config.AbsoluteTimeout.String()
+	}
+	if c.config.IdleTimeout > 0 {
+		res["idleTimeout"] = c.config.IdleTimeout.String()
+	}
+	return json.Marshal(res)
+}
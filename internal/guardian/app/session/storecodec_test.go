@@ -0,0 +1,224 @@
+package session
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/pkg/errors"
+
+	"guardian/internal/guardian/app/user"
+)
+
+var errStoreUnavailable = errors.New("store unavailable")
+
+// mapStore is Store failing with errStoreUnavailable when unavailable set
+type mapStore struct {
+	mu          sync.Mutex
+	records     map[string]Record
+	touches     int
+	unavailable bool
+}
+
+func newMapStore() *mapStore {
+	return &mapStore{records: map[string]Record{}}
+}
+
+func (s *mapStore) Create(r Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.unavailable {
+		return errStoreUnavailable
+	}
+	s.records[r.ID] = r
+	return nil
+}
+
+func (s *mapStore) Get(id string) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.unavailable {
+		return Record{}, errStoreUnavailable
+	}
+	r, ok := s.records[id]
+	if !ok {
+		return Record{}, errors.WithStack(ErrNotFound)
+	}
+	return r, nil
+}
+
+func (s *mapStore) Touch(id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.records[id]
+	if !ok {
+		return errors.WithStack(ErrNotFound)
+	}
+	r.LastAccessAt = at
+	s.records[id] = r
+	s.touches++
+	return nil
+}
+
+func (s *mapStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.records[id]; !ok {
+		return errors.WithStack(ErrNotFound)
+	}
+	delete(s.records, id)
+	return nil
+}
+
+func (s *mapStore) DeleteUser(userID uuid.UUID) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for id, r := range s.records {
+		if r.UserID == userID {
+			delete(s.records, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *mapStore) lastAccess(id string) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.records[id].LastAccessAt
+}
+
+func (s *mapStore) setLastAccess(id string, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.records[id]
+	r.LastAccessAt = at
+	s.records[id] = r
+}
+
+func TestStoreCodecEncodeDecode(t *testing.T) {
+	store := newMapStore()
+	c := NewStoreCodec(store, StoreConfig{AbsoluteTimeout: time.Hour})
+
+	userID := uuid.Must(uuid.NewV4())
+	value, err := c.Encode(Session{
+		User:      user.Descriptor{ID: userID, Username: "alice"},
+		Binding:   "primary",
+		ExpiresAt: time.Now().Add(12 * time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := c.Decode(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.ID != value || s.User.ID != userID || s.Binding != "primary" {
+		t.Errorf("unexpected session %+v", s)
+	}
+	// user resolved by provider, so store doesn't keep it
+	if s.User.Username != "" {
+		t.Errorf("user other than ID stored: %+v", s.User)
+	}
+	if time.Until(s.ExpiresAt) > time.Hour {
+		t.Errorf("expiration %s not limited by absolute timeout", s.ExpiresAt)
+	}
+
+	if _, err = NewStoreCodec(store, StoreConfig{}).Encode(Session{User: user.Descriptor{ID: userID}}); err == nil {
+		t.Error("session without expiration stored")
+	}
+	if _, err = NewStoreCodec(store, StoreConfig{}).Decode("unknown"); !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected ErrInvalid for unknown session, got %v", err)
+	}
+}
+
+func TestStoreCodecExpiration(t *testing.T) {
+	store := newMapStore()
+	c := NewStoreCodec(store, StoreConfig{IdleTimeout: time.Minute})
+
+	value, err := c.Encode(Session{
+		User:      user.Descriptor{ID: uuid.Must(uuid.NewV4())},
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := c.Decode(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Until(s.ExpiresAt) > time.Minute {
+		t.Errorf("expiration %s not limited by idle timeout", s.ExpiresAt)
+	}
+
+	store.setLastAccess(value, time.Now().Add(-2*time.Minute))
+	if _, err = c.Decode(value); !errors.Is(err, ErrExpired) {
+		t.Fatalf("expected ErrExpired for idle session, got %v", err)
+	}
+	if _, err = store.Get(value); !errors.Is(err, ErrNotFound) {
+		t.Error("expired session kept in store")
+	}
+}
+
+func TestStoreCodecRenew(t *testing.T) {
+	store := newMapStore()
+	c := NewStoreCodec(store, StoreConfig{IdleTimeout: 10 * time.Minute})
+
+	value, err := c.Encode(Session{
+		User:      user.Descriptor{ID: uuid.Must(uuid.NewV4())},
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// writes throttled
+	if err = c.Renew(value); err != nil || store.touches != 0 {
+		t.Fatalf("fresh session touched: %d, %v", store.touches, err)
+	}
+
+	past := time.Now().Add(-2 * time.Minute)
+	store.setLastAccess(value, past)
+	if err = c.Renew(value); err != nil {
+		t.Fatal(err)
+	}
+	if !store.lastAccess(value).After(past) {
+		t.Error("last access not updated")
+	}
+
+	if err = c.Revoke(value); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Renew(value); err != nil {
+		t.Errorf("renewal of revoked session failed: %v", err)
+	}
+	if err = c.Revoke(value); err != nil {
+		t.Errorf("second revocation failed: %v", err)
+	}
+	if _, err = c.Decode(value); !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected ErrInvalid for revoked session, got %v", err)
+	}
+}
+
+func TestStoreCodecStoreUnavailable(t *testing.T) {
+	store := newMapStore()
+	c := NewStoreCodec(store, StoreConfig{})
+
+	value, err := c.Encode(Session{
+		User:      user.Descriptor{ID: uuid.Must(uuid.NewV4())},
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store.unavailable = true
+	_, err = c.Decode(value)
+	if !errors.Is(err, errStoreUnavailable) || errors.Is(err, ErrInvalid) || errors.Is(err, ErrExpired) {
+		t.Errorf("store failure reported as %v", err)
+	}
+}
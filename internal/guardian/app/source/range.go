@@ -0,0 +1,22 @@
+package source
+
+import (
+	"fmt"
+)
+
+// Range points to definition of entity in config source
+type Range struct {
+	Filename string
+	Start    Pos
+	End      Pos
+}
+
+type Pos struct {
+	Line   int
+	Column int
+	Byte   int
+}
+
+func (r Range) String() string {
+	return fmt.Sprintf("%s:%d", r.Filename, r.Start.Line)
+}
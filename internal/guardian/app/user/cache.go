@@ -0,0 +1,224 @@
+package user
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultCacheTTL        = time.Minute
+	DefaultCacheMaxEntries = 1000
+	DefaultCacheTimeout    = 10 * time.Second
+)
+
+type CacheConfig struct {
+	// TTL of found users
+	TTL time.Duration
+	// NegativeTTL of unknown users, not found users aren't cached when zero
+	NegativeTTL time.Duration
+	// MaxEntries bounds cache size, least recently used entries evicted first
+	MaxEntries int
+	// StaleTTL is how long after expiration user still served when provider unavailable, disabled when zero
+	StaleTTL time.Duration
+	// Timeout bounds lookup of provider, lookup shared by concurrent requests, so it isn't canceled with request started it
+	Timeout time.Duration
+}
+
+// NewCachingProvider caches users returned by provider.
+// Concurrent lookups of same token collapsed into single call of provider
+func NewCachingProvider(provider Provider, config CacheConfig) Provider {
+	if config.TTL <= 0 {
+		config.TTL = DefaultCacheTTL
+	}
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = DefaultCacheMaxEntries
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultCacheTimeout
+	}
+
+	p := &cachingProvider{
+		provider: provider,
+		config:   config,
+		entries:  map[Token]*list.Element{},
+		lru:      list.New(),
+		calls:    map[Token]*call{},
+	}
+	if authenticator, ok := provider.(Authenticator); ok {
+		return &cachingAuthenticator{cachingProvider: p, authenticator: authenticator}
+	}
+	return p
+}
+
+// cachingAuthenticator caches users, credentials always checked by authenticator
+type cachingAuthenticator struct {
+	*cachingProvider
+	authenticator Authenticator
+}
+
+func (a *cachingAuthenticator) Authenticate(ctx context.Context, username, password string) (Descriptor, error) {
+	return a.authenticator.Authenticate(ctx, username, password)
+}
+
+type cachingProvider struct {
+	provider Provider
+	config   CacheConfig
+
+	mu      sync.Mutex
+	entries map[Token]*list.Element
+	// lru holds *cacheEntry, most recently used in front
+	lru   *list.List
+	calls map[Token]*call
+}
+
+type cacheEntry struct {
+	token      Token
+	descriptor Descriptor
+	// err is ErrUserNotFound for negative entries
+	err       error
+	expiresAt time.Time
+}
+
+// call is in-flight lookup of provider
+type call struct {
+	done       chan struct{}
+	descriptor Descriptor
+	err        error
+}
+
+func (p *cachingProvider) User(ctx context.Context, token Token) (Descriptor, error) {
+	p.mu.Lock()
+	entry, ok := p.get(token)
+	if ok && time.Now().Before(entry.expiresAt) {
+		p.mu.Unlock()
+		return entry.descriptor, entry.err
+	}
+
+	c, inFlight := p.calls[token]
+	if !inFlight {
+		c = &call{done: make(chan struct{})}
+		p.calls[token] = c
+	}
+	p.mu.Unlock()
+
+	if inFlight {
+		select {
+		case <-c.done:
+			return c.descriptor, c.err
+		case <-ctx.Done():
+			return Descriptor{}, errors.Wrap(ErrProviderUnavailable, ctx.Err().Error())
+		}
+	}
+
+	// waiters released even when provider panics, they get error set before lookup
+	c.err = errors.Wrap(ErrProviderUnavailable, "user lookup aborted")
+	defer func() {
+		p.mu.Lock()
+		delete(p.calls, token)
+		p.mu.Unlock()
+		close(c.done)
+	}()
+
+	// client of leader may disconnect, so lookup detached from its cancellation
+	lookupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), p.config.Timeout)
+	defer cancel()
+
+	descriptor, err := p.provider.User(lookupCtx, token)
+
+	p.mu.Lock()
+	c.descriptor, c.err = p.store(token, entry, descriptor, err)
+	p.mu.Unlock()
+
+	return c.descriptor, c.err
+}
+
+// store caches result of provider, returns stale entry when provider unavailable
+func (p *cachingProvider) store(token Token, stale *cacheEntry, descriptor Descriptor, err error) (Descriptor, error) {
+	switch cause := errors.Cause(err); {
+	case err == nil:
+		p.put(token, descriptor, nil, p.config.TTL)
+	case cause == ErrUserNotFound && p.config.NegativeTTL > 0:
+		p.put(token, Descriptor{}, err, p.config.NegativeTTL)
+	case cause == ErrUserNotFound:
+		// user removed, it must not be served as stale
+		p.remove(token)
+	case cause == ErrProviderUnavailable && stale != nil && stale.err == nil &&
+		time.Now().Before(stale.expiresAt.Add(p.config.StaleTTL)):
+		return stale.descriptor, nil
+	}
+	return descriptor, err
+}
+
+func (p *cachingProvider) get(token Token) (*cacheEntry, bool) {
+	e, ok := p.entries[token]
+	if !ok {
+		return nil, false
+	}
+	p.lru.MoveToFront(e)
+	return e.Value.(*cacheEntry), true
+}
+
+func (p *cachingProvider) put(token Token, descriptor Descriptor, err error, ttl time.Duration) {
+	entry := &cacheEntry{
+		token:      token,
+		descriptor: descriptor,
+		err:        err,
+		expiresAt:  time.Now().Add(ttl),
+	}
+
+	if e, ok := p.entries[token]; ok {
+		e.Value = entry
+		p.lru.MoveToFront(e)
+		return
+	}
+
+	p.entries[token] = p.lru.PushFront(entry)
+	for p.lru.Len() > p.config.MaxEntries {
+		oldest := p.lru.Back()
+		p.lru.Remove(oldest)
+		delete(p.entries, oldest.Value.(*cacheEntry).token)
+	}
+}
+
+func (p *cachingProvider) remove(token Token) {
+	if e, ok := p.entries[token]; ok {
+		p.lru.Remove(e)
+		delete(p.entries, token)
+	}
+}
+
+// Close closes provider holding resources like connections
+func (p *cachingProvider) Close() error {
+	if c, ok := p.provider.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (p *cachingProvider) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(p.provider)
+	if err != nil {
+		return nil, err
+	}
+
+	res := map[string]any{}
+	err = json.Unmarshal(data, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	res["cache"] = map[string]any{
+		"ttl":         p.config.TTL.String(),
+		"negativeTTL": p.config.NegativeTTL.String(),
+		"maxEntries":  p.config.MaxEntries,
+		"staleTTL":    p.config.StaleTTL.String(),
+		"timeout":     p.config.Timeout.String(),
+	}
+	return json.Marshal(res)
+}
@@ -0,0 +1,134 @@
+package user
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/pkg/errors"
+)
+
+type providerFunc func(ctx context.Context, token Token) (Descriptor, error)
+
+func (f providerFunc) User(ctx context.Context, token Token) (Descriptor, error) {
+	return f(ctx, token)
+}
+
+func TestCachingProviderCaches(t *testing.T) {
+	var calls atomic.Int32
+	var unavailable atomic.Bool
+	p := NewCachingProvider(providerFunc(func(_ context.Context, token Token) (Descriptor, error) {
+		calls.Add(1)
+		if unavailable.Load() {
+			return Descriptor{}, errors.WithStack(ErrProviderUnavailable)
+		}
+		return Descriptor{ID: token.ID, Username: "alice"}, nil
+	}), CacheConfig{TTL: 20 * time.Millisecond, StaleTTL: time.Hour})
+
+	token := Token{ID: uuid.Must(uuid.NewV4())}
+	for i := 0; i < 3; i++ {
+		d, err := p.User(context.Background(), token)
+		if err != nil || d.Username != "alice" {
+			t.Fatalf("unexpected result %+v, %v", d, err)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected 1 call of provider, got %d", n)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	unavailable.Store(true)
+	d, err := p.User(context.Background(), token)
+	if err != nil || d.Username != "alice" {
+		t.Errorf("expected stale user, got %+v, %v", d, err)
+	}
+}
+
+func TestCachingProviderLeaderCanceled(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var once sync.Once
+	p := NewCachingProvider(providerFunc(func(ctx context.Context, token Token) (Descriptor, error) {
+		once.Do(func() { close(started) })
+		select {
+		case <-release:
+		case <-ctx.Done():
+			return Descriptor{}, errors.Wrap(ErrProviderUnavailable, ctx.Err().Error())
+		}
+		return Descriptor{ID: token.ID, Username: "alice"}, nil
+	}), CacheConfig{})
+
+	token := Token{ID: uuid.Must(uuid.NewV4())}
+	leaderCtx, cancel := context.WithCancel(context.Background())
+
+	leaderDone := make(chan struct{})
+	go func() {
+		defer close(leaderDone)
+		_, _ = p.User(leaderCtx, token)
+	}()
+	<-started
+
+	waiterRes := make(chan error, 1)
+	go func() {
+		d, err := p.User(context.Background(), token)
+		if err == nil && d.Username != "alice" {
+			err = errors.Errorf("unexpected user %+v", d)
+		}
+		waiterRes <- err
+	}()
+
+	// client of leader disconnected, lookup must continue for waiter
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	select {
+	case err := <-waiterRes:
+		if err != nil {
+			t.Errorf("waiter failed: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiter hangs")
+	}
+	<-leaderDone
+}
+
+func TestCachingProviderPanic(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var once sync.Once
+	p := NewCachingProvider(providerFunc(func(context.Context, Token) (Descriptor, error) {
+		once.Do(func() { close(started) })
+		<-release
+		panic("provider failed")
+	}), CacheConfig{})
+
+	token := Token{ID: uuid.Must(uuid.NewV4())}
+	go func() {
+		defer func() {
+			_ = recover()
+		}()
+		_, _ = p.User(context.Background(), token)
+	}()
+	<-started
+
+	waiterRes := make(chan error, 1)
+	go func() {
+		_, err := p.User(context.Background(), token)
+		waiterRes <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	select {
+	case err := <-waiterRes:
+		if errors.Cause(err) != ErrProviderUnavailable {
+			t.Errorf("expected ErrProviderUnavailable, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiter hangs after provider panic")
+	}
+}
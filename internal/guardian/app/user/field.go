@@ -0,0 +1,58 @@
+package user
+
+import (
+	"strings"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/pkg/errors"
+)
+
+// Field of Descriptor: id, username, email, displayName, groups or attributes.<name>
+type Field string
+
+const (
+	FieldID          = Field("id")
+	FieldUsername    = Field("username")
+	FieldEmail       = Field("email")
+	FieldDisplayName = Field("displayName")
+	FieldGroups      = Field("groups")
+
+	attributeFieldPrefix = "attributes."
+)
+
+func ParseField(s string) (Field, error) {
+	switch f := Field(s); f {
+	case FieldID, FieldUsername, FieldEmail, FieldDisplayName, FieldGroups:
+		return f, nil
+	}
+
+	if name, ok := strings.CutPrefix(s, attributeFieldPrefix); ok && name != "" {
+		return Field(s), nil
+	}
+	return "", errors.Errorf("unknown user field %s, expected id, username, email, displayName, groups or %s<name>", s, attributeFieldPrefix)
+}
+
+// Field returns value of descriptor field formatted as string, groups joined with comma.
+// Empty string returned for unset fields
+func (d Descriptor) Field(f Field) string {
+	switch f {
+	case FieldID:
+		if d.ID == uuid.Nil {
+			return ""
+		}
+		return d.ID.String()
+	case FieldUsername:
+		return d.Username
+	case FieldEmail:
+		return d.Email
+	case FieldDisplayName:
+		return d.DisplayName
+	case FieldGroups:
+		return strings.Join(d.Groups, ",")
+	}
+
+	if name, ok := strings.CutPrefix(string(f), attributeFieldPrefix); ok {
+		return d.Attributes[name]
+	}
+	return ""
+}
@@ -2,10 +2,20 @@ package user
 
 import (
 	"context"
+	stderrors "errors"
 
 	"github.com/gofrs/uuid/v5"
 )
 
+var (
+	// ErrUserNotFound returned by Provider when there is no user for token
+	ErrUserNotFound = stderrors.New("user not found")
+	// ErrProviderUnavailable returned by Provider when users source can't be reached
+	ErrProviderUnavailable = stderrors.New("user provider unavailable")
+	// ErrInvalidCredentials returned by Authenticator when user unknown or password doesn't match
+	ErrInvalidCredentials = stderrors.New("invalid credentials")
+)
+
 type Token struct {
 	ID uuid.UUID
 }
@@ -14,7 +24,19 @@ type Provider interface {
 	User(ctx context.Context, token Token) (Descriptor, error)
 }
 
+// Authenticator is Provider checking user credentials, like by LDAP bind
+type Authenticator interface {
+	Provider
+	Authenticate(ctx context.Context, username, password string) (Descriptor, error)
+}
+
 type Descriptor struct {
-	ID       uuid.UUID
-	Username string
+	ID          uuid.UUID
+	Username    string
+	Email       string
+	DisplayName string
+	// Groups user is member of, also used for roles
+	Groups []string
+	// Attributes holds provider specific user data
+	Attributes map[string]string
 }
@@ -0,0 +1,71 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/gorilla/mux"
+
+	"guardian/internal/common/infrastructure/logger"
+)
+
+// UserSessionsPath is path of sessions of user, DELETE revokes them
+const UserSessionsPath = "/sessions/users/{id}"
+
+// Runtime is running proxy operated by admin
+type Runtime interface {
+	// AdminToken returns token of actual config, admin requests rejected when it's empty
+	AdminToken() string
+	// RevokeUserSessions deletes every session of user from session stores and returns number of deleted sessions
+	RevokeUserSessions(userID uuid.UUID) (int, error)
+}
+
+func RegisterHandlers(router *mux.Router, rt Runtime, l logger.Logger) {
+	h := &handler{rt: rt, logger: l}
+	router.Use(h.authenticate)
+	router.HandleFunc(UserSessionsPath, h.revokeUserSessions).Methods(http.MethodDelete)
+}
+
+type handler struct {
+	rt     Runtime
+	logger logger.Logger
+}
+
+func (h *handler) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := h.rt.AdminToken()
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]any{"error": "unauthorized"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *handler) revokeUserSessions(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.FromString(mux.Vars(r)["id"])
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid user id"})
+		return
+	}
+
+	n, err := h.rt.RevokeUserSessions(userID)
+	if err != nil {
+		h.logger.Errorf(err, "failed to revoke sessions of user %s", userID)
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "failed to revoke sessions", "revoked": n})
+		return
+	}
+
+	h.logger.Infof("revoked %d sessions of user %s", n, userID)
+	writeJSON(w, http.StatusOK, map[string]any{"revoked": n})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,131 @@
+package apikey
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"guardian/internal/common/infrastructure/filewatch"
+	"guardian/internal/guardian/app/proxy/downstream"
+	"guardian/internal/guardian/app/user"
+)
+
+const DefaultHeader = "X-API-Key"
+
+type Config struct {
+	// Header holds key, DefaultHeader used when empty
+	Header string
+	// Query is name of query parameter holding key, checked when request has no Header
+	Query string
+
+	// Downstream is ID of downstream authorizer serves, matched with Key.Downstreams
+	Downstream string
+
+	Keys *filewatch.File[[]Key]
+}
+
+// NewAuthorizer returns authorizer of callers by API keys from file
+func NewAuthorizer(config Config) downstream.Authorizer {
+	if config.Header == "" {
+		config.Header = DefaultHeader
+	}
+	return &authorizer{config: config}
+}
+
+type authorizer struct {
+	config Config
+}
+
+func (a *authorizer) Auth(_ context.Context, r http.Request) (user.Descriptor, error) {
+	raw, ok := a.key(r)
+	if !ok {
+		return user.Descriptor{}, errors.WithStack(downstream.ErrAuthDataNotFound)
+	}
+
+	key, ok := find(a.config.Keys.Get(), sha256.Sum256([]byte(raw)))
+	if !ok {
+		return user.Descriptor{}, errors.Wrap(downstream.ErrAuthDataInvalid, "apikey: unknown key")
+	}
+
+	if key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt) {
+		return user.Descriptor{}, errors.Wrapf(downstream.ErrAuthDataInvalid, "apikey: key %s expired", key.Name)
+	}
+
+	if len(key.Downstreams) != 0 && !slices.Contains(key.Downstreams, a.config.Downstream) {
+		return user.Descriptor{}, errors.Wrapf(downstream.ErrAuthDenied, "apikey: key %s not allowed for downstream %s", key.Name, a.config.Downstream)
+	}
+
+	return key.Owner.Descriptor(), nil
+}
+
+func (a *authorizer) key(r http.Request) (string, bool) {
+	if v := r.Header.Get(a.config.Header); v != "" {
+		return v, true
+	}
+	if a.config.Query != "" {
+		if v := r.URL.Query().Get(a.config.Query); v != "" {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func (a *authorizer) StripCredentials(u *url.URL, header http.Header) {
+	header.Del(a.config.Header)
+	if a.config.Query == "" {
+		return
+	}
+
+	u.RawQuery = withoutParam(u.RawQuery, a.config.Query)
+}
+
+// withoutParam removes pairs of param from raw query, other pairs kept byte for byte in original order,
+// so upstreams relying on order or encoding of query, like signed URLs, get it intact
+func withoutParam(rawQuery, param string) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	pairs := strings.Split(rawQuery, "&")
+	kept := pairs[:0]
+	for _, pair := range pairs {
+		name, _, _ := strings.Cut(pair, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil && unescaped == param {
+			continue
+		}
+		kept = append(kept, pair)
+	}
+	return strings.Join(kept, "&")
+}
+
+// find compares hash with every key, so lookup time doesn't depend on which key matched
+func find(keys []Key, hash [sha256.Size]byte) (Key, bool) {
+	var found Key
+	var ok bool
+	for _, k := range keys {
+		if subtle.ConstantTimeCompare(k.hash[:], hash[:]) == 1 {
+			found, ok = k, true
+		}
+	}
+	return found, ok
+}
+
+func (a *authorizer) MarshalJSON() ([]byte, error) {
+	res := map[string]any{
+		"type":   "apikey",
+		"file":   a.config.Keys.Path(),
+		"header": a.config.Header,
+	}
+	if a.config.Query != "" {
+		res["query"] = a.config.Query
+	}
+	return json.Marshal(res)
+}
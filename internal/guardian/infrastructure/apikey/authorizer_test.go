@@ -0,0 +1,115 @@
+package apikey
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"guardian/internal/common/infrastructure/filewatch"
+	"guardian/internal/guardian/app/proxy/downstream"
+)
+
+func TestAuthorizer(t *testing.T) {
+	key, hash, err := Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, expiredHash, err := Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	billing, billingHash, err := Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "keys.json")
+	err = os.WriteFile(path, []byte(fmt.Sprintf(`[
+		{"name": "ci", "hash": %q, "owner": {"username": "ci"}},
+		{"name": "old", "hash": "sha256:%s", "owner": {"username": "ci"}, "expiresAt": %q},
+		{"name": "billing", "hash": %q, "owner": {"username": "billing"}, "downstreams": ["billing"]}
+	]`, hash, expiredHash, time.Now().Add(-time.Hour).Format(time.RFC3339), billingHash)), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := filewatch.NewFile(path, filewatch.DefaultInterval, ParseKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := NewAuthorizer(Config{Query: "api_key", Downstream: "app", Keys: keys})
+
+	tests := []struct {
+		name   string
+		header string
+		query  string
+		err    error
+	}{
+		{"header", key, "", nil},
+		{"query", "", key, nil},
+		{"missing", "", "", downstream.ErrAuthDataNotFound},
+		{"unknown", "unknown", "", downstream.ErrAuthDataInvalid},
+		{"expired", expired, "", downstream.ErrAuthDataInvalid},
+		{"other downstream", billing, "", downstream.ErrAuthDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := http.Request{Header: http.Header{}, URL: &url.URL{Path: "/"}}
+			if tt.header != "" {
+				r.Header.Set(DefaultHeader, tt.header)
+			}
+			if tt.query != "" {
+				r.URL.RawQuery = url.Values{"api_key": {tt.query}}.Encode()
+			}
+
+			d, err := a.Auth(context.Background(), r)
+			if errors.Cause(err) != tt.err {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+			if err == nil && d.Username != "ci" {
+				t.Errorf("unexpected user %+v", d)
+			}
+		})
+	}
+}
+
+func TestAuthorizerStripCredentials(t *testing.T) {
+	a := NewAuthorizer(Config{Query: "api_key"}).(downstream.CredentialsStripper)
+
+	u := &url.URL{Path: "/items", RawQuery: "page=2&api_key=secret"}
+	header := http.Header{DefaultHeader: {"secret"}, "Accept": {"application/json"}}
+	a.StripCredentials(u, header)
+
+	if u.RawQuery != "page=2" {
+		t.Errorf("key left in query %q", u.RawQuery)
+	}
+	if header.Get(DefaultHeader) != "" || header.Get("Accept") == "" {
+		t.Errorf("unexpected header %v", header)
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"b=1&a=2", "b=1&a=2"},
+		// order and encoding of other parameters kept, like in signed URLs
+		{"z=%7e&api_key=secret&a=x+y&sig=AB%2Fc", "z=%7e&a=x+y&sig=AB%2Fc"},
+		{"api_key=1&api_key=2", ""},
+		{"api%5Fkey=secret&flag", "flag"},
+		{"api_key_id=7&xapi_key=1", "api_key_id=7&xapi_key=1"},
+	}
+	for _, tt := range tests {
+		u = &url.URL{Path: "/items", RawQuery: tt.query}
+		a.StripCredentials(u, http.Header{})
+		if u.RawQuery != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.query, u.RawQuery, tt.want)
+		}
+	}
+}
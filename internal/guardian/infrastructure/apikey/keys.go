@@ -0,0 +1,101 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/pkg/errors"
+
+	"guardian/internal/guardian/app/user"
+)
+
+// Key is API key stored by its hash, so store file doesn't expose keys
+type Key struct {
+	// Name identifies key in logs
+	Name string `json:"name"`
+	// Hash is hex encoded SHA-256 of key
+	Hash  string `json:"hash"`
+	Owner Owner  `json:"owner"`
+	// ExpiresAt is optional expiration time of key
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// Downstreams key allowed to access, all downstreams of authorizer allowed when empty
+	Downstreams []string `json:"downstreams,omitempty"`
+
+	hash [sha256.Size]byte
+}
+
+// Owner describes service or user key issued to
+type Owner struct {
+	// ID derived from username when omitted
+	ID          uuid.UUID         `json:"id"`
+	Username    string            `json:"username"`
+	Email       string            `json:"email,omitempty"`
+	DisplayName string            `json:"displayName,omitempty"`
+	Groups      []string          `json:"groups,omitempty"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+}
+
+func (o Owner) Descriptor() user.Descriptor {
+	return user.Descriptor{
+		ID:          o.ID,
+		Username:    o.Username,
+		Email:       o.Email,
+		DisplayName: o.DisplayName,
+		Groups:      o.Groups,
+		Attributes:  o.Attributes,
+	}
+}
+
+// ParseKeys parses JSON array of keys
+func ParseKeys(data []byte) ([]Key, error) {
+	var keys []Key
+	err := json.Unmarshal(data, &keys)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	names := map[string]struct{}{}
+	for i := range keys {
+		k := &keys[i]
+		if k.Name == "" {
+			return nil, errors.Errorf("key %d: name required", i)
+		}
+		if _, ok := names[k.Name]; ok {
+			return nil, errors.Errorf("key %s defined twice", k.Name)
+		}
+		names[k.Name] = struct{}{}
+
+		hash, err2 := hex.DecodeString(strings.TrimPrefix(k.Hash, "sha256:"))
+		if err2 != nil || len(hash) != sha256.Size {
+			return nil, errors.Errorf("key %s: hash must be hex encoded SHA-256", k.Name)
+		}
+		copy(k.hash[:], hash)
+
+		if k.Owner.Username == "" {
+			return nil, errors.Errorf("key %s: owner username required", k.Name)
+		}
+		if k.Owner.ID == uuid.Nil {
+			k.Owner.ID = uuid.NewV5(uuid.NamespaceURL, "apikey#"+k.Owner.Username)
+		}
+	}
+	return keys, nil
+}
+
+// Generate returns new random key and its hash
+func Generate() (key, hash string, err error) {
+	b := make([]byte, 32)
+	_, err = rand.Read(b)
+	if err != nil {
+		return "", "", errors.WithStack(err)
+	}
+
+	key = base64.RawURLEncoding.EncodeToString(b)
+	sum := sha256.Sum256([]byte(key))
+	return key, hex.EncodeToString(sum[:]), nil
+}
@@ -1,22 +1,160 @@
 package config
 
+import (
+	"github.com/hashicorp/hcl/v2"
+)
+
 type appConfig struct {
-	Healthcheck  healthcheck   `hcl:"healthcheck,block"`
-	UserProvider *userProvider `hcl:"userprovider,block"`
-	TCPProxies   []tcpProxy    `hcl:"tcpproxy,block"`
-	HTTPProxies  []httpProxy   `hcl:"httpproxy,block"`
+	Includes      []include      `hcl:"include,block"`
+	Healthcheck   *healthcheck   `hcl:"healthcheck,block"`
+	Admin         *admin         `hcl:"admin,block"`
+	UserProviders []userProvider `hcl:"userprovider,block"`
+	SessionStores []sessionStore `hcl:"sessionstore,block"`
+	TCPProxies    []tcpProxy     `hcl:"tcpproxy,block"`
+	HTTPProxies   []httpProxy    `hcl:"httpproxy,block"`
+}
+
+// include loads config from file, directory or glob relative to including file
+type include struct {
+	Path string `hcl:"path"`
 }
 
 type healthcheck struct {
-	Address string `hcl:"address"`
-	Path    string `hcl:"path"`
+	Body    hcl.Body `hcl:",body"`
+	Address string   `hcl:"address"`
+	Path    string   `hcl:"path"`
+}
+
+// admin serves operations like revoking sessions, requests must have Authorization: Bearer <token>
+type admin struct {
+	Body    hcl.Body `hcl:",body"`
+	Address string   `hcl:"address"`
+	Token   string   `hcl:"token"`
 }
 
 const (
-	ldapUserProviderType = "ldap"
+	memorySessionStoreType = "memory"
+	fileSessionStoreType   = "file"
+)
+
+// sessionStore keeps sessions on server, session cookies hold only opaque session ID
+type sessionStore struct {
+	Type    string   `hcl:"type,label"`
+	ID      string   `hcl:"id,label"`
+	Body    hcl.Body `hcl:",body"`
+	Payload hcl.Body `hcl:",remain"`
+}
+
+type memorySessionStore struct{}
+
+type fileSessionStore struct {
+	// Path of log file, sessions restored from it on start
+	Path string `hcl:"path"`
+}
+
+const (
+	ldapUserProviderType   = "ldap"
+	staticUserProviderType = "static"
+	httpUserProviderType   = "http"
+	// htpasswdUserProviderType serves users of htpasswd file, used by login portal
+	htpasswdUserProviderType = "htpasswd"
 )
 
 type userProvider struct {
-	Type    string `hcl:"type,label"`
-	Address string `hcl:"address"`
+	Type    string             `hcl:"type,label"`
+	ID      string             `hcl:"id,label"`
+	Cache   *userProviderCache `hcl:"cache,block"`
+	Body    hcl.Body           `hcl:",body"`
+	Payload hcl.Body           `hcl:",remain"`
+}
+
+// userProviderCache caches users returned by provider
+type userProviderCache struct {
+	TTL         string `hcl:"ttl,optional"`
+	NegativeTTL string `hcl:"negativeTTL,optional"`
+	MaxEntries  int    `hcl:"maxEntries,optional"`
+	// StaleTTL is how long expired user served when provider unavailable
+	StaleTTL string `hcl:"staleTTL,optional"`
+	// Timeout bounds lookup shared by concurrent requests, it isn't canceled with request started it
+	Timeout string `hcl:"timeout,optional"`
+}
+
+type ldapUserProvider struct {
+	Address  string `hcl:"address"`
+	StartTLS bool   `hcl:"startTLS,optional"`
+	TLS      *tls   `hcl:"tls,block"`
+
+	BindDN       string `hcl:"bindDN,optional"`
+	BindPassword string `hcl:"bindPassword,optional"`
+
+	BaseDN string `hcl:"baseDN"`
+	Filter string `hcl:"filter,optional"`
+	// LoginFilter searches user by {username} on login
+	LoginFilter string          `hcl:"loginFilter,optional"`
+	Attributes  *ldapAttributes `hcl:"attributes,block"`
+
+	PoolSize int    `hcl:"poolSize,optional"`
+	Timeout  string `hcl:"timeout,optional"`
+}
+
+type ldapAttributes struct {
+	ID          string `hcl:"id,optional"`
+	Username    string `hcl:"username,optional"`
+	Email       string `hcl:"email,optional"`
+	DisplayName string `hcl:"displayName,optional"`
+	Groups      string `hcl:"groups,optional"`
+	// Extra maps user attributes to ldap attributes
+	Extra map[string]string `hcl:"extra,optional"`
+}
+
+type staticUserProvider struct {
+	// File with users in JSON or CSV format
+	File  string       `hcl:"file,optional"`
+	Users []staticUser `hcl:"user,block"`
+}
+
+type staticUser struct {
+	ID          string            `hcl:"id,label"`
+	Username    string            `hcl:"username"`
+	Email       string            `hcl:"email,optional"`
+	DisplayName string            `hcl:"displayName,optional"`
+	Groups      []string          `hcl:"groups,optional"`
+	Attributes  map[string]string `hcl:"attributes,optional"`
+	// PasswordHash is bcrypt or {SHA} hash checked on login
+	PasswordHash string `hcl:"passwordHash,optional"`
+}
+
+type htpasswdUserProvider struct {
+	File string `hcl:"file"`
+}
+
+type httpUserProvider struct {
+	// URL with {id} placeholder
+	URL       string            `hcl:"url"`
+	Headers   map[string]string `hcl:"headers,optional"`
+	BasicAuth *basicAuth        `hcl:"basicAuth,block"`
+	TLS       *tls              `hcl:"tls,block"`
+	Timeout   string            `hcl:"timeout,optional"`
+	Mapping   *httpUserMapping  `hcl:"mapping,block"`
+}
+
+type basicAuth struct {
+	Username string `hcl:"username"`
+	Password string `hcl:"password"`
+}
+
+// httpUserMapping holds JSONPath-style selectors of user fields in response
+type httpUserMapping struct {
+	ID          string            `hcl:"id,optional"`
+	Username    string            `hcl:"username,optional"`
+	Email       string            `hcl:"email,optional"`
+	DisplayName string            `hcl:"displayName,optional"`
+	Groups      string            `hcl:"groups,optional"`
+	Attributes  map[string]string `hcl:"attributes,optional"`
+}
+
+// tls configures client connections to external services
+type tls struct {
+	CAFile             string `hcl:"caFile,optional"`
+	InsecureSkipVerify bool   `hcl:"insecureSkipVerify,optional"`
 }
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"net/url"
+
+	"github.com/hashicorp/hcl/v2"
+
+	appdownstream "guardian/internal/guardian/app/proxy/downstream"
+	"guardian/internal/guardian/infrastructure/forwardauth"
+)
+
+func mapForwardAuthorizer(auth forwardDownstreamAuthorizer, body hcl.Body) (appdownstream.Authorizer, error) {
+	u, err := url.Parse(auth.URL)
+	if err != nil || !u.IsAbs() {
+		return nil, diagnostic(body, "forward authorizer url %s must be absolute", auth.URL)
+	}
+
+	timeout, err := parseDuration(auth.Timeout, body)
+	if err != nil {
+		return nil, err
+	}
+
+	transport, err := mapTransport(auth.TLS, body)
+	if err != nil {
+		return nil, err
+	}
+
+	var mapping forwardauth.Mapping
+	if m := auth.Mapping; m != nil {
+		mapping = forwardauth.Mapping{
+			ID:          m.ID,
+			Username:    m.Username,
+			Email:       m.Email,
+			DisplayName: m.DisplayName,
+			Groups:      m.Groups,
+			Attributes:  m.Attributes,
+		}
+	}
+
+	return forwardauth.NewAuthorizer(forwardauth.Config{
+		URL:             auth.URL,
+		Headers:         auth.Headers,
+		ResponseHeaders: auth.ResponseHeaders,
+		PassBody:        auth.PassBody,
+		Mapping:         mapping,
+		Timeout:         timeout,
+		Transport:       transport,
+	}), nil
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+)
+
+// evalContext returns context with functions available in config.
+// Relative paths passed to file() resolved from baseDir
+func evalContext(baseDir string) *hcl.EvalContext {
+	return &hcl.EvalContext{
+		Functions: map[string]function.Function{
+			"env":       envFunc,
+			"file":      makeFileFunc(baseDir),
+			"trimspace": stdlib.TrimSpaceFunc,
+			"tostring":  stdlib.MakeToFunc(cty.String),
+			"tonumber":  stdlib.MakeToFunc(cty.Number),
+			"tobool":    stdlib.MakeToFunc(cty.Bool),
+		},
+	}
+}
+
+// envFunc returns value of environment variable: env("NAME") or env("NAME", default).
+// When default passed, value converted to type of default, so env("PORT", 8080) returns number
+var envFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{
+			Name: "name",
+			Type: cty.String,
+		},
+	},
+	VarParam: &function.Parameter{
+		Name:             "default",
+		Type:             cty.DynamicPseudoType,
+		AllowDynamicType: true,
+		AllowNull:        true,
+	},
+	Type: func(args []cty.Value) (cty.Type, error) {
+		switch len(args) {
+		case 1:
+			return cty.String, nil
+		case 2:
+			return args[1].Type(), nil
+		default:
+			return cty.NilType, function.NewArgErrorf(2, "env accepts only one default value")
+		}
+	},
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		name := args[0].AsString()
+
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			if len(args) == 2 {
+				return args[1], nil
+			}
+			return cty.NilVal, function.NewArgErrorf(0, "environment variable %s is not set", name)
+		}
+
+		v, err := convert.Convert(cty.StringVal(value), retType)
+		if err != nil {
+			return cty.NilVal, function.NewArgErrorf(0, "environment variable %s: %s", name, err)
+		}
+		return v, nil
+	},
+})
+
+// makeFileFunc returns function reading file content: file("path")
+func makeFileFunc(baseDir string) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{
+				Name: "path",
+				Type: cty.String,
+			},
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+			p := args[0].AsString()
+			if !filepath.IsAbs(p) {
+				p = filepath.Join(baseDir, p)
+			}
+
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return cty.NilVal, function.NewArgErrorf(0, "failed to read file: %s", err)
+			}
+			return cty.StringVal(string(data)), nil
+		},
+	})
+}
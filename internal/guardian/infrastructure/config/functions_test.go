@@ -0,0 +1,103 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestFunctions(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"secrets/token": "s3cret\n"})
+
+	t.Setenv("GUARDIAN_TEST_HOST", "example.com")
+	t.Setenv("GUARDIAN_TEST_PORT", "9090")
+	t.Setenv("GUARDIAN_TEST_DEBUG", "true")
+	t.Setenv("GUARDIAN_TEST_WORD", "abc")
+
+	tests := []struct {
+		expr string
+		want cty.Value
+		// err holds substring of error, want compared when empty
+		err string
+	}{
+		{expr: `env("GUARDIAN_TEST_HOST")`, want: cty.StringVal("example.com")},
+		{expr: `env("GUARDIAN_TEST_HOST", "localhost")`, want: cty.StringVal("example.com")},
+		{expr: `env("GUARDIAN_TEST_MISSING", "localhost")`, want: cty.StringVal("localhost")},
+		// value converted to type of default
+		{expr: `env("GUARDIAN_TEST_PORT", 8080)`, want: cty.NumberIntVal(9090)},
+		{expr: `env("GUARDIAN_TEST_MISSING", 8080)`, want: cty.NumberIntVal(8080)},
+		{expr: `env("GUARDIAN_TEST_DEBUG", false)`, want: cty.True},
+		{expr: `env("GUARDIAN_TEST_WORD", 8080)`, err: "environment variable GUARDIAN_TEST_WORD"},
+		{expr: `env("GUARDIAN_TEST_MISSING")`, err: "environment variable GUARDIAN_TEST_MISSING is not set"},
+		{expr: `env("GUARDIAN_TEST_MISSING", 1, 2)`, err: "only one default value"},
+		{expr: `file("secrets/token")`, want: cty.StringVal("s3cret\n")},
+		{expr: `file("` + filepath.ToSlash(filepath.Join(dir, "secrets", "token")) + `")`, want: cty.StringVal("s3cret\n")},
+		{expr: `file("secrets/missing")`, err: "failed to read file"},
+		{expr: `trimspace(file("secrets/token"))`, want: cty.StringVal("s3cret")},
+		{expr: `trimspace("  a b \n")`, want: cty.StringVal("a b")},
+		{expr: `tostring(42)`, want: cty.StringVal("42")},
+		{expr: `tonumber("42")`, want: cty.NumberIntVal(42)},
+		{expr: `tonumber(env("GUARDIAN_TEST_PORT"))`, want: cty.NumberIntVal(9090)},
+		{expr: `tonumber("abc")`, err: "cannot convert"},
+		{expr: `tobool("true")`, want: cty.True},
+		{expr: `tobool("yes")`, err: "cannot convert"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr, diags := hclsyntax.ParseExpression([]byte(tt.expr), "test.hcl", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatal(diags)
+			}
+
+			v, diags := expr.Value(evalContext(dir))
+			if tt.err != "" {
+				if !diags.HasErrors() || !strings.Contains(diags.Error(), tt.err) {
+					t.Errorf("got %#v, %v, want error %q", v, diags, tt.err)
+				}
+				return
+			}
+			if diags.HasErrors() {
+				t.Fatal(diags)
+			}
+			if !v.RawEquals(tt.want) {
+				t.Errorf("got %#v, want %#v", v, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileFuncRelativeToIncludingFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.hcl": testHealthcheck + `include { path = "conf.d/admin.hcl" }`,
+		"conf.d/admin.hcl": `admin {
+    address = ":8081"
+    token   = trimspace(file("token"))
+}`,
+		"conf.d/token": "s3cret\n",
+		// not read, file of included config resolved from its directory
+		"token": "wrong",
+	})
+
+	l := newLoader(FormatAuto)
+	if err := l.loadPattern(filepath.Join(dir, "main.hcl")); err != nil {
+		t.Fatal(err)
+	}
+	if l.config.Admin == nil || l.config.Admin.Token != "s3cret" {
+		t.Errorf("unexpected admin %+v", l.config.Admin)
+	}
+
+	writeFiles(t, dir, map[string]string{"conf.d/admin.hcl": `admin {
+    address = ":8081"
+    token   = file("missing")
+}`})
+	err := newLoader(FormatAuto).loadPattern(filepath.Join(dir, "main.hcl"))
+	if err == nil || !strings.Contains(err.Error(), "failed to read file") {
+		t.Errorf("got %v, want missing file reported", err)
+	}
+}
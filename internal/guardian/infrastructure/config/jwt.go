@@ -0,0 +1,87 @@
+package config
+
+import (
+	"github.com/hashicorp/hcl/v2"
+
+	"guardian/internal/common/infrastructure/filewatch"
+	appdownstream "guardian/internal/guardian/app/proxy/downstream"
+	"guardian/internal/guardian/infrastructure/jwtauth"
+)
+
+func mapJWTAuthorizer(auth jwtDownstreamAuthorizer, providers *userProviders, body hcl.Body) (appdownstream.Authorizer, error) {
+	if len(auth.Keys) == 0 && auth.JWKSFile == "" {
+		return nil, diagnostic(body, "jwt authorizer requires key or jwksFile")
+	}
+
+	keys := make([]jwtauth.Key, 0, len(auth.Keys))
+	for _, k := range auth.Keys {
+		key, err := mapJWTKey(k, body)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	var jwks *filewatch.File[[]jwtauth.Key]
+	if auth.JWKSFile != "" {
+		var err error
+		jwks, err = filewatch.NewFile(resolvePath(auth.JWKSFile, body), filewatch.DefaultInterval, jwtauth.ParseJWKS)
+		if err != nil {
+			return nil, diagnostic(body, "%s", err)
+		}
+	}
+
+	leeway, err := parseDuration(auth.Leeway, body)
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := providers.resolveOptional(auth.UserProvider, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return jwtauth.NewAuthorizer(jwtauth.Config{
+		Cookie:   auth.Cookie,
+		Keys:     keys,
+		JWKS:     jwks,
+		Issuer:   auth.Issuer,
+		Audience: auth.Audience,
+		Leeway:   leeway,
+		Claims:   mapJWTClaims(auth.Claims),
+	}, provider), nil
+}
+
+func mapJWTClaims(c *jwtClaims) jwtauth.Claims {
+	if c == nil {
+		return jwtauth.Claims{}
+	}
+
+	return jwtauth.Claims{
+		ID:          c.ID,
+		Username:    c.Username,
+		Email:       c.Email,
+		DisplayName: c.DisplayName,
+		Groups:      c.Groups,
+		Attributes:  c.Attributes,
+	}
+}
+
+func mapJWTKey(k jwtKey, body hcl.Body) (jwtauth.Key, error) {
+	if k.Algorithm == jwtauth.HS256 {
+		if len(k.Secret) < 32 {
+			return jwtauth.Key{}, diagnostic(body, "secret of jwt key %s must be at least 32 bytes", k.ID)
+		}
+		return jwtauth.NewHMACKey(k.ID, []byte(k.Secret)), nil
+	}
+
+	if k.PublicKey == "" {
+		return jwtauth.Key{}, diagnostic(body, "jwt key %s requires publicKey", k.ID)
+	}
+
+	key, err := jwtauth.ParsePublicKey(k.ID, k.Algorithm, []byte(k.PublicKey))
+	if err != nil {
+		return jwtauth.Key{}, diagnostic(body, "%s", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,345 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/UsingCoding/fpgo/pkg/maybe"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/pkg/errors"
+)
+
+func newLoader(format Format) *loader {
+	return &loader{
+		format: format,
+		parser: hclparse.NewParser(),
+		loaded: map[string]bool{},
+	}
+}
+
+// loader reads config files with includes and merges them into single appConfig.
+// Files loaded in deterministic order: files of directory or glob sorted by name,
+// included files loaded right after file that includes them
+type loader struct {
+	format Format
+	parser *hclparse.Parser
+	// loaded holds absolute paths of loaded files, so file reached by several paths loaded once
+	loaded map[string]bool
+
+	// sources holds absolute paths of files and directories config loaded from
+	sources []string
+	config  appConfig
+}
+
+// loadPattern loads file, all config files of directory or files matched by glob
+func (l *loader) loadPattern(pattern string) error {
+	files, err := l.resolve(pattern)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		err = l.loadFile(file)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *loader) loadFile(file string) error {
+	path, err := filepath.Abs(file)
+	if err != nil {
+		return errors.Wrapf(err, "failed to resolve config %s", file)
+	}
+	if l.loaded[path] {
+		return nil
+	}
+	// marked before includes loaded, so include cycles stop here
+	l.loaded[path] = true
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read config %s", file)
+	}
+	l.addSource(path)
+
+	return l.loadData(file, data)
+}
+
+func (l *loader) loadData(filename string, data []byte) error {
+	parse := l.parser.ParseHCL
+	if l.fileFormat(filename) == FormatJSON {
+		parse = l.parser.ParseJSON
+	}
+
+	f, diags := parse(data, filename)
+	if diags.HasErrors() {
+		return errors.Wrap(diags, "failed to parse app config")
+	}
+
+	var c appConfig
+	diags = gohcl.DecodeBody(f.Body, evalContext(filepath.Dir(filename)), &c)
+	if diags.HasErrors() {
+		return errors.Wrap(diags, "failed to parse app config")
+	}
+
+	diags = l.config.merge(c)
+	if diags.HasErrors() {
+		return diags
+	}
+
+	for _, i := range c.Includes {
+		p := i.Path
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(filepath.Dir(filename), p)
+		}
+
+		err := l.loadPattern(p)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (l *loader) resolve(pattern string) ([]string, error) {
+	info, err := os.Stat(pattern)
+	switch {
+	case err == nil && info.IsDir():
+		l.addSource(pattern)
+
+		entries, err2 := os.ReadDir(pattern)
+		if err2 != nil {
+			return nil, errors.Wrapf(err2, "failed to read config dir %s", pattern)
+		}
+
+		var files []string
+		for _, e := range entries {
+			if e.IsDir() || !l.configFile(e.Name()) {
+				continue
+			}
+			files = append(files, filepath.Join(pattern, e.Name()))
+		}
+		return files, nil
+	case err == nil:
+		return []string{pattern}, nil
+	case !strings.ContainsAny(pattern, "*?["):
+		return nil, errors.Wrapf(err, "failed to read config %s", pattern)
+	}
+
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid config pattern %s", pattern)
+	}
+	if len(files) == 0 {
+		return nil, errors.Errorf("no config files matched %s", pattern)
+	}
+	// watch directory of glob to notice new files
+	l.addSource(filepath.Dir(pattern))
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// addSource records absolute path of file or directory config loaded from once
+func (l *loader) addSource(path string) {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	for _, s := range l.sources {
+		if s == path {
+			return
+		}
+	}
+	l.sources = append(l.sources, path)
+}
+
+func (l *loader) fileFormat(filename string) Format {
+	if l.format != FormatAuto {
+		return l.format
+	}
+	if filepath.Ext(filename) == jsonFileExt {
+		return FormatJSON
+	}
+	return FormatHCL
+}
+
+// configFile reports whether file in config directory should be loaded
+func (l *loader) configFile(filename string) bool {
+	ext := filepath.Ext(filename)
+	switch l.format {
+	case FormatHCL:
+		return ext == hclFileExt
+	case FormatJSON:
+		return ext == jsonFileExt
+	default:
+		return ext == hclFileExt || ext == jsonFileExt
+	}
+}
+
+// merge appends blocks of other config file.
+// httpproxy blocks with same address merged into one listener
+func (c *appConfig) merge(other appConfig) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+
+	if other.Healthcheck != nil {
+		if c.Healthcheck != nil {
+			diags = append(diags, conflict(other.Healthcheck.Body, c.Healthcheck.Body, "healthcheck defined twice"))
+		} else {
+			c.Healthcheck = other.Healthcheck
+		}
+	}
+
+	if other.Admin != nil {
+		if c.Admin != nil {
+			diags = append(diags, conflict(other.Admin.Body, c.Admin.Body, "admin defined twice"))
+		} else {
+			c.Admin = other.Admin
+		}
+	}
+
+	for _, s := range other.SessionStores {
+		if existing, ok := maybe.JustValid(findSessionStore(c.SessionStores, s.ID)); ok {
+			diags = append(diags, conflict(s.Body, existing.Body, "sessionstore %s defined twice", s.ID))
+			continue
+		}
+		c.SessionStores = append(c.SessionStores, s)
+	}
+
+	for _, p := range other.UserProviders {
+		if existing, ok := maybe.JustValid(findUserProvider(c.UserProviders, p.ID)); ok {
+			diags = append(diags, conflict(p.Body, existing.Body, "userprovider %s defined twice", p.ID))
+			continue
+		}
+		c.UserProviders = append(c.UserProviders, p)
+	}
+
+	for _, p := range other.TCPProxies {
+		if existing, ok := maybe.JustValid(findTCPProxy(c.TCPProxies, p.SrcAdress)); ok {
+			diags = append(diags, conflict(p.Body, existing.Body, "tcpproxy %s defined twice", p.SrcAdress))
+			continue
+		}
+		c.TCPProxies = append(c.TCPProxies, p)
+	}
+
+	for _, p := range other.HTTPProxies {
+		i, ok := findHTTPProxy(c.HTTPProxies, p.Address)
+		if !ok {
+			c.HTTPProxies = append(c.HTTPProxies, httpProxy{
+				Address: p.Address,
+				Body:    p.Body,
+			})
+			i = len(c.HTTPProxies) - 1
+		}
+
+		diags = append(diags, c.HTTPProxies[i].merge(p)...)
+	}
+
+	return diags
+}
+
+func (p *httpProxy) merge(other httpProxy) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+
+	if other.Limit != nil {
+		if p.Limit != nil {
+			diags = append(diags, conflict(other.Limit.Body, p.Limit.Body, "limit for httpproxy %s defined twice", p.Address))
+		} else {
+			p.Limit = other.Limit
+		}
+	}
+
+	if other.TLS != nil {
+		if p.TLS != nil {
+			diags = append(diags, conflict(other.TLS.Body, p.TLS.Body, "tls for httpproxy %s defined twice", p.Address))
+		} else {
+			p.TLS = other.TLS
+		}
+	}
+
+	for _, d := range other.Downstream {
+		if existing, ok := maybe.JustValid(findDownstream(p.Downstream, d.ID)); ok {
+			diags = append(diags, conflict(d.Body, existing.Body, "downstream %s of httpproxy %s defined twice", d.ID, p.Address))
+			continue
+		}
+		p.Downstream = append(p.Downstream, d)
+	}
+
+	for _, u := range other.Upstream {
+		if existing, ok := maybe.JustValid(findUpstream(p.Upstream, u.ID)); ok {
+			diags = append(diags, conflict(u.Body, existing.Body, "upstream %s of httpproxy %s defined twice", u.ID, p.Address))
+			continue
+		}
+		p.Upstream = append(p.Upstream, u)
+	}
+
+	return diags
+}
+
+// conflict reports block defined in body that conflicts with previously defined one
+func conflict(body, prev hcl.Body, format string, args ...any) *hcl.Diagnostic {
+	summary := fmt.Sprintf(format, args...)
+	return &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  summary,
+		Detail:   fmt.Sprintf("Defined in %s and %s", location(prev), location(body)),
+		Subject:  body.MissingItemRange().Ptr(),
+	}
+}
+
+func location(body hcl.Body) string {
+	r := body.MissingItemRange()
+	return fmt.Sprintf("%s:%d", r.Filename, r.Start.Line)
+}
+
+func findUserProvider(providers []userProvider, id string) maybe.Maybe[userProvider] {
+	for _, p := range providers {
+		if p.ID == id {
+			return maybe.NewJust(p)
+		}
+	}
+	return maybe.Maybe[userProvider]{}
+}
+
+func findSessionStore(stores []sessionStore, id string) maybe.Maybe[sessionStore] {
+	for _, s := range stores {
+		if s.ID == id {
+			return maybe.NewJust(s)
+		}
+	}
+	return maybe.Maybe[sessionStore]{}
+}
+
+func findTCPProxy(proxies []tcpProxy, src string) maybe.Maybe[tcpProxy] {
+	for _, p := range proxies {
+		if p.SrcAdress == src {
+			return maybe.NewJust(p)
+		}
+	}
+	return maybe.Maybe[tcpProxy]{}
+}
+
+func findHTTPProxy(proxies []httpProxy, address string) (int, bool) {
+	for i, p := range proxies {
+		if p.Address == address {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func findDownstream(downstreams []downstream, id string) maybe.Maybe[downstream] {
+	for _, d := range downstreams {
+		if d.ID == id {
+			return maybe.NewJust(d)
+		}
+	}
+	return maybe.Maybe[downstream]{}
+}
@@ -0,0 +1,203 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testHealthcheck = `
+healthcheck {
+    address = ":8080"
+    path    = "/healthz"
+}
+`
+
+// writeFiles writes files by path relative to dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoader(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		pattern string
+		format  Format
+		// err holds substrings of error, config loaded when empty
+		err   []string
+		check func(t *testing.T, c appConfig)
+	}{
+		{
+			name: "include relative to including file",
+			files: map[string]string{
+				"main.hcl":             `include { path = "conf.d" }`,
+				"conf.d/health.hcl":    testHealthcheck + `include { path = "proxies/*.hcl" }`,
+				"conf.d/proxies/a.hcl": `tcpproxy ":9000" { destination = "127.0.0.1:9001" }`,
+				"conf.d/proxies/b.hcl": `tcpproxy ":9002" { destination = "127.0.0.1:9003" }`,
+				"conf.d/README.md":     "not config",
+			},
+			pattern: "main.hcl",
+			check: func(t *testing.T, c appConfig) {
+				if c.Healthcheck == nil || len(c.TCPProxies) != 2 || c.TCPProxies[0].SrcAdress != ":9000" {
+					t.Errorf("includes not loaded in order: %+v", c)
+				}
+			},
+		},
+		{
+			name: "include cycle",
+			files: map[string]string{
+				"a.hcl": testHealthcheck + `include { path = "b.hcl" }`,
+				"b.hcl": `include { path = "a.hcl" }
+tcpproxy ":9000" { destination = "127.0.0.1:9001" }`,
+			},
+			pattern: "a.hcl",
+			check: func(t *testing.T, c appConfig) {
+				if c.Healthcheck == nil || len(c.TCPProxies) != 1 {
+					t.Errorf("unexpected config %+v", c)
+				}
+			},
+		},
+		{
+			name: "hcl and json mixed",
+			files: map[string]string{
+				"health.json": `{"healthcheck": {"address": ":8080", "path": "/healthz"}}`,
+				"proxy.hcl":   `tcpproxy ":9000" { destination = "127.0.0.1:9001" }`,
+			},
+			pattern: ".",
+			check: func(t *testing.T, c appConfig) {
+				if c.Healthcheck == nil || c.Healthcheck.Path != "/healthz" || len(c.TCPProxies) != 1 {
+					t.Errorf("unexpected config %+v", c)
+				}
+			},
+		},
+		{
+			name: "format limits files of directory",
+			files: map[string]string{
+				"health.json": `{"healthcheck": {"address": ":8080", "path": "/healthz"}}`,
+				"proxy.hcl":   `tcpproxy ":9000" { destination = "127.0.0.1:9001" }`,
+			},
+			pattern: ".",
+			format:  FormatHCL,
+			check: func(t *testing.T, c appConfig) {
+				if c.Healthcheck != nil || len(c.TCPProxies) != 1 {
+					t.Errorf("json file loaded as hcl: %+v", c)
+				}
+			},
+		},
+		{
+			name: "conflict names both files",
+			files: map[string]string{
+				"a.hcl":  testHealthcheck,
+				"b.hcl":  `include { path = "c.json" }`,
+				"c.json": `{"healthcheck": {"address": ":8081", "path": "/"}}`,
+			},
+			pattern: "*.hcl",
+			err:     []string{"healthcheck defined twice", "a.hcl:2", "c.json:1"},
+		},
+		{
+			name: "conflict of nested blocks",
+			files: map[string]string{
+				"a.hcl": testHealthcheck + `httpproxy ":8000" {
+    upstream app {
+        address = "http://127.0.0.1:9000"
+    }
+}`,
+				"b.hcl": `httpproxy ":8000" {
+    upstream app {
+        address = "http://127.0.0.1:9001"
+    }
+}`,
+			},
+			pattern: ".",
+			err:     []string{"upstream app of httpproxy :8000 defined twice", "a.hcl:7", "b.hcl:2"},
+		},
+		{
+			name:    "missing include",
+			files:   map[string]string{"a.hcl": testHealthcheck + `include { path = "missing.hcl" }`},
+			pattern: "a.hcl",
+			err:     []string{"failed to read config", "missing.hcl"},
+		},
+		{
+			name:    "glob matched nothing",
+			files:   map[string]string{"a.hcl": testHealthcheck + `include { path = "conf.d/*.hcl" }`},
+			pattern: "a.hcl",
+			err:     []string{"no config files matched"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+
+			l := newLoader(tt.format)
+			err := l.loadPattern(filepath.Join(dir, tt.pattern))
+			if len(tt.err) == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				tt.check(t, l.config)
+				return
+			}
+
+			if err == nil {
+				t.Fatal("config loaded")
+			}
+			msg := err.Error()
+			if diags, ok := Diagnostics(err); ok {
+				for _, d := range diags {
+					msg += "\n" + d.Detail
+				}
+			}
+			for _, s := range tt.err {
+				if !strings.Contains(msg, s) {
+					t.Errorf("%q not reported, got %s", s, msg)
+				}
+			}
+		})
+	}
+}
+
+func TestLoaderLoadsFileOnce(t *testing.T) {
+	// working directory reported without symlinks
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, dir, map[string]string{
+		"a.hcl": testHealthcheck + `include { path = "conf.d/b.hcl" }`,
+		// reaches a.hcl by path other than given on command line
+		"conf.d/b.hcl": `include { path = "../a.hcl" }`,
+	})
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	l := newLoader(FormatAuto)
+	if err = l.loadPattern("./a.hcl"); err != nil {
+		t.Fatal(err)
+	}
+
+	// sources watched by absolute paths, each once
+	want := []string{filepath.Join(dir, "a.hcl"), filepath.Join(dir, "conf.d", "b.hcl")}
+	if strings.Join(l.sources, ",") != strings.Join(want, ",") {
+		t.Errorf("got sources %v, want %v", l.sources, want)
+	}
+}
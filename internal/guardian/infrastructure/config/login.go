@@ -0,0 +1,61 @@
+package config
+
+import (
+	"html/template"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+
+	"guardian/internal/common/infrastructure/filewatch"
+	appdownstream "guardian/internal/guardian/app/proxy/downstream"
+	"guardian/internal/guardian/app/user"
+	"guardian/internal/guardian/infrastructure/loginportal"
+)
+
+func mapLoginAuthorizer(auth loginDownstreamAuthorizer, providers *userProviders, stores *sessionStores, body hcl.Body) (appdownstream.Authorizer, error) {
+	for _, p := range []string{auth.Path, auth.LogoutPath} {
+		if p != "" && !strings.HasPrefix(p, "/") {
+			return nil, diagnostic(body, "path %s must start with /", p)
+		}
+	}
+	if auth.Path != "" && auth.Path == auth.LogoutPath {
+		return nil, diagnostic(body, "logoutPath must differ from path")
+	}
+
+	codec, err := mapSession(auth.Session, stores, body)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionTTL, err := parseDuration(auth.SessionTTL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := providers.resolve(auth.UserProvider, body)
+	if err != nil {
+		return nil, err
+	}
+	authenticator, ok := provider.(user.Authenticator)
+	if !ok {
+		return nil, diagnostic(body, "userprovider of login authorizer must check credentials, supported by ldap, static and htpasswd")
+	}
+
+	var t *filewatch.File[*template.Template]
+	if auth.Template != "" {
+		t, err = filewatch.NewFile(resolvePath(auth.Template, body), filewatch.DefaultInterval, loginportal.ParseTemplate)
+		if err != nil {
+			return nil, diagnostic(body, "%s", err)
+		}
+	}
+
+	return loginportal.NewAuthorizer(loginportal.Config{
+		Path:          auth.Path,
+		LogoutPath:    auth.LogoutPath,
+		ReturnToParam: auth.ReturnToParam,
+		Cookie:        auth.Cookie,
+		Codec:         codec,
+		SessionTTL:    sessionTTL,
+		Template:      t,
+	}, authenticator), nil
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"html/template"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"guardian/internal/common/infrastructure/filewatch"
+	appdownstream "guardian/internal/guardian/app/proxy/downstream"
+	"guardian/internal/guardian/infrastructure/totp"
+)
+
+// NewTOTPAttempts returns registry of TOTP attempts shared by parses
+func NewTOTPAttempts() *TOTPAttempts {
+	return &TOTPAttempts{
+		attempts: map[string]*totp.Attempts{},
+	}
+}
+
+// TOTPAttempts keeps lockouts and used codes between config reloads, attempts reused by path of secrets file
+type TOTPAttempts struct {
+	mu       sync.Mutex
+	attempts map[string]*totp.Attempts
+}
+
+func (r *TOTPAttempts) get(secretsPath string) *totp.Attempts {
+	if r == nil {
+		return totp.NewAttempts()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a, ok := r.attempts[secretsPath]
+	if !ok {
+		a = totp.NewAttempts()
+		r.attempts[secretsPath] = a
+	}
+	return a
+}
+
+func mapDownstreamMFA(m downstreamMFA, stores *sessionStores, attempts *TOTPAttempts) (appdownstream.SecondFactor, error) {
+	switch m.Type {
+	case totpDownstreamMFAType:
+		p, err := decodeHclBody[totpDownstreamMFA](m.Payload)
+		if err != nil {
+			return nil, err
+		}
+
+		return mapTOTP(p, stores, attempts, m)
+	default:
+		return nil, diagnostic(m.Body, "unknown mfa type %s", m.Type)
+	}
+}
+
+func mapTOTP(p totpDownstreamMFA, stores *sessionStores, attempts *TOTPAttempts, m downstreamMFA) (appdownstream.SecondFactor, error) {
+	if p.Path != "" && !strings.HasPrefix(p.Path, "/") {
+		return nil, diagnostic(m.Body, "path %s must start with /", p.Path)
+	}
+
+	window, err := parseDuration(p.Window, m.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	codec, err := mapSession(p.Session, stores, m.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	secretsPath, err := filepath.Abs(resolvePath(p.Secrets, m.Body))
+	if err != nil {
+		return nil, diagnostic(m.Body, "%s", err)
+	}
+	secrets, err := filewatch.NewFile(secretsPath, filewatch.DefaultInterval, totp.ParseSecrets)
+	if err != nil {
+		return nil, diagnostic(m.Body, "%s", err)
+	}
+
+	var t *filewatch.File[*template.Template]
+	if p.Template != "" {
+		t, err = filewatch.NewFile(resolvePath(p.Template, m.Body), filewatch.DefaultInterval, totp.ParseTemplate)
+		if err != nil {
+			return nil, diagnostic(m.Body, "%s", err)
+		}
+	}
+
+	return totp.NewSecondFactor(totp.Config{
+		Path:          p.Path,
+		ReturnToParam: p.ReturnToParam,
+		Window:        window,
+		Cookie:        p.Cookie,
+		Codec:         codec,
+		Secrets:       secrets,
+		Template:      t,
+		Attempts:      attempts.get(secretsPath),
+	}), nil
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	cryptotls "crypto/tls"
+	"os"
+
+	"github.com/hashicorp/hcl/v2"
+
+	"guardian/internal/common/infrastructure/filewatch"
+	appdownstream "guardian/internal/guardian/app/proxy/downstream"
+	"guardian/internal/guardian/infrastructure/mtls"
+)
+
+func mapListenerTLS(t *listenerTLS) (*cryptotls.Config, error) {
+	if t == nil {
+		return nil, nil
+	}
+
+	config := mtls.ServerConfig{
+		CertFile: resolvePath(t.CertFile, t.Body),
+		KeyFile:  resolvePath(t.KeyFile, t.Body),
+	}
+
+	clientAuth := t.ClientAuth
+	if clientAuth == "" && t.ClientCAFile != "" {
+		clientAuth = "require"
+	}
+	switch clientAuth {
+	case "", "none":
+		config.ClientAuth = cryptotls.NoClientCert
+	case "optional":
+		config.ClientAuth = cryptotls.VerifyClientCertIfGiven
+	case "require":
+		config.ClientAuth = cryptotls.RequireAndVerifyClientCert
+	default:
+		return nil, diagnostic(t.Body, "unknown clientAuth %s, expected none, optional or require", t.ClientAuth)
+	}
+
+	if config.ClientAuth != cryptotls.NoClientCert {
+		if t.ClientCAFile == "" {
+			return nil, diagnostic(t.Body, "clientAuth %s requires clientCAFile", clientAuth)
+		}
+
+		data, err := os.ReadFile(resolvePath(t.ClientCAFile, t.Body))
+		if err != nil {
+			return nil, diagnostic(t.Body, "failed to read client CA file: %s", err)
+		}
+		config.ClientCAs, err = mtls.ParseCertificates(data)
+		if err != nil {
+			return nil, diagnostic(t.Body, "client CA file %s: %s", t.ClientCAFile, err)
+		}
+	}
+
+	if t.CRLFile != "" {
+		if len(config.ClientCAs) == 0 {
+			return nil, diagnostic(t.Body, "crlFile requires clientCAFile")
+		}
+
+		var err error
+		config.CRL, err = filewatch.NewFile(resolvePath(t.CRLFile, t.Body), filewatch.DefaultInterval, mtls.ParseRevocationLists(config.ClientCAs))
+		if err != nil {
+			return nil, diagnostic(t.Body, "%s", err)
+		}
+	}
+
+	res, err := mtls.NewServerTLS(config)
+	if err != nil {
+		return nil, diagnostic(t.Body, "%s", err)
+	}
+	return res, nil
+}
+
+func mapMTLSAuthorizer(auth mtlsDownstreamAuthorizer, providers *userProviders, body hcl.Body) (appdownstream.Authorizer, error) {
+	var mapping mtls.Mapping
+	sources := map[*mtls.Source]string{
+		&mapping.ID:          auth.ID,
+		&mapping.Username:    auth.Username,
+		&mapping.Email:       auth.Email,
+		&mapping.DisplayName: auth.DisplayName,
+		&mapping.Groups:      auth.Groups,
+	}
+	for src, s := range sources {
+		if s == "" {
+			continue
+		}
+
+		var err error
+		*src, err = mtls.ParseSource(s)
+		if err != nil {
+			return nil, diagnostic(body, "%s", err)
+		}
+	}
+
+	if len(auth.Attributes) != 0 {
+		mapping.Attributes = make(map[string]mtls.Source, len(auth.Attributes))
+		for name, s := range auth.Attributes {
+			src, err := mtls.ParseSource(s)
+			if err != nil {
+				return nil, diagnostic(body, "%s", err)
+			}
+			mapping.Attributes[name] = src
+		}
+	}
+
+	provider, err := providers.resolveOptional(auth.UserProvider, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return mtls.NewAuthorizer(mapping, provider), nil
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"net/url"
+
+	"github.com/hashicorp/hcl/v2"
+
+	appdownstream "guardian/internal/guardian/app/proxy/downstream"
+	"guardian/internal/guardian/infrastructure/oidc"
+)
+
+func mapOIDCAuthorizer(auth oidcDownstreamAuthorizer, providers *userProviders, body hcl.Body) (appdownstream.Authorizer, error) {
+	redirectURL, err := url.Parse(auth.RedirectURL)
+	if err != nil || !redirectURL.IsAbs() || redirectURL.Path == "" {
+		return nil, diagnostic(body, "redirectURL %s must be absolute URL with path", auth.RedirectURL)
+	}
+	if auth.LogoutPath != "" && auth.LogoutPath == redirectURL.Path {
+		return nil, diagnostic(body, "logoutPath must differ from path of redirectURL")
+	}
+
+	// oidc seals login state with session keys and keeps user built from claims in session, which store drops,
+	// so sessions are signed cookies
+	if auth.Session.Store != nil {
+		return nil, diagnostic(body, "oidc authorizer doesn't support session store, session keeps user built from claims")
+	}
+	codec, err := mapSessionCookie(auth.Session, body)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionTTL, err := parseDuration(auth.SessionTTL, body)
+	if err != nil {
+		return nil, err
+	}
+	sessionMaxAge, err := parseDuration(auth.SessionMaxAge, body)
+	if err != nil {
+		return nil, err
+	}
+
+	transport, err := mapTransport(auth.TLS, body)
+	if err != nil {
+		return nil, err
+	}
+
+	var endpoints oidc.Endpoints
+	if e := auth.Endpoints; e != nil {
+		endpoints = oidc.Endpoints{
+			Authorization: e.Authorization,
+			Token:         e.Token,
+			JWKS:          e.JWKS,
+			EndSession:    e.EndSession,
+		}
+	}
+
+	provider, err := providers.resolveOptional(auth.UserProvider, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return oidc.NewAuthorizer(oidc.Config{
+		Issuer:                auth.Issuer,
+		ClientID:              auth.ClientID,
+		ClientSecret:          auth.ClientSecret,
+		RedirectURL:           redirectURL,
+		Scopes:                auth.Scopes,
+		Endpoints:             endpoints,
+		LogoutPath:            auth.LogoutPath,
+		PostLogoutRedirectURL: auth.PostLogoutRedirectURL,
+		Cookie:                auth.Cookie,
+		Codec:                 codec,
+		SessionTTL:            sessionTTL,
+		SessionMaxAge:         sessionMaxAge,
+		Claims:                mapJWTClaims(auth.Claims),
+		Transport:             transport,
+	}, provider), nil
+}
@@ -1,75 +1,181 @@
 package config
 
 import (
+	"fmt"
+	"net/http"
 	"net/url"
-	"os"
-	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/UsingCoding/fpgo/pkg/maybe"
 	"github.com/UsingCoding/fpgo/pkg/slices"
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/gohcl"
-	"github.com/hashicorp/hcl/v2/hclsimple"
 	"github.com/pkg/errors"
 
+	"guardian/internal/common/infrastructure/filewatch"
 	"guardian/internal/guardian/app/config"
 	appdownstream "guardian/internal/guardian/app/proxy/downstream"
 	appupstream "guardian/internal/guardian/app/proxy/upstream"
+	"guardian/internal/guardian/app/source"
 	"guardian/internal/guardian/app/user"
-	"guardian/internal/guardian/infrastructure/ldap"
+	"guardian/internal/guardian/infrastructure/apikey"
+	"guardian/internal/guardian/infrastructure/htpasswd"
 )
 
-type Parser struct{}
+type Format string
 
-func (p Parser) Parse(file string) (config.AppConfig, error) {
-	data, err := os.ReadFile(file)
+const (
+	// FormatAuto chooses syntax by file extension: .json files parsed as JSON, others as HCL
+	FormatAuto = Format("")
+	FormatHCL  = Format("hcl")
+	FormatJSON = Format("json")
+)
+
+const (
+	hclFileExt  = ".hcl"
+	jsonFileExt = ".json"
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatAuto, FormatHCL, FormatJSON:
+		return f, nil
+	default:
+		return "", errors.Errorf("unknown config format %s", s)
+	}
+}
+
+type Parser struct {
+	Format Format
+	// SessionStores reused between parses so sessions survive config reload, stores created for every parse when nil
+	SessionStores *SessionStores
+	// TOTPAttempts reused between parses so lockouts and used codes survive config reload
+	TOTPAttempts *TOTPAttempts
+}
+
+// Parse loads config from file, directory with config files or glob pattern.
+// Config files may include other files with include block
+func (p Parser) Parse(pattern string) (config.AppConfig, error) {
+	l := newLoader(p.Format)
+	err := l.loadPattern(pattern)
 	if err != nil {
-		return config.AppConfig{}, errors.Wrap(err, "failed to read config")
+		return config.AppConfig{}, err
 	}
-	return p.ParseData(path.Base(file), data)
+	return p.mapConfig(l)
 }
 
+// ParseData parses and maps config.
+// Config errors reported as hcl.Diagnostics with source ranges, use Diagnostics to extract them
 func (p Parser) ParseData(filename string, data []byte) (config.AppConfig, error) {
-	var c appConfig
-	err := hclsimple.Decode(filename, data, nil, &c)
+	l := newLoader(p.Format)
+	err := l.loadData(filename, data)
 	if err != nil {
-		return config.AppConfig{}, errors.Wrap(err, "failed to parse app config")
+		return config.AppConfig{}, err
 	}
+	return p.mapConfig(l)
+}
 
-	var provider maybe.Maybe[user.Provider]
+func (p Parser) mapConfig(l *loader) (config.AppConfig, error) {
+	c := l.config
 
-	if c.UserProvider != nil {
-		p, err2 := mapUserProvider(*c.UserProvider)
-		if err2 != nil {
-			return config.AppConfig{}, err2
-		}
+	if c.Healthcheck == nil {
+		return config.AppConfig{}, errors.New("healthcheck block is required")
+	}
 
-		provider = maybe.NewJust(p)
+	providers, err := mapUserProviders(c.UserProviders)
+	if err != nil {
+		return config.AppConfig{}, err
 	}
 
-	servers, err := mapHTTPProxies(c.HTTPProxies, provider)
+	stores, err := mapSessionStores(c.SessionStores, p.SessionStores)
 	if err != nil {
 		return config.AppConfig{}, err
 	}
 
-	return config.AppConfig{
+	// problems of proxies reported together with validation problems
+	var diags hcl.Diagnostics
+	servers, err := mapHTTPProxies(c.HTTPProxies, providers, stores, p.TOTPAttempts)
+	if err = collect(&diags, err); err != nil {
+		return config.AppConfig{}, err
+	}
+
+	for _, checkUsed := range []func() error{providers.checkUsed, stores.checkUsed} {
+		if err = collect(&diags, checkUsed()); err != nil {
+			return config.AppConfig{}, err
+		}
+	}
+
+	admin, err := mapAdmin(c.Admin)
+	if err = collect(&diags, err); err != nil {
+		return config.AppConfig{}, err
+	}
+
+	appConfig := config.AppConfig{
 		Healthcheck: config.Healthcheck{
 			Address: c.Healthcheck.Address,
 			Path:    c.Healthcheck.Path,
+			Source:  sourceRange(c.Healthcheck.Body),
 		},
-		UserProvider: provider,
-		TCPProxies:   mapTCPProxy(c.TCPProxies),
-		HTTPProxies:  servers,
-	}, nil
+		Admin:         admin,
+		UserProviders: providers.providers,
+		SessionStores: stores.stores,
+		TCPProxies:    mapTCPProxy(c.TCPProxies),
+		HTTPProxies:   servers,
+		Sources:       l.sources,
+	}
+
+	mappingFailed := diags.HasErrors()
+	for _, diag := range Validate(appConfig) {
+		// blocks with errors skipped, so warnings like unused upstream may be caused by them
+		if mappingFailed && diag.Severity == hcl.DiagWarning {
+			continue
+		}
+		diags = append(diags, diag)
+	}
+	if diags.HasErrors() {
+		return config.AppConfig{}, diags
+	}
+
+	return appConfig, nil
 }
 
-func mapUserProvider(provider userProvider) (user.Provider, error) {
-	switch provider.Type {
-	case ldapUserProviderType:
-		return ldap.NewUserProvider(provider.Address), nil
-	default:
-		return nil, errors.Errorf("unknown user provider type %s", provider.Type)
+// Validate runs semantic checks of config and reports problems as hcl diagnostics
+func Validate(c config.AppConfig) hcl.Diagnostics {
+	return slices.Map(config.Validate(c), func(p config.Problem) *hcl.Diagnostic {
+		severity := hcl.DiagError
+		if p.Severity == config.SeverityWarning {
+			severity = hcl.DiagWarning
+		}
+
+		return &hcl.Diagnostic{
+			Severity: severity,
+			Summary:  p.Summary,
+			Detail:   p.Detail,
+			Subject: &hcl.Range{
+				Filename: p.Source.Filename,
+				Start:    hcl.Pos(p.Source.Start),
+				End:      hcl.Pos(p.Source.End),
+			},
+		}
+	})
+}
+
+func mapAdmin(a *admin) (maybe.Maybe[config.Admin], error) {
+	if a == nil {
+		return maybe.Maybe[config.Admin]{}, nil
+	}
+	if a.Token == "" {
+		return maybe.Maybe[config.Admin]{}, diagnostic(a.Body, "admin token must not be empty")
 	}
+
+	return maybe.NewJust(config.Admin{
+		Address: a.Address,
+		Token:   a.Token,
+		Source:  sourceRange(a.Body),
+	}), nil
 }
 
 func mapTCPProxy(proxies []tcpProxy) []config.TCPProxy {
@@ -77,72 +183,214 @@ func mapTCPProxy(proxies []tcpProxy) []config.TCPProxy {
 		return config.TCPProxy{
 			SrcAddress: p.SrcAdress,
 			DstAddress: p.DstAddress,
+			Source:     sourceRange(p.Body),
 		}
 	})
 }
 
-func mapHTTPProxies(proxies []httpProxy, provider maybe.Maybe[user.Provider]) ([]config.HTTPProxy, error) {
-	return slices.MapErr(proxies, func(s httpProxy) (config.HTTPProxy, error) {
-		d, err := mapDownstream(s, provider)
-		if err != nil {
-			return config.HTTPProxy{}, err
+// mapHTTPProxies maps every proxy and reports problems of all of them at once
+func mapHTTPProxies(proxies []httpProxy, providers *userProviders, stores *sessionStores, attempts *TOTPAttempts) ([]config.HTTPProxy, error) {
+	var diags hcl.Diagnostics
+	res := make([]config.HTTPProxy, 0, len(proxies))
+	for _, s := range proxies {
+		d, err := mapDownstream(s, providers, stores, attempts)
+		if err = collect(&diags, err); err != nil {
+			return nil, err
 		}
 
+		var proxyDiags hcl.Diagnostics
 		u, err := mapUpstream(s.Upstream)
-		if err != nil {
-			return config.HTTPProxy{}, err
+		if err = collect(&proxyDiags, err); err != nil {
+			return nil, err
 		}
 
-		return config.HTTPProxy{
-			Address: s.Address,
-			Limit: config.Limit{
+		tlsConfig, err := mapListenerTLS(s.TLS)
+		if err = collect(&proxyDiags, err); err != nil {
+			return nil, err
+		}
+
+		if proxyDiags.HasErrors() {
+			// downstreams can't be validated without upstreams
+			diags = diags.Extend(proxyDiags)
+			continue
+		}
+
+		var l config.Limit
+		if s.Limit != nil {
+			l = config.Limit{
 				RPS:   s.Limit.RPS,
 				Burst: s.Limit.Burst,
-			},
+			}
+		}
+
+		res = append(res, config.HTTPProxy{
+			Address:    s.Address,
+			Source:     sourceRange(s.Body),
+			TLS:        tlsConfig,
+			Limit:      l,
 			Downstream: d,
 			Upstream:   u,
-		}, nil
-	})
+		})
+	}
+
+	if diags.HasErrors() {
+		return res, diags
+	}
+	return res, nil
 }
 
-func mapDownstream(s httpProxy, provider maybe.Maybe[user.Provider]) ([]appdownstream.Downstream, error) {
-	return slices.MapErr(s.Downstream, func(d downstream) (appdownstream.Downstream, error) {
-		if !maybe.Valid(findUpstream(s.Upstream, d.UpstreamID)) {
-			return appdownstream.Downstream{}, errors.Errorf(
-				"upstream %s for downstream %s not found",
-				d.UpstreamID,
-				d.ID,
-			)
-		}
+// mapDownstream returns downstreams mapped without problems and diagnostics of others
+func mapDownstream(s httpProxy, providers *userProviders, stores *sessionStores, attempts *TOTPAttempts) ([]appdownstream.Downstream, error) {
+	var diags hcl.Diagnostics
+	res := make([]appdownstream.Downstream, 0, len(s.Downstream))
+	for _, d := range s.Downstream {
+		var downstreamDiags hcl.Diagnostics
 
 		rules, err := mapRules(d.Rules)
-		if err != nil {
-			return appdownstream.Downstream{}, err
+		if err = collect(&downstreamDiags, err); err != nil {
+			return nil, err
 		}
 
-		var a maybe.Maybe[appdownstream.Authorizer]
+		a, authErr := mapDownstreamAuthorizers(d, providers, stores)
+		if err = collect(&downstreamDiags, authErr); err != nil {
+			return nil, err
+		}
 
-		if d.Authorizer != nil {
-			p, ok := maybe.JustValid(provider)
-			if !ok {
-				return appdownstream.Downstream{}, errors.Errorf("downstream %s requires authorizer but no user provider configured", d.ID)
+		for _, authorizer := range d.Authorizers {
+			// without verified client certificates every request unauthenticated
+			if authorizer.Type == mtlsDownstreamAuthorizerType && (s.TLS == nil || s.TLS.ClientCAFile == "") {
+				downstreamDiags = downstreamDiags.Extend(diagnostic(authorizer.Body, "mtls authorizer of downstream %s requires clientCAFile in tls of httpproxy %s", d.ID, s.Address))
 			}
+		}
 
-			auth, err2 := mapDownstreamAuthorizer(*d.Authorizer, p)
-			if err2 != nil {
-				return appdownstream.Downstream{}, err2
+		var u maybe.Maybe[appdownstream.Unauthenticated]
+		if d.OnUnauthenticated != nil {
+			if len(d.Authorizers) == 0 {
+				downstreamDiags = downstreamDiags.Extend(diagnostic(d.OnUnauthenticated.Body, "onUnauthenticated of downstream %s requires authorizer", d.ID))
 			}
 
-			a = maybe.NewJust(auth)
+			unauthenticated, err2 := mapOnUnauthenticated(*d.OnUnauthenticated)
+			if err2 = collect(&downstreamDiags, err2); err2 != nil {
+				return nil, err2
+			}
+			u = maybe.NewJust(unauthenticated)
 		}
 
-		return appdownstream.Downstream{
-			ID:         d.ID,
-			Rules:      rules,
-			UpstreamID: d.UpstreamID,
-			Authorizer: a,
-		}, nil
-	})
+		var p maybe.Maybe[appdownstream.Policy]
+		if d.Policy != nil {
+			if len(d.Authorizers) == 0 {
+				downstreamDiags = downstreamDiags.Extend(diagnostic(d.Policy.Body, "policy of downstream %s requires authorizer", d.ID))
+			}
+
+			policy, err2 := mapPolicy(*d.Policy)
+			if err2 = collect(&downstreamDiags, err2); err2 != nil {
+				return nil, err2
+			}
+
+			p = maybe.NewJust(policy)
+		}
+
+		var secondFactor maybe.Maybe[appdownstream.SecondFactor]
+		if d.MFA != nil {
+			// passed check bound to session, so it's dropped on logout
+			if _, ok := maybe.Just(a).(appdownstream.SessionAuthorizer); !ok && authErr == nil {
+				downstreamDiags = downstreamDiags.Extend(diagnostic(d.MFA.Body, "mfa of downstream %s requires session authorizer like cookie, login or oidc", d.ID))
+			}
+
+			f, err2 := mapDownstreamMFA(*d.MFA, stores, attempts)
+			if err2 = collect(&downstreamDiags, err2); err2 != nil {
+				return nil, err2
+			}
+
+			secondFactor = maybe.NewJust(f)
+		}
+
+		if downstreamDiags.HasErrors() {
+			diags = diags.Extend(downstreamDiags)
+			continue
+		}
+
+		res = append(res, appdownstream.Downstream{
+			ID:                d.ID,
+			Rules:             rules,
+			UpstreamID:        d.UpstreamID,
+			Authorizer:        a,
+			AuthOptional:      d.Optional,
+			OnUnauthenticated: u,
+			Policy:            p,
+			SecondFactor:      secondFactor,
+			Source:            sourceRange(d.Body),
+		})
+	}
+
+	if diags.HasErrors() {
+		return res, diags
+	}
+	return res, nil
+}
+
+func mapOnUnauthenticated(u onUnauthenticated) (appdownstream.Unauthenticated, error) {
+	loginURL, err := url.Parse(u.LoginURL)
+	if err != nil || (!loginURL.IsAbs() && !strings.HasPrefix(loginURL.Path, "/")) {
+		return appdownstream.Unauthenticated{}, diagnostic(u.Body, "loginURL %s must be absolute URL or path", u.LoginURL)
+	}
+
+	mode := appdownstream.UnauthenticatedMode(u.Mode)
+	switch mode {
+	case "", appdownstream.ModeNegotiate, appdownstream.ModeRedirect, appdownstream.ModeUnauthorized:
+	default:
+		return appdownstream.Unauthenticated{}, diagnostic(u.Body, "unknown onUnauthenticated mode %s, expected negotiate, redirect or unauthorized", u.Mode)
+	}
+
+	return appdownstream.Unauthenticated{
+		LoginURL:      loginURL,
+		ReturnToParam: u.ReturnToParam,
+		Mode:          mode,
+	}, nil
+}
+
+func mapPolicy(p policy) (appdownstream.Policy, error) {
+	res := appdownstream.Policy{
+		Default: appdownstream.EffectDeny,
+	}
+
+	switch effect := appdownstream.Effect(p.Default); effect {
+	case "":
+	case appdownstream.EffectAllow, appdownstream.EffectDeny:
+		res.Default = effect
+	default:
+		return appdownstream.Policy{}, diagnostic(p.Body, "unknown policy default %s, expected allow or deny", p.Default)
+	}
+
+	names := map[string]bool{}
+	add := func(rules []policyRule, effect appdownstream.Effect) error {
+		for _, r := range rules {
+			if names[r.Name] {
+				return diagnostic(p.Body, "policy rule %s defined twice", r.Name)
+			}
+			names[r.Name] = true
+
+			res.Rules = append(res.Rules, appdownstream.PolicyRule{
+				Name:       r.Name,
+				Effect:     effect,
+				Paths:      r.Paths,
+				Methods:    r.Methods,
+				Users:      r.Users,
+				Groups:     r.Groups,
+				Attributes: r.Attributes,
+			})
+		}
+		return nil
+	}
+
+	if err := add(p.Allow, appdownstream.EffectAllow); err != nil {
+		return appdownstream.Policy{}, err
+	}
+	if err := add(p.Deny, appdownstream.EffectDeny); err != nil {
+		return appdownstream.Policy{}, err
+	}
+
+	return res, nil
 }
 
 func mapRules(rules []rule) ([]appdownstream.Rule, error) {
@@ -167,12 +415,42 @@ func mapRules(rules []rule) ([]appdownstream.Rule, error) {
 				Prefix: decodedRule.Path,
 			}, nil
 		default:
-			return nil, errors.Errorf("unknown rule type %s", r.Type)
+			return nil, diagnostic(r.Body, "unknown rule type %s", r.Type)
 		}
 	})
 }
 
-func mapDownstreamAuthorizer(authorizer downstreamAuthorizer, provider user.Provider) (appdownstream.Authorizer, error) {
+func mapDownstreamAuthorizers(d downstream, providers *userProviders, stores *sessionStores) (maybe.Maybe[appdownstream.Authorizer], error) {
+	if len(d.Authorizers) == 0 {
+		if d.Optional || d.AuthMode != "" {
+			return maybe.Maybe[appdownstream.Authorizer]{}, diagnostic(d.Body, "authMode and optional of downstream %s require authorizer", d.ID)
+		}
+		return maybe.Maybe[appdownstream.Authorizer]{}, nil
+	}
+
+	mode := appdownstream.ChainFirst
+	switch m := appdownstream.ChainMode(d.AuthMode); m {
+	case "":
+	case appdownstream.ChainFirst, appdownstream.ChainAll:
+		mode = m
+	default:
+		return maybe.Maybe[appdownstream.Authorizer]{}, diagnostic(d.Body, "unknown authMode %s, expected first or all", d.AuthMode)
+	}
+
+	authorizers, err := slices.MapErr(d.Authorizers, func(a downstreamAuthorizer) (appdownstream.Authorizer, error) {
+		return mapDownstreamAuthorizer(d.ID, a, providers, stores)
+	})
+	if err != nil {
+		return maybe.Maybe[appdownstream.Authorizer]{}, err
+	}
+
+	if len(authorizers) == 1 {
+		return maybe.NewJust(authorizers[0]), nil
+	}
+	return maybe.NewJust(appdownstream.NewChain(mode, authorizers)), nil
+}
+
+func mapDownstreamAuthorizer(downstreamID string, authorizer downstreamAuthorizer, providers *userProviders, stores *sessionStores) (appdownstream.Authorizer, error) {
 	switch authorizer.Type {
 	case cookieDownstreamAuthorizerType:
 		auth, err := decodeHclBody[cookieDownstreamAuthorizer](authorizer.Payload)
@@ -180,9 +458,83 @@ func mapDownstreamAuthorizer(authorizer downstreamAuthorizer, provider user.Prov
 			return nil, err
 		}
 
-		return appdownstream.NewCookieAuthorizer(auth.Key, provider), nil
+		provider, err := providers.resolve(auth.UserProvider, authorizer.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		codec, err := mapSession(auth.Session, stores, authorizer.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		return appdownstream.NewCookieAuthorizer(auth.Key, codec, provider), nil
+	case jwtDownstreamAuthorizerType:
+		auth, err := decodeHclBody[jwtDownstreamAuthorizer](authorizer.Payload)
+		if err != nil {
+			return nil, err
+		}
+
+		return mapJWTAuthorizer(auth, providers, authorizer.Body)
+	case oidcDownstreamAuthorizerType:
+		auth, err := decodeHclBody[oidcDownstreamAuthorizer](authorizer.Payload)
+		if err != nil {
+			return nil, err
+		}
+
+		return mapOIDCAuthorizer(auth, providers, authorizer.Body)
+	case basicDownstreamAuthorizerType:
+		auth, err := decodeHclBody[basicDownstreamAuthorizer](authorizer.Payload)
+		if err != nil {
+			return nil, err
+		}
+
+		file, err := filewatch.NewFile(resolvePath(auth.File, authorizer.Body), filewatch.DefaultInterval, htpasswd.Parse)
+		if err != nil {
+			return nil, diagnostic(authorizer.Body, "%s", err)
+		}
+
+		return htpasswd.NewAuthorizer(file, auth.Realm), nil
+	case apikeyDownstreamAuthorizerType:
+		auth, err := decodeHclBody[apikeyDownstreamAuthorizer](authorizer.Payload)
+		if err != nil {
+			return nil, err
+		}
+
+		keys, err := filewatch.NewFile(resolvePath(auth.File, authorizer.Body), filewatch.DefaultInterval, apikey.ParseKeys)
+		if err != nil {
+			return nil, diagnostic(authorizer.Body, "%s", err)
+		}
+
+		return apikey.NewAuthorizer(apikey.Config{
+			Header:     auth.Header,
+			Query:      auth.Query,
+			Downstream: downstreamID,
+			Keys:       keys,
+		}), nil
+	case forwardDownstreamAuthorizerType:
+		auth, err := decodeHclBody[forwardDownstreamAuthorizer](authorizer.Payload)
+		if err != nil {
+			return nil, err
+		}
+
+		return mapForwardAuthorizer(auth, authorizer.Body)
+	case mtlsDownstreamAuthorizerType:
+		auth, err := decodeHclBody[mtlsDownstreamAuthorizer](authorizer.Payload)
+		if err != nil {
+			return nil, err
+		}
+
+		return mapMTLSAuthorizer(auth, providers, authorizer.Body)
+	case loginDownstreamAuthorizerType:
+		auth, err := decodeHclBody[loginDownstreamAuthorizer](authorizer.Payload)
+		if err != nil {
+			return nil, err
+		}
+
+		return mapLoginAuthorizer(auth, providers, stores, authorizer.Body)
 	default:
-		return nil, errors.Errorf("unknown donstream authorizer %s", authorizer.Type)
+		return nil, diagnostic(authorizer.Body, "unknown downstream authorizer %s", authorizer.Type)
 	}
 }
 
@@ -192,20 +544,21 @@ func mapUpstream(upstreams []upstream) ([]appupstream.Upstream, error) {
 		if u.Authorizer != nil {
 			auth, err := mapUpstreamAuthorizer(*u.Authorizer)
 			if err != nil {
-				return appupstream.Upstream{}, errors.Wrap(err, "failed to map upstream authorizer")
+				return appupstream.Upstream{}, err
 			}
 			a = maybe.NewJust(auth)
 		}
 
 		address, err := url.Parse(u.Address)
 		if err != nil {
-			return appupstream.Upstream{}, errors.Wrapf(err, "failed to parse %s upstream addess", u.Address)
+			return appupstream.Upstream{}, diagnostic(u.Body, "failed to parse %s upstream address: %s", u.Address, err)
 		}
 
 		return appupstream.Upstream{
 			ID:         u.ID,
 			Address:    address,
 			Authorizer: a,
+			Source:     sourceRange(u.Body),
 		}, nil
 	})
 }
@@ -218,20 +571,133 @@ func mapUpstreamAuthorizer(authorizer upstreamAuthorizer) (appupstream.Authorize
 			return nil, err
 		}
 
-		return appupstream.NewAuthHeaderAuthorizer(auth.UserID, auth.Username), nil
+		return mapHeaderUpstreamAuthorizer(auth, authorizer.Body)
 	default:
-		return nil, errors.Errorf("unknown upstream authorizer %s", authorizer.Type)
+		return nil, diagnostic(authorizer.Body, "unknown upstream authorizer %s", authorizer.Type)
 	}
 }
 
+func mapHeaderUpstreamAuthorizer(auth headerUpstreamAuthorizer, body hcl.Body) (appupstream.Authorizer, error) {
+	headers := map[string]user.Field{}
+	add := func(name string, field user.Field) error {
+		name = http.CanonicalHeaderKey(name)
+		if _, ok := headers[name]; ok {
+			return diagnostic(body, "header %s forwarded twice", name)
+		}
+		headers[name] = field
+		return nil
+	}
+
+	if auth.UserID != "" {
+		if err := add(auth.UserID, user.FieldID); err != nil {
+			return nil, err
+		}
+	}
+	if auth.Username != "" {
+		if err := add(auth.Username, user.FieldUsername); err != nil {
+			return nil, err
+		}
+	}
+
+	names := make([]string, 0, len(auth.Headers))
+	for name := range auth.Headers {
+		names = append(names, name)
+	}
+	// sorted to report errors deterministically
+	sort.Strings(names)
+
+	for _, name := range names {
+		field, err := user.ParseField(auth.Headers[name])
+		if err != nil {
+			return nil, diagnostic(body, "header %s: %s", name, err)
+		}
+		if err = add(name, field); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(headers) == 0 {
+		return nil, diagnostic(body, "header authorizer forwards no headers")
+	}
+
+	return appupstream.NewAuthHeaderAuthorizer(headers), nil
+}
+
+// decodeHclBody decodes body with functions resolved relative to file body defined in
 func decodeHclBody[T any](body hcl.Body) (v T, err error) {
-	diags := gohcl.DecodeBody(body, nil, &v)
+	ctx := evalContext(filepath.Dir(body.MissingItemRange().Filename))
+	diags := gohcl.DecodeBody(body, ctx, &v)
 	if diags.HasErrors() {
 		return v, diags
 	}
 	return v, nil
 }
 
+// diagnostic reports config error placed at block of body
+func diagnostic(body hcl.Body, format string, args ...any) hcl.Diagnostics {
+	return hcl.Diagnostics{
+		&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  fmt.Sprintf(format, args...),
+			Subject:  body.MissingItemRange().Ptr(),
+		},
+	}
+}
+
+// collect appends diagnostics of err to diags, so mapping goes on and reports every problem at once.
+// Errors which aren't diagnostics returned as is
+func collect(diags *hcl.Diagnostics, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	d, ok := Diagnostics(err)
+	if !ok {
+		return err
+	}
+	*diags = diags.Extend(d)
+	return nil
+}
+
+// resolvePath resolves path relative to file body defined in
+func resolvePath(p string, body hcl.Body) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(filepath.Dir(body.MissingItemRange().Filename), p)
+}
+
+// parseDuration parses optional duration, empty string means zero duration
+func parseDuration(s string, body hcl.Body) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, diagnostic(body, "invalid duration %s: %s", s, err)
+	}
+	return d, nil
+}
+
+func sourceRange(body hcl.Body) source.Range {
+	r := body.MissingItemRange()
+	return source.Range{
+		Filename: r.Filename,
+		Start:    source.Pos(r.Start),
+		End:      source.Pos(r.End),
+	}
+}
+
+// Diagnostics extracts hcl diagnostics from error returned by Parser
+func Diagnostics(err error) (hcl.Diagnostics, bool) {
+	var diags hcl.Diagnostics
+	if errors.As(err, &diags) {
+		return diags, true
+	}
+	return nil, false
+}
+
 func findUpstream(upstreams []upstream, id string) maybe.Maybe[upstream] {
 	for _, u := range upstreams {
 		if u.ID == id {
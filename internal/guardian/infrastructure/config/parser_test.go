@@ -0,0 +1,86 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+func TestParserReportsEveryProblem(t *testing.T) {
+	data := `
+healthcheck {
+    address = ":8080"
+    path    = "/healthz"
+}
+
+httpproxy ":8000" {
+    downstream a {
+        upstream = "app"
+        policy {
+            default = "allow"
+        }
+    }
+    downstream b {
+        upstream = "app"
+        onUnauthenticated {
+            loginURL = "/login"
+        }
+    }
+    downstream c {
+        upstream = "missing"
+    }
+    downstream d {
+        upstream = "app"
+        authorizer mtls {}
+    }
+    downstream e {
+        upstream = "app"
+        authorizer oidc {
+            issuer      = "https://idp.example.com"
+            clientID    = "guardian"
+            redirectURL = "https://app.example.com/callback"
+            session {
+                store = "main"
+            }
+        }
+    }
+    upstream app {
+        address = "http://127.0.0.1:9000"
+    }
+}
+
+sessionstore memory main {}
+
+tcpproxy ":8000" {
+    destination = "127.0.0.1:9001"
+}
+`
+	_, err := Parser{}.ParseData("guardian.hcl", []byte(data))
+	diags, ok := Diagnostics(err)
+	if !ok {
+		t.Fatalf("expected diagnostics, got %v", err)
+	}
+
+	for _, summary := range []string{
+		"policy of downstream a requires authorizer",
+		"onUnauthenticated of downstream b requires authorizer",
+		"upstream missing for downstream c not found",
+		"mtls authorizer of downstream d requires clientCAFile in tls of httpproxy :8000",
+		"clashes with",
+		"oidc authorizer doesn't support session store",
+	} {
+		if !hasDiagnostic(diags, summary) {
+			t.Errorf("problem %q not reported, got %v", summary, diags)
+		}
+	}
+}
+
+func hasDiagnostic(diags hcl.Diagnostics, summary string) bool {
+	for _, d := range diags {
+		if d.Severity == hcl.DiagError && strings.Contains(d.Summary, summary) {
+			return true
+		}
+	}
+	return false
+}
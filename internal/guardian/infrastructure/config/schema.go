@@ -0,0 +1,222 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+const jsonSchemaVersion = "https://json-schema.org/draft/2020-12/schema"
+
+var bodyType = reflect.TypeOf((*hcl.Body)(nil)).Elem()
+
+// payloads describes blocks which content depends on type label
+var payloads = map[reflect.Type]map[string]any{
+	reflect.TypeOf(userProvider{}): {
+		ldapUserProviderType:     ldapUserProvider{},
+		staticUserProviderType:   staticUserProvider{},
+		httpUserProviderType:     httpUserProvider{},
+		htpasswdUserProviderType: htpasswdUserProvider{},
+	},
+	reflect.TypeOf(sessionStore{}): {
+		memorySessionStoreType: memorySessionStore{},
+		fileSessionStoreType:   fileSessionStore{},
+	},
+	reflect.TypeOf(rule{}): {
+		hostRuleType:       hostRule{},
+		pathPrefixRuleType: pathPrefixRule{},
+	},
+	reflect.TypeOf(downstreamAuthorizer{}): {
+		cookieDownstreamAuthorizerType:  cookieDownstreamAuthorizer{},
+		jwtDownstreamAuthorizerType:     jwtDownstreamAuthorizer{},
+		oidcDownstreamAuthorizerType:    oidcDownstreamAuthorizer{},
+		loginDownstreamAuthorizerType:   loginDownstreamAuthorizer{},
+		basicDownstreamAuthorizerType:   basicDownstreamAuthorizer{},
+		apikeyDownstreamAuthorizerType:  apikeyDownstreamAuthorizer{},
+		forwardDownstreamAuthorizerType: forwardDownstreamAuthorizer{},
+		mtlsDownstreamAuthorizerType:    mtlsDownstreamAuthorizer{},
+	},
+	reflect.TypeOf(downstreamMFA{}): {
+		totpDownstreamMFAType: totpDownstreamMFA{},
+	},
+	reflect.TypeOf(upstreamAuthorizer{}): {
+		headerUpstreamAuthorizerType: headerUpstreamAuthorizer{},
+	},
+}
+
+// Schema returns JSON Schema of config written in JSON syntax
+func Schema() map[string]any {
+	s := objectSchema(reflect.TypeOf(appConfig{}))
+	s["$schema"] = jsonSchemaVersion
+	s["title"] = "guardian config"
+	return s
+}
+
+type schemaLabel struct {
+	name string
+	// payload maps type label to content of block
+	payload map[string]any
+}
+
+func objectSchema(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, ok := field.Tag.Lookup("hcl")
+		if !ok || field.Type == bodyType {
+			continue
+		}
+
+		name, kind, _ := strings.Cut(tag, ",")
+		switch kind {
+		case "", "attr":
+			properties[name] = attrSchema(field.Type)
+			required = append(required, name)
+		case "optional":
+			properties[name] = attrSchema(field.Type)
+		case "block":
+			properties[name] = blockSchema(field.Type)
+			if field.Type.Kind() == reflect.Struct {
+				required = append(required, name)
+			}
+		}
+	}
+
+	s := map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) != 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+// blockSchema describes block in JSON syntax: labels are nested object keys,
+// block may be defined as object or array of objects
+func blockSchema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+
+	var labels []schemaLabel
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, kind, _ := strings.Cut(field.Tag.Get("hcl"), ",")
+		if kind == "label" {
+			labels = append(labels, schemaLabel{name: name})
+		}
+	}
+
+	body := objectSchema(t)
+	if p, ok := payloads[t]; ok && len(labels) != 0 {
+		// payload type selected by first label
+		labels[0].payload = p
+	}
+
+	return oneOrMany(labeledSchema(labels, body))
+}
+
+func labeledSchema(labels []schemaLabel, body map[string]any) map[string]any {
+	if len(labels) == 0 {
+		return body
+	}
+
+	l := labels[0]
+	nested := func(b map[string]any) map[string]any {
+		return oneOrMany(labeledSchema(labels[1:], b))
+	}
+
+	if l.payload == nil {
+		return map[string]any{
+			"type":                 "object",
+			"description":          l.name,
+			"additionalProperties": nested(body),
+		}
+	}
+
+	properties := map[string]any{}
+	for typ, payload := range l.payload {
+		properties[typ] = nested(mergeObjects(body, objectSchema(reflect.TypeOf(payload))))
+	}
+
+	return map[string]any{
+		"type":                 "object",
+		"description":          l.name,
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+func mergeObjects(a, b map[string]any) map[string]any {
+	properties := map[string]any{}
+	for k, v := range a["properties"].(map[string]any) {
+		properties[k] = v
+	}
+	for k, v := range b["properties"].(map[string]any) {
+		properties[k] = v
+	}
+
+	var required []string
+	for _, s := range []map[string]any{a, b} {
+		if r, ok := s["required"].([]string); ok {
+			required = append(required, r...)
+		}
+	}
+
+	res := map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) != 0 {
+		res["required"] = required
+	}
+	return res
+}
+
+func oneOrMany(s map[string]any) map[string]any {
+	return map[string]any{
+		"anyOf": []any{
+			s,
+			map[string]any{
+				"type":  "array",
+				"items": s,
+			},
+		},
+	}
+}
+
+// attrSchema describes attribute, every attribute also accepts string since it may hold template expression
+func attrSchema(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64, reflect.Float64:
+		return map[string]any{"type": []string{"number", "string"}}
+	case reflect.Bool:
+		return map[string]any{"type": []string{"boolean", "string"}}
+	case reflect.Slice:
+		return map[string]any{
+			"anyOf": []any{
+				map[string]any{"type": "array", "items": attrSchema(t.Elem())},
+				map[string]any{"type": "string"},
+			},
+		}
+	case reflect.Map:
+		return map[string]any{
+			"anyOf": []any{
+				map[string]any{"type": "object", "additionalProperties": attrSchema(t.Elem())},
+				map[string]any{"type": "string"},
+			},
+		}
+	case reflect.Ptr:
+		return attrSchema(t.Elem())
+	default:
+		return map[string]any{}
+	}
+}
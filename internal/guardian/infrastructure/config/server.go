@@ -5,29 +5,87 @@ import (
 )
 
 type tcpProxy struct {
-	SrcAdress  string `hcl:"src,label"`
-	DstAddress string `hcl:"destination"`
+	SrcAdress  string   `hcl:"src,label"`
+	Body       hcl.Body `hcl:",body"`
+	DstAddress string   `hcl:"destination"`
 }
 
 type httpProxy struct {
-	Address string `hcl:"address,label"`
+	Address string   `hcl:"address,label"`
+	Body    hcl.Body `hcl:",body"`
 
-	Limit limit `hcl:"limit,block"`
+	Limit *limit       `hcl:"limit,block"`
+	TLS   *listenerTLS `hcl:"tls,block"`
 
 	Downstream []downstream `hcl:"downstream,block"`
 	Upstream   []upstream   `hcl:"upstream,block"`
 }
 
+// listenerTLS serves https and verifies client certificates
+type listenerTLS struct {
+	Body     hcl.Body `hcl:",body"`
+	CertFile string   `hcl:"certFile"`
+	KeyFile  string   `hcl:"keyFile"`
+
+	// ClientCAFile holds PEM bundle verifying client certificates
+	ClientCAFile string `hcl:"clientCAFile,optional"`
+	// ClientAuth is none, optional or require, require by default when clientCAFile set
+	ClientAuth string `hcl:"clientAuth,optional"`
+	// CRLFile is PEM or DER CRL of client CA, reloaded on change
+	CRLFile string `hcl:"crlFile,optional"`
+}
+
 type limit struct {
-	RPS   int `hcl:"rps"`
-	Burst int `hcl:"burst"`
+	Body  hcl.Body `hcl:",body"`
+	RPS   int      `hcl:"rps"`
+	Burst int      `hcl:"burst"`
 }
 
 type downstream struct {
-	ID         string                `hcl:"id,label"`
-	UpstreamID string                `hcl:"upstream"`
-	Rules      []rule                `hcl:"rule,block"`
-	Authorizer *downstreamAuthorizer `hcl:"authorizer,block"`
+	ID         string   `hcl:"id,label"`
+	Body       hcl.Body `hcl:",body"`
+	UpstreamID string   `hcl:"upstream"`
+	Rules      []rule   `hcl:"rule,block"`
+	// Authorizers applied in order according to AuthMode
+	Authorizers []downstreamAuthorizer `hcl:"authorizer,block"`
+	// AuthMode is first or all: first succeeded authorizer resolves user or all of them must succeed
+	AuthMode string `hcl:"authMode,optional"`
+	// Optional lets unauthenticated requests through without user
+	Optional bool `hcl:"optional,optional"`
+	// OnUnauthenticated redirects browsers failed authentication to login page
+	OnUnauthenticated *onUnauthenticated `hcl:"onUnauthenticated,block"`
+	Policy            *policy            `hcl:"policy,block"`
+	// MFA requires second factor from authenticated users
+	MFA *downstreamMFA `hcl:"mfa,block"`
+}
+
+type onUnauthenticated struct {
+	Body hcl.Body `hcl:",body"`
+	// LoginURL is absolute URL or path on same host
+	LoginURL string `hcl:"loginURL"`
+	// ReturnToParam holds original URL, return_to by default
+	ReturnToParam string `hcl:"returnToParam,optional"`
+	// Mode is negotiate, redirect or unauthorized
+	Mode string `hcl:"mode,optional"`
+}
+
+// policy allows or denies access to downstream for authorized users, deny rules win over allow ones
+type policy struct {
+	Body hcl.Body `hcl:",body"`
+	// Default is allow or deny, applied when no rule matched. Deny by default
+	Default string       `hcl:"default,optional"`
+	Allow   []policyRule `hcl:"allow,block"`
+	Deny    []policyRule `hcl:"deny,block"`
+}
+
+type policyRule struct {
+	Name string `hcl:"name,label"`
+	// Paths are path prefixes
+	Paths      []string          `hcl:"paths,optional"`
+	Methods    []string          `hcl:"methods,optional"`
+	Users      []string          `hcl:"users,optional"`
+	Groups     []string          `hcl:"groups,optional"`
+	Attributes map[string]string `hcl:"attributes,optional"`
 }
 
 const (
@@ -37,6 +95,7 @@ const (
 
 type rule struct {
 	Type    string   `hcl:"type,label"`
+	Body    hcl.Body `hcl:",body"`
 	Payload hcl.Body `hcl:",remain"`
 }
 
@@ -50,25 +109,209 @@ type pathPrefixRule struct {
 
 type downstreamAuthorizer struct {
 	Type    string   `hcl:"type,label"`
+	Body    hcl.Body `hcl:",body"`
 	Payload hcl.Body `hcl:",remain"`
 }
 
 type cookieDownstreamAuthorizer struct {
-	Key string `hcl:"key"`
+	Key          string        `hcl:"key"`
+	UserProvider *string       `hcl:"userprovider,optional"`
+	Session      sessionCookie `hcl:"session,block"`
+}
+
+// sessionCookie configures signing of session cookies or server-side sessions when store set
+type sessionCookie struct {
+	Encrypt bool `hcl:"encrypt,optional"`
+	// Keys verify sessions, first one signs new sessions
+	Keys []sessionKey `hcl:"key,block"`
+
+	// Store refers sessionstore, cookie holds only session ID then
+	Store           *string `hcl:"store,optional"`
+	AbsoluteTimeout string  `hcl:"absoluteTimeout,optional"`
+	IdleTimeout     string  `hcl:"idleTimeout,optional"`
+}
+
+type sessionKey struct {
+	ID     string `hcl:"id,label"`
+	Secret string `hcl:"secret"`
+}
+
+type jwtDownstreamAuthorizer struct {
+	// Cookie holds token when request has no Authorization: Bearer header
+	Cookie string `hcl:"cookie,optional"`
+	// UserProvider resolves user by ID from token, user built from claims when omitted
+	UserProvider *string `hcl:"userprovider,optional"`
+
+	Keys     []jwtKey `hcl:"key,block"`
+	JWKSFile string   `hcl:"jwksFile,optional"`
+
+	Issuer   string     `hcl:"issuer,optional"`
+	Audience string     `hcl:"audience,optional"`
+	Leeway   string     `hcl:"leeway,optional"`
+	Claims   *jwtClaims `hcl:"claims,block"`
+}
+
+type jwtKey struct {
+	// ID matched with kid header of token
+	ID        string `hcl:"id,label"`
+	Algorithm string `hcl:"algorithm"`
+	// Secret of HS256 key
+	Secret string `hcl:"secret,optional"`
+	// PublicKey in PEM of RS256 and ES256 keys
+	PublicKey string `hcl:"publicKey,optional"`
+}
+
+type oidcDownstreamAuthorizer struct {
+	Issuer       string `hcl:"issuer"`
+	ClientID     string `hcl:"clientID"`
+	ClientSecret string `hcl:"clientSecret,optional"`
+	// RedirectURL is callback registered in identity provider, downstream must match its path
+	RedirectURL string         `hcl:"redirectURL"`
+	Scopes      []string       `hcl:"scopes,optional"`
+	Endpoints   *oidcEndpoints `hcl:"endpoints,block"`
+	TLS         *tls           `hcl:"tls,block"`
+
+	LogoutPath            string `hcl:"logoutPath,optional"`
+	PostLogoutRedirectURL string `hcl:"postLogoutRedirectURL,optional"`
+
+	Cookie        string        `hcl:"cookie,optional"`
+	Session       sessionCookie `hcl:"session,block"`
+	SessionTTL    string        `hcl:"sessionTTL,optional"`
+	SessionMaxAge string        `hcl:"sessionMaxAge,optional"`
+
+	Claims *jwtClaims `hcl:"claims,block"`
+	// UserProvider resolves user by ID from ID token, user built from claims when omitted
+	UserProvider *string `hcl:"userprovider,optional"`
+}
+
+// oidcEndpoints override endpoints discovered from issuer
+type oidcEndpoints struct {
+	Authorization string `hcl:"authorization,optional"`
+	Token         string `hcl:"token,optional"`
+	JWKS          string `hcl:"jwks,optional"`
+	EndSession    string `hcl:"endSession,optional"`
+}
+
+type loginDownstreamAuthorizer struct {
+	// Path serves login page and form posted to it, /login by default
+	Path          string `hcl:"path,optional"`
+	LogoutPath    string `hcl:"logoutPath,optional"`
+	ReturnToParam string `hcl:"returnToParam,optional"`
+	// Template overrides built-in login page, reloaded on change
+	Template string `hcl:"template,optional"`
+
+	Cookie     string        `hcl:"cookie,optional"`
+	Session    sessionCookie `hcl:"session,block"`
+	SessionTTL string        `hcl:"sessionTTL,optional"`
+	// UserProvider checks credentials, it must support them like ldap, static and htpasswd
+	UserProvider *string `hcl:"userprovider,optional"`
+}
+
+type basicDownstreamAuthorizer struct {
+	// File in htpasswd format with bcrypt or {SHA} hashes, reloaded on change
+	File  string `hcl:"file"`
+	Realm string `hcl:"realm,optional"`
+}
+
+type apikeyDownstreamAuthorizer struct {
+	// File is JSON array of keys, reloaded on change
+	File string `hcl:"file"`
+	// Header holds key, X-API-Key by default
+	Header string `hcl:"header,optional"`
+	Query  string `hcl:"query,optional"`
+}
+
+type forwardDownstreamAuthorizer struct {
+	// URL of auth service requested with GET for every request
+	URL string `hcl:"url"`
+	// Headers of request copied to auth request
+	Headers []string `hcl:"headers,optional"`
+	// ResponseHeaders of auth service 3xx, 401 and 403 responses copied to client
+	ResponseHeaders []string `hcl:"responseHeaders,optional"`
+	PassBody        bool     `hcl:"passBody,optional"`
+
+	TLS     *tls            `hcl:"tls,block"`
+	Timeout string          `hcl:"timeout,optional"`
+	Mapping *forwardMapping `hcl:"mapping,block"`
+}
+
+// forwardMapping holds names of auth service response headers mapped to user fields
+type forwardMapping struct {
+	ID          string            `hcl:"id,optional"`
+	Username    string            `hcl:"username,optional"`
+	Email       string            `hcl:"email,optional"`
+	DisplayName string            `hcl:"displayName,optional"`
+	Groups      string            `hcl:"groups,optional"`
+	Attributes  map[string]string `hcl:"attributes,optional"`
+}
+
+type mtlsDownstreamAuthorizer struct {
+	// fields of client certificate mapped to user
+	ID          string            `hcl:"id,optional"`
+	Username    string            `hcl:"username,optional"`
+	Email       string            `hcl:"email,optional"`
+	DisplayName string            `hcl:"displayName,optional"`
+	Groups      string            `hcl:"groups,optional"`
+	Attributes  map[string]string `hcl:"attributes,optional"`
+	// UserProvider resolves user by ID from certificate, user built from certificate when omitted
+	UserProvider *string `hcl:"userprovider,optional"`
+}
+
+type jwtClaims struct {
+	ID          string            `hcl:"id,optional"`
+	Username    string            `hcl:"username,optional"`
+	Email       string            `hcl:"email,optional"`
+	DisplayName string            `hcl:"displayName,optional"`
+	Groups      string            `hcl:"groups,optional"`
+	Attributes  map[string]string `hcl:"attributes,optional"`
 }
 
 const (
-	cookieDownstreamAuthorizerType = "cookie"
+	cookieDownstreamAuthorizerType  = "cookie"
+	jwtDownstreamAuthorizerType     = "jwt"
+	oidcDownstreamAuthorizerType    = "oidc"
+	basicDownstreamAuthorizerType   = "basic"
+	apikeyDownstreamAuthorizerType  = "apikey"
+	forwardDownstreamAuthorizerType = "forward"
+	mtlsDownstreamAuthorizerType    = "mtls"
+	loginDownstreamAuthorizerType   = "login"
 )
 
+type downstreamMFA struct {
+	Type    string   `hcl:"type,label"`
+	Body    hcl.Body `hcl:",body"`
+	Payload hcl.Body `hcl:",remain"`
+}
+
+const (
+	totpDownstreamMFAType = "totp"
+)
+
+type totpDownstreamMFA struct {
+	// Secrets is JSON file of enrolled users written by guardian totp enroll, reloaded on change
+	Secrets string `hcl:"secrets"`
+	// Window is how long passed check is valid, 12h by default
+	Window string `hcl:"window,optional"`
+	// Path serves challenge page and form posted to it, /mfa by default
+	Path          string `hcl:"path,optional"`
+	ReturnToParam string `hcl:"returnToParam,optional"`
+	// Template overrides built-in challenge page, reloaded on change
+	Template string `hcl:"template,optional"`
+
+	Cookie  string        `hcl:"cookie,optional"`
+	Session sessionCookie `hcl:"session,block"`
+}
+
 type upstream struct {
 	ID         string              `hcl:"id,label"`
+	Body       hcl.Body            `hcl:",body"`
 	Address    string              `hcl:"address"`
 	Authorizer *upstreamAuthorizer `hcl:"authorizer,block"`
 }
 
 type upstreamAuthorizer struct {
 	Type    string   `hcl:"type,label"`
+	Body    hcl.Body `hcl:",body"`
 	Payload hcl.Body `hcl:",remain"`
 }
 
@@ -77,6 +320,8 @@ const (
 )
 
 type headerUpstreamAuthorizer struct {
-	UserID   string `hcl:"userID"`
-	Username string `hcl:"username"`
+	UserID   string `hcl:"userID,optional"`
+	Username string `hcl:"username,optional"`
+	// Headers maps header name to user field: email, displayName, groups, attributes.<name>
+	Headers map[string]string `hcl:"headers,optional"`
 }
@@ -0,0 +1,194 @@
+package config
+
+import (
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/UsingCoding/fpgo/pkg/slices"
+	"github.com/hashicorp/hcl/v2"
+
+	"guardian/internal/guardian/app/session"
+	"guardian/internal/guardian/infrastructure/sessioncookie"
+	"guardian/internal/guardian/infrastructure/sessionstore"
+)
+
+// mapSession returns codec of server-side sessions when session refers store, otherwise codec of signed cookies.
+// Store keeps only user ID, so authorizers using store resolve user by provider
+func mapSession(s sessionCookie, stores *sessionStores, body hcl.Body) (session.Codec, error) {
+	if s.Store == nil {
+		if s.AbsoluteTimeout != "" || s.IdleTimeout != "" {
+			return nil, diagnostic(body, "absoluteTimeout and idleTimeout require session store")
+		}
+		return mapSessionCookie(s, body)
+	}
+
+	if len(s.Keys) != 0 || s.Encrypt {
+		return nil, diagnostic(body, "session with store must not have keys, cookie holds only session ID")
+	}
+
+	absoluteTimeout, err := parseDuration(s.AbsoluteTimeout, body)
+	if err != nil {
+		return nil, err
+	}
+	idleTimeout, err := parseDuration(s.IdleTimeout, body)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := stores.resolve(*s.Store, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return session.NewStoreCodec(store, session.StoreConfig{
+		AbsoluteTimeout: absoluteTimeout,
+		IdleTimeout:     idleTimeout,
+	}), nil
+}
+
+func mapSessionCookie(s sessionCookie, body hcl.Body) (*sessioncookie.Codec, error) {
+	if len(s.Keys) == 0 {
+		return nil, diagnostic(body, "session requires at least one key")
+	}
+
+	keys := slices.Map(s.Keys, func(k sessionKey) sessioncookie.Key {
+		return sessioncookie.Key{
+			ID:     k.ID,
+			Secret: []byte(k.Secret),
+		}
+	})
+
+	codec, err := sessioncookie.NewCodec(keys, s.Encrypt)
+	if err != nil {
+		return nil, diagnostic(body, "%s", err)
+	}
+	return codec, nil
+}
+
+// NewSessionStores returns registry of session stores shared by parses
+func NewSessionStores() *SessionStores {
+	return &SessionStores{
+		stores: map[string]session.Store{},
+	}
+}
+
+// SessionStores keeps stores between config reloads, so sessions survive reload.
+// Memory stores reused by ID, file stores by path
+type SessionStores struct {
+	mu     sync.Mutex
+	stores map[string]session.Store
+}
+
+func (r *SessionStores) get(key string, create func() (session.Store, error)) (session.Store, error) {
+	if r == nil {
+		return create()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if s, ok := r.stores[key]; ok {
+		return s, nil
+	}
+
+	s, err := create()
+	if err != nil {
+		return nil, err
+	}
+	r.stores[key] = s
+	return s, nil
+}
+
+// sessionStores resolves session stores referenced by authorizers and tracks which of them are used
+type sessionStores struct {
+	stores map[string]session.Store
+	bodies map[string]hcl.Body
+	used   map[string]bool
+}
+
+func mapSessionStores(stores []sessionStore, registry *SessionStores) (*sessionStores, error) {
+	res := &sessionStores{
+		stores: map[string]session.Store{},
+		bodies: map[string]hcl.Body{},
+		used:   map[string]bool{},
+	}
+
+	for _, s := range stores {
+		store, err := mapSessionStore(s, registry)
+		if err != nil {
+			return nil, err
+		}
+
+		res.stores[s.ID] = store
+		res.bodies[s.ID] = s.Body
+	}
+
+	return res, nil
+}
+
+func mapSessionStore(s sessionStore, registry *SessionStores) (session.Store, error) {
+	switch s.Type {
+	case memorySessionStoreType:
+		_, err := decodeHclBody[memorySessionStore](s.Payload)
+		if err != nil {
+			return nil, err
+		}
+
+		return registry.get(memorySessionStoreType+":"+s.ID, func() (session.Store, error) {
+			return sessionstore.NewMemoryStore(), nil
+		})
+	case fileSessionStoreType:
+		p, err := decodeHclBody[fileSessionStore](s.Payload)
+		if err != nil {
+			return nil, err
+		}
+
+		path, err := filepath.Abs(resolvePath(p.Path, s.Body))
+		if err != nil {
+			return nil, diagnostic(s.Body, "%s", err)
+		}
+
+		store, err := registry.get(fileSessionStoreType+":"+path, func() (session.Store, error) {
+			return sessionstore.NewFileStore(path)
+		})
+		if err != nil {
+			return nil, diagnostic(s.Body, "%s", err)
+		}
+		return store, nil
+	default:
+		return nil, diagnostic(s.Body, "unknown session store type %s", s.Type)
+	}
+}
+
+func (s *sessionStores) resolve(ref string, body hcl.Body) (session.Store, error) {
+	store, ok := s.stores[ref]
+	if !ok {
+		return nil, diagnostic(body, "unknown sessionstore %s", ref)
+	}
+
+	s.used[ref] = true
+	return store, nil
+}
+
+// checkUsed reports stores not referenced by any session
+func (s *sessionStores) checkUsed() error {
+	ids := make([]string, 0, len(s.stores))
+	for id := range s.stores {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var diags hcl.Diagnostics
+	for _, id := range ids {
+		if s.used[id] {
+			continue
+		}
+		diags = append(diags, diagnostic(s.bodies[id], "sessionstore %s is not used by any session", id)...)
+	}
+
+	if diags.HasErrors() {
+		return diags
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	cryptotls "crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"os"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// mapTLS builds client tls config, nil config means defaults
+func mapTLS(t *tls, body hcl.Body) (*cryptotls.Config, error) {
+	if t == nil {
+		return nil, nil
+	}
+
+	//nolint:gosec
+	config := &cryptotls.Config{
+		MinVersion:         cryptotls.VersionTLS12,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+
+	if t.CAFile != "" {
+		data, err := os.ReadFile(resolvePath(t.CAFile, body))
+		if err != nil {
+			return nil, diagnostic(body, "failed to read CA file: %s", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, diagnostic(body, "no certificates found in CA file %s", t.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	return config, nil
+}
+
+// mapTransport builds http transport with client tls config, nil transport means http.DefaultTransport
+func mapTransport(t *tls, body hcl.Body) (http.RoundTripper, error) {
+	tlsConfig, err := mapTLS(t, body)
+	if err != nil || tlsConfig == nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
@@ -0,0 +1,328 @@
+package config
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/UsingCoding/fpgo/pkg/maybe"
+	"github.com/UsingCoding/fpgo/pkg/slices"
+	"github.com/gofrs/uuid/v5"
+	"github.com/hashicorp/hcl/v2"
+
+	"guardian/internal/common/infrastructure/filewatch"
+	"guardian/internal/guardian/app/user"
+	"guardian/internal/guardian/infrastructure/htpasswd"
+	"guardian/internal/guardian/infrastructure/httpuser"
+	"guardian/internal/guardian/infrastructure/ldap"
+	"guardian/internal/guardian/infrastructure/static"
+)
+
+// userProviders resolves user providers referenced by authorizers and tracks which of them are used
+type userProviders struct {
+	providers map[string]user.Provider
+	bodies    map[string]hcl.Body
+	used      map[string]bool
+}
+
+func mapUserProviders(providers []userProvider) (*userProviders, error) {
+	res := &userProviders{
+		providers: map[string]user.Provider{},
+		bodies:    map[string]hcl.Body{},
+		used:      map[string]bool{},
+	}
+
+	for _, p := range providers {
+		provider, err := mapUserProvider(p)
+		if err != nil {
+			return nil, err
+		}
+
+		if p.Cache != nil {
+			provider, err = mapUserProviderCache(provider, *p.Cache, p.Body)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		res.providers[p.ID] = provider
+		res.bodies[p.ID] = p.Body
+	}
+
+	return res, nil
+}
+
+func mapUserProvider(provider userProvider) (user.Provider, error) {
+	switch provider.Type {
+	case ldapUserProviderType:
+		p, err := decodeHclBody[ldapUserProvider](provider.Payload)
+		if err != nil {
+			return nil, err
+		}
+
+		return mapLDAPUserProvider(p, provider.Body)
+	case staticUserProviderType:
+		p, err := decodeHclBody[staticUserProvider](provider.Payload)
+		if err != nil {
+			return nil, err
+		}
+
+		return mapStaticUserProvider(p, provider.Body)
+	case httpUserProviderType:
+		p, err := decodeHclBody[httpUserProvider](provider.Payload)
+		if err != nil {
+			return nil, err
+		}
+
+		return mapHTTPUserProvider(p, provider.Body)
+	case htpasswdUserProviderType:
+		p, err := decodeHclBody[htpasswdUserProvider](provider.Payload)
+		if err != nil {
+			return nil, err
+		}
+
+		file, err := filewatch.NewFile(resolvePath(p.File, provider.Body), filewatch.DefaultInterval, htpasswd.ParseIndexed)
+		if err != nil {
+			return nil, diagnostic(provider.Body, "%s", err)
+		}
+		return htpasswd.NewUserProvider(file), nil
+	default:
+		return nil, diagnostic(provider.Body, "unknown user provider type %s", provider.Type)
+	}
+}
+
+func mapUserProviderCache(provider user.Provider, c userProviderCache, body hcl.Body) (user.Provider, error) {
+	ttl, err := parseDuration(c.TTL, body)
+	if err != nil {
+		return nil, err
+	}
+	negativeTTL, err := parseDuration(c.NegativeTTL, body)
+	if err != nil {
+		return nil, err
+	}
+	staleTTL, err := parseDuration(c.StaleTTL, body)
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := parseDuration(c.Timeout, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return user.NewCachingProvider(provider, user.CacheConfig{
+		TTL:         ttl,
+		NegativeTTL: negativeTTL,
+		MaxEntries:  c.MaxEntries,
+		StaleTTL:    staleTTL,
+		Timeout:     timeout,
+	}), nil
+}
+
+func mapLDAPUserProvider(p ldapUserProvider, body hcl.Body) (user.Provider, error) {
+	timeout, err := parseDuration(p.Timeout, body)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := mapTLS(p.TLS, body)
+	if err != nil {
+		return nil, err
+	}
+
+	var attributes ldap.Attributes
+	if p.Attributes != nil {
+		attributes = ldap.Attributes{
+			ID:          p.Attributes.ID,
+			Username:    p.Attributes.Username,
+			Email:       p.Attributes.Email,
+			DisplayName: p.Attributes.DisplayName,
+			Groups:      p.Attributes.Groups,
+			Extra:       p.Attributes.Extra,
+		}
+	}
+
+	return ldap.NewUserProvider(ldap.Config{
+		Address:      p.Address,
+		StartTLS:     p.StartTLS,
+		TLS:          tlsConfig,
+		BindDN:       p.BindDN,
+		BindPassword: p.BindPassword,
+		BaseDN:       p.BaseDN,
+		Filter:       p.Filter,
+		LoginFilter:  p.LoginFilter,
+		Attributes:   attributes,
+		PoolSize:     p.PoolSize,
+		Timeout:      timeout,
+	}), nil
+}
+
+func mapStaticUserProvider(p staticUserProvider, body hcl.Body) (user.Provider, error) {
+	users, err := slices.MapErr(p.Users, func(u staticUser) (static.User, error) {
+		id, err2 := uuid.FromString(u.ID)
+		if err2 != nil {
+			return static.User{}, diagnostic(body, "invalid id of static user %s: %s", u.ID, err2)
+		}
+
+		return static.User{
+			ID:          id,
+			Username:    u.Username,
+			Email:       u.Email,
+			DisplayName: u.DisplayName,
+			Groups:      u.Groups,
+			Attributes:  u.Attributes,
+			// hashes validated by static.NewUserProvider
+			PasswordHash: u.PasswordHash,
+		}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var file *filewatch.File[static.Users]
+	if p.File != "" {
+		path := resolvePath(p.File, body)
+		file, err = filewatch.NewFile(path, filewatch.DefaultInterval, static.ParseUsersFile(path))
+		if err != nil {
+			return nil, diagnostic(body, "%s", err)
+		}
+	}
+
+	provider, err := static.NewUserProvider(users, file)
+	if err != nil {
+		return nil, diagnostic(body, "%s", err)
+	}
+	return provider, nil
+}
+
+func mapHTTPUserProvider(p httpUserProvider, body hcl.Body) (user.Provider, error) {
+	if !strings.Contains(p.URL, httpuser.IDPlaceholder) {
+		return nil, diagnostic(body, "url %s must contain %s placeholder", p.URL, httpuser.IDPlaceholder)
+	}
+
+	timeout, err := parseDuration(p.Timeout, body)
+	if err != nil {
+		return nil, err
+	}
+
+	transport, err := mapTransport(p.TLS, body)
+	if err != nil {
+		return nil, err
+	}
+
+	var mapping httpuser.Mapping
+	if p.Mapping != nil {
+		paths := map[*httpuser.Path]string{
+			&mapping.ID:          p.Mapping.ID,
+			&mapping.Username:    p.Mapping.Username,
+			&mapping.Email:       p.Mapping.Email,
+			&mapping.DisplayName: p.Mapping.DisplayName,
+			&mapping.Groups:      p.Mapping.Groups,
+		}
+		for path, s := range paths {
+			*path, err = parseOptionalPath(s, body)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		mapping.Attributes = make(map[string]httpuser.Path, len(p.Mapping.Attributes))
+		for name, s := range p.Mapping.Attributes {
+			mapping.Attributes[name], err = parseOptionalPath(s, body)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var auth *httpuser.BasicAuth
+	if p.BasicAuth != nil {
+		auth = &httpuser.BasicAuth{
+			Username: p.BasicAuth.Username,
+			Password: p.BasicAuth.Password,
+		}
+	}
+
+	return httpuser.NewUserProvider(httpuser.Config{
+		URL:       p.URL,
+		Headers:   p.Headers,
+		BasicAuth: auth,
+		Mapping:   mapping,
+		Timeout:   timeout,
+		Transport: transport,
+	}), nil
+}
+
+func parseOptionalPath(s string, body hcl.Body) (httpuser.Path, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	path, err := httpuser.ParsePath(s)
+	if err != nil {
+		return nil, diagnostic(body, "invalid mapping %s: %s", s, err)
+	}
+	return path, nil
+}
+
+// resolveOptional returns provider only when it's referenced
+func (p *userProviders) resolveOptional(ref *string, body hcl.Body) (maybe.Maybe[user.Provider], error) {
+	if ref == nil {
+		return maybe.Maybe[user.Provider]{}, nil
+	}
+
+	provider, err := p.resolve(ref, body)
+	if err != nil {
+		return maybe.Maybe[user.Provider]{}, err
+	}
+	return maybe.NewJust(provider), nil
+}
+
+// resolve returns provider referenced by authorizer defined in body.
+// Reference may be omitted when only one provider configured
+func (p *userProviders) resolve(ref *string, body hcl.Body) (user.Provider, error) {
+	if ref == nil {
+		switch len(p.providers) {
+		case 0:
+			return nil, diagnostic(body, "authorizer requires user provider but no user provider configured")
+		case 1:
+			for id, provider := range p.providers {
+				p.used[id] = true
+				return provider, nil
+			}
+		default:
+			return nil, diagnostic(body, "authorizer must specify userprovider, available: %s", strings.Join(p.ids(), ", "))
+		}
+	}
+
+	provider, ok := p.providers[*ref]
+	if !ok {
+		return nil, diagnostic(body, "unknown userprovider %s", *ref)
+	}
+
+	p.used[*ref] = true
+	return provider, nil
+}
+
+// checkUsed reports providers not referenced by any authorizer
+func (p *userProviders) checkUsed() error {
+	var diags hcl.Diagnostics
+	for _, id := range p.ids() {
+		if p.used[id] {
+			continue
+		}
+		diags = append(diags, diagnostic(p.bodies[id], "userprovider %s is not used by any authorizer", id)...)
+	}
+
+	if diags.HasErrors() {
+		return diags
+	}
+	return nil
+}
+
+func (p *userProviders) ids() []string {
+	ids := make([]string, 0, len(p.providers))
+	for id := range p.providers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,252 @@
+package forwardauth
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/pkg/errors"
+
+	"guardian/internal/guardian/app/proxy/downstream"
+	"guardian/internal/guardian/app/user"
+)
+
+const (
+	DefaultTimeout = 5 * time.Second
+
+	// groupsSeparator separates groups in response header
+	groupsSeparator = ","
+
+	maxBodySize = 64 << 10
+)
+
+// DefaultHeaders of request copied to auth request
+var DefaultHeaders = []string{"Authorization", "Cookie"}
+
+// DefaultResponseHeaders of denial copied to client
+var DefaultResponseHeaders = []string{"Location", "WWW-Authenticate", "Set-Cookie"}
+
+type Config struct {
+	// URL of auth service, requested with GET
+	URL string
+	// Headers of request copied to auth request, DefaultHeaders when empty
+	Headers []string
+	// ResponseHeaders of auth service denial copied to client, DefaultResponseHeaders when empty
+	ResponseHeaders []string
+	// PassBody passes body of auth service denial to client, generic status text written otherwise
+	PassBody bool
+
+	Mapping Mapping
+	Timeout time.Duration
+
+	// Transport used for requests, http.DefaultTransport when nil
+	Transport http.RoundTripper
+}
+
+// Mapping holds names of auth service response headers mapped to user.Descriptor
+type Mapping struct {
+	ID          string            `json:"id"`
+	Username    string            `json:"username"`
+	Email       string            `json:"email"`
+	DisplayName string            `json:"displayName"`
+	Groups      string            `json:"groups"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+}
+
+func (m Mapping) WithDefaults() Mapping {
+	defaults := map[*string]string{
+		&m.ID:          "X-User-ID",
+		&m.Username:    "X-Username",
+		&m.Email:       "X-Email",
+		&m.DisplayName: "X-Display-Name",
+		&m.Groups:      "X-Groups",
+	}
+	for field, header := range defaults {
+		if *field == "" {
+			*field = header
+		}
+	}
+	return m
+}
+
+// NewAuthorizer returns authorizer delegating decision to external auth service like nginx auth_request.
+// Auth request carries original method, URI and host in X-Forwarded-Method, X-Forwarded-Uri and X-Forwarded-Host headers
+func NewAuthorizer(config Config) downstream.Authorizer {
+	if len(config.Headers) == 0 {
+		config.Headers = DefaultHeaders
+	}
+	if len(config.ResponseHeaders) == 0 {
+		config.ResponseHeaders = DefaultResponseHeaders
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+	config.Mapping = config.Mapping.WithDefaults()
+
+	transport := config.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	return &authorizer{
+		config: config,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   config.Timeout,
+			// redirects of auth service passed to client
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+type authorizer struct {
+	config Config
+	client *http.Client
+}
+
+func (a *authorizer) Auth(ctx context.Context, r http.Request) (user.Descriptor, error) {
+	req, err := a.request(ctx, r)
+	if err != nil {
+		return user.Descriptor{}, err
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return user.Descriptor{}, errors.Wrapf(user.ErrProviderUnavailable, "forward %s: %s", req.URL.Host, err)
+	}
+	defer func() {
+		// drained body lets connection be reused
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxBodySize))
+		_ = resp.Body.Close()
+	}()
+
+	switch {
+	case resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices:
+		return a.descriptor(resp.Header)
+	case resp.StatusCode == http.StatusUnauthorized:
+		return user.Descriptor{}, a.denial(resp, errors.Wrapf(downstream.ErrAuthDataInvalid, "forward %s: status %d", req.URL.Host, resp.StatusCode))
+	case resp.StatusCode == http.StatusForbidden:
+		return user.Descriptor{}, a.denial(resp, errors.Wrapf(downstream.ErrAuthDenied, "forward %s: status %d", req.URL.Host, resp.StatusCode))
+	case resp.StatusCode >= http.StatusMultipleChoices && resp.StatusCode < http.StatusBadRequest:
+		return user.Descriptor{}, a.denial(resp, errors.Wrapf(downstream.ErrAuthDataNotFound, "forward %s: redirect", req.URL.Host))
+	default:
+		return user.Descriptor{}, errors.Wrapf(user.ErrProviderUnavailable, "forward %s: status %d", req.URL.Host, resp.StatusCode)
+	}
+}
+
+func (a *authorizer) request(ctx context.Context, r http.Request) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.config.URL, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build forward auth request")
+	}
+
+	for _, name := range a.config.Headers {
+		for _, v := range r.Header.Values(name) {
+			req.Header.Add(name, v)
+		}
+	}
+
+	proto := "http"
+	if r.TLS != nil {
+		proto = "https"
+	}
+	req.Header.Set("X-Forwarded-Method", r.Method)
+	req.Header.Set("X-Forwarded-Uri", r.URL.RequestURI())
+	req.Header.Set("X-Forwarded-Host", r.Host)
+	req.Header.Set("X-Forwarded-Proto", proto)
+
+	return req, nil
+}
+
+// denial wraps err with response of auth service passed to client
+func (a *authorizer) denial(resp *http.Response, err error) error {
+	res := &downstream.ErrResponse{
+		StatusCode: resp.StatusCode,
+		Header:     http.Header{},
+		Err:        err,
+	}
+	for _, name := range a.config.ResponseHeaders {
+		for _, v := range resp.Header.Values(name) {
+			res.Header.Add(name, v)
+		}
+	}
+
+	if !a.config.PassBody {
+		return res
+	}
+
+	body, readErr := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if readErr != nil {
+		return errors.Wrapf(user.ErrProviderUnavailable, "forward: failed to read response: %s", readErr)
+	}
+	res.Body = body
+	if ct := resp.Header.Get("Content-Type"); ct != "" {
+		res.Header.Set("Content-Type", ct)
+	}
+	return res
+}
+
+func (a *authorizer) descriptor(header http.Header) (user.Descriptor, error) {
+	m := a.config.Mapping
+	username := header.Get(m.Username)
+
+	var id uuid.UUID
+	switch rawID := header.Get(m.ID); {
+	case rawID != "":
+		var err error
+		id, err = uuid.FromString(rawID)
+		if err != nil {
+			// ids of auth service may be not UUID, so stable one derived
+			id = uuid.NewV5(uuid.NamespaceURL, "forward#"+rawID)
+		}
+	case username != "":
+		id = uuid.NewV5(uuid.NamespaceURL, "forward#"+username)
+	default:
+		// auth service allowed request without telling who user is, so it's misconfigured
+		return user.Descriptor{}, errors.Wrapf(user.ErrProviderUnavailable, "forward: response has neither %s nor %s header", m.ID, m.Username)
+	}
+
+	var groups []string
+	for _, g := range strings.Split(header.Get(m.Groups), groupsSeparator) {
+		if g = strings.TrimSpace(g); g != "" {
+			groups = append(groups, g)
+		}
+	}
+
+	var attributes map[string]string
+	for name, h := range m.Attributes {
+		if v := header.Get(h); v != "" {
+			if attributes == nil {
+				attributes = map[string]string{}
+			}
+			attributes[name] = v
+		}
+	}
+
+	return user.Descriptor{
+		ID:          id,
+		Username:    username,
+		Email:       header.Get(m.Email),
+		DisplayName: header.Get(m.DisplayName),
+		Groups:      groups,
+		Attributes:  attributes,
+	}, nil
+}
+
+func (a *authorizer) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"type":            "forward",
+		"url":             a.config.URL,
+		"headers":         a.config.Headers,
+		"responseHeaders": a.config.ResponseHeaders,
+		"passBody":        a.config.PassBody,
+		"mapping":         a.config.Mapping,
+		"timeout":         a.config.Timeout.String(),
+	})
+}
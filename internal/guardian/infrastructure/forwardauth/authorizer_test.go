@@ -0,0 +1,172 @@
+package forwardauth
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/pkg/errors"
+
+	"guardian/internal/guardian/app/proxy/downstream"
+	"guardian/internal/guardian/app/user"
+)
+
+// newTestService returns auth service answering by Authorization header of original request and counting connections
+func newTestService(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var connections atomic.Int32
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Forwarded-Uri") != "/app?x=1" || r.Header.Get("X-Forwarded-Method") != http.MethodPost ||
+			r.Header.Get("X-Forwarded-Host") != "app.example.com" || r.Header.Get("Cookie") != "session=s1" || r.Header.Get("X-Not-Forwarded") != "" {
+			http.Error(w, "unexpected auth request", http.StatusBadRequest)
+			return
+		}
+
+		switch r.Header.Get("Authorization") {
+		case "alice":
+			w.Header().Set("X-User-ID", "alice-42")
+			w.Header().Set("X-Username", "alice")
+			w.Header().Set("X-Email", "alice@example.com")
+			w.Header().Set("X-Groups", "staff, admins,")
+			w.Header().Set("X-Team", "platform")
+			_, _ = w.Write([]byte(strings.Repeat("ok", 1024)))
+		case "anonymous":
+			_, _ = w.Write([]byte("ok"))
+		case "expired":
+			w.Header().Set("Location", "https://auth.example.com/login")
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			w.Header().Set("X-Internal", "secret")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"token expired"}`))
+		case "intern":
+			http.Error(w, "interns can't", http.StatusForbidden)
+		case "redirect":
+			http.Redirect(w, r, "https://auth.example.com/login", http.StatusFound)
+		default:
+			http.Error(w, "broken", http.StatusInternalServerError)
+		}
+	}))
+	s.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections.Add(1)
+		}
+	}
+	s.Start()
+	t.Cleanup(s.Close)
+	return s, &connections
+}
+
+func authRequest(authorization string) http.Request {
+	r := httptest.NewRequest(http.MethodPost, "http://app.example.com/app?x=1", strings.NewReader("body"))
+	r.Header.Set("Authorization", authorization)
+	r.Header.Set("Cookie", "session=s1")
+	r.Header.Set("X-Not-Forwarded", "1")
+	return *r
+}
+
+func TestAuthorizerAuth(t *testing.T) {
+	s, _ := newTestService(t)
+	a := NewAuthorizer(Config{
+		URL:      s.URL,
+		PassBody: true,
+		Mapping:  Mapping{Attributes: map[string]string{"team": "X-Team"}},
+	})
+
+	descriptor, err := a.Auth(context.Background(), authRequest("alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if descriptor.ID != uuid.NewV5(uuid.NamespaceURL, "forward#alice-42") || descriptor.Username != "alice" ||
+		descriptor.Email != "alice@example.com" || strings.Join(descriptor.Groups, ",") != "staff,admins" ||
+		descriptor.Attributes["team"] != "platform" {
+		t.Errorf("unexpected descriptor %+v", descriptor)
+	}
+
+	tests := []struct {
+		name     string
+		auth     string
+		err      error
+		status   int
+		location string
+		body     string
+	}{
+		// allowed without identity means misconfigured service, not invalid credentials of client
+		{name: "no identity", auth: "anonymous", err: user.ErrProviderUnavailable},
+		{
+			name:     "unauthorized",
+			auth:     "expired",
+			err:      downstream.ErrAuthDataInvalid,
+			status:   http.StatusUnauthorized,
+			location: "https://auth.example.com/login",
+			body:     `{"error":"token expired"}`,
+		},
+		{name: "forbidden", auth: "intern", err: downstream.ErrAuthDenied, status: http.StatusForbidden, body: "interns can't\n"},
+		{
+			name:     "redirect",
+			auth:     "redirect",
+			err:      downstream.ErrAuthDataNotFound,
+			status:   http.StatusFound,
+			location: "https://auth.example.com/login",
+		},
+		{name: "service failed", auth: "broken", err: user.ErrProviderUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := a.Auth(context.Background(), authRequest(tt.auth))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+
+			var response *downstream.ErrResponse
+			if !errors.As(err, &response) {
+				if tt.status != 0 {
+					t.Fatal("response of service not passed")
+				}
+				return
+			}
+			if response.StatusCode != tt.status || response.Header.Get("Location") != tt.location ||
+				!strings.HasPrefix(string(response.Body), tt.body) {
+				t.Errorf("unexpected response %d %v %q", response.StatusCode, response.Header, response.Body)
+			}
+			if response.Header.Get("X-Internal") != "" {
+				t.Error("header not listed in response headers passed")
+			}
+
+			// unauthenticated handling of downstream keeps response of service
+			u := downstream.Unauthenticated{LoginURL: &url.URL{Path: "/login"}, Mode: downstream.ModeRedirect}
+			if responded := u.Respond(authRequest(tt.auth), err); responded != err {
+				t.Errorf("response of service replaced by %v", responded)
+			}
+		})
+	}
+}
+
+func TestAuthorizerReusesConnections(t *testing.T) {
+	s, connections := newTestService(t)
+	a := NewAuthorizer(Config{URL: s.URL})
+
+	for _, auth := range []string{"alice", "alice", "expired", "intern", "alice"} {
+		_, _ = a.Auth(context.Background(), authRequest(auth))
+	}
+	if n := connections.Load(); n != 1 {
+		t.Errorf("responses not drained, %d connections opened", n)
+	}
+}
+
+func TestAuthorizerServiceUnreachable(t *testing.T) {
+	s, _ := newTestService(t)
+	s.Close()
+
+	a := NewAuthorizer(Config{URL: s.URL})
+	if _, err := a.Auth(context.Background(), authRequest("alice")); !errors.Is(err, user.ErrProviderUnavailable) {
+		t.Errorf("got %v, want %v", err, user.ErrProviderUnavailable)
+	}
+}
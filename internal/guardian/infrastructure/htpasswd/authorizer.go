@@ -0,0 +1,67 @@
+package htpasswd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/pkg/errors"
+
+	"guardian/internal/common/infrastructure/filewatch"
+	"guardian/internal/guardian/app/proxy/downstream"
+	"guardian/internal/guardian/app/user"
+)
+
+const DefaultRealm = "guardian"
+
+// NewAuthorizer returns authorizer of users by Authorization: Basic header checked against htpasswd file
+func NewAuthorizer(file *filewatch.File[Users], realm string) downstream.Authorizer {
+	if realm == "" {
+		realm = DefaultRealm
+	}
+	return &authorizer{file: file, realm: realm, verified: newVerifiedCache()}
+}
+
+type authorizer struct {
+	file     *filewatch.File[Users]
+	realm    string
+	verified *verifiedCache
+}
+
+func (a *authorizer) Auth(_ context.Context, r http.Request) (user.Descriptor, error) {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return user.Descriptor{}, a.challenge(errors.WithStack(downstream.ErrAuthDataNotFound))
+	}
+
+	if !a.verified.Verify(a.file.Get(), username, password) {
+		return user.Descriptor{}, a.challenge(errors.Wrapf(downstream.ErrAuthDataInvalid, "basic: invalid credentials of user %s", username))
+	}
+
+	return user.Descriptor{
+		ID:       UserID(username),
+		Username: username,
+	}, nil
+}
+
+func (a *authorizer) challenge(err error) error {
+	return &downstream.ErrChallenge{
+		Challenge: "Basic realm=" + strconv.Quote(a.realm) + `, charset="UTF-8"`,
+		Err:       err,
+	}
+}
+
+func (a *authorizer) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"type":  "basic",
+		"file":  a.file.Path(),
+		"realm": a.realm,
+	})
+}
+
+// UserID derives stable id of htpasswd user since file holds only usernames
+func UserID(username string) uuid.UUID {
+	return uuid.NewV5(uuid.NamespaceURL, "htpasswd#"+username)
+}
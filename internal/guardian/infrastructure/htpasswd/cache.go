@@ -0,0 +1,88 @@
+package htpasswd
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"sync"
+	"time"
+)
+
+const (
+	// verifiedTTL is how long successful check of password reused, so clients sending Basic credentials
+	// with every request don't pay for bcrypt each time
+	verifiedTTL = time.Minute
+	// maxVerified limits number of cached checks
+	maxVerified = 1024
+)
+
+func newVerifiedCache() *verifiedCache {
+	key := make([]byte, 32)
+	// crypto/rand never fails on supported platforms
+	_, _ = rand.Read(key)
+	return &verifiedCache{
+		key:     key,
+		entries: map[[sha256.Size]byte]time.Time{},
+	}
+}
+
+// verifiedCache remembers successful checks by keyed digest of user, hash and password,
+// so passwords aren't kept and changed hash of user invalidates its checks
+type verifiedCache struct {
+	key []byte
+
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]time.Time
+}
+
+// Verify reports whether password matches hash of user, successful checks reused for verifiedTTL
+func (c *verifiedCache) Verify(users Users, username, password string) bool {
+	hash, ok := users[username]
+	if !ok {
+		return CheckPassword("", password)
+	}
+
+	digest := c.digest(username, hash, password)
+	now := time.Now()
+
+	c.mu.Lock()
+	expiresAt, cached := c.entries[digest]
+	c.mu.Unlock()
+	if cached && now.Before(expiresAt) {
+		return true
+	}
+
+	if !CheckPassword(hash, password) {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxVerified {
+		c.sweep(now)
+	}
+	if len(c.entries) < maxVerified {
+		c.entries[digest] = now.Add(verifiedTTL)
+	}
+	return true
+}
+
+func (c *verifiedCache) digest(username, hash, password string) [sha256.Size]byte {
+	mac := hmac.New(sha256.New, c.key)
+	for _, s := range []string{username, hash, password} {
+		mac.Write([]byte(s))
+		mac.Write([]byte{0})
+	}
+
+	var res [sha256.Size]byte
+	copy(res[:], mac.Sum(nil))
+	return res
+}
+
+func (c *verifiedCache) sweep(now time.Time) {
+	for digest, expiresAt := range c.entries {
+		if !now.Before(expiresAt) {
+			delete(c.entries, digest)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package htpasswd
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func bcryptHash(t *testing.T, password string) string {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(hash)
+}
+
+func TestVerifiedCache(t *testing.T) {
+	c := newVerifiedCache()
+	users := Users{"alice": bcryptHash(t, "secret")}
+
+	if c.Verify(users, "alice", "wrong") || len(c.entries) != 0 {
+		t.Fatal("wrong password accepted or cached")
+	}
+	if c.Verify(users, "bob", "secret") {
+		t.Fatal("unknown user accepted")
+	}
+
+	for i := 0; i < 2; i++ {
+		if !c.Verify(users, "alice", "secret") {
+			t.Fatal("valid password rejected")
+		}
+	}
+	if len(c.entries) != 1 {
+		t.Errorf("expected 1 cached check, got %d", len(c.entries))
+	}
+
+	// password changed in file
+	changed := Users{"alice": bcryptHash(t, "new-secret")}
+	if c.Verify(changed, "alice", "secret") {
+		t.Error("old password accepted after change")
+	}
+	if !c.Verify(changed, "alice", "new-secret") {
+		t.Error("new password rejected")
+	}
+
+	// expired checks repeated
+	for digest := range c.entries {
+		c.entries[digest] = time.Now().Add(-time.Second)
+	}
+	if c.Verify(Users{"alice": users["alice"]}, "alice", "wrong") {
+		t.Error("wrong password accepted")
+	}
+	if !c.Verify(users, "alice", "secret") {
+		t.Error("valid password rejected after expiration")
+	}
+}
+
+func TestVerifiedCacheLimit(t *testing.T) {
+	c := newVerifiedCache()
+	users := Users{"alice": "{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ="}
+
+	for i := range maxVerified {
+		c.entries[[32]byte{byte(i), byte(i >> 8)}] = time.Now().Add(time.Minute)
+	}
+	if !c.Verify(users, "alice", "secret") {
+		t.Fatal("valid password rejected")
+	}
+	if len(c.entries) > maxVerified {
+		t.Errorf("cache grown over limit: %d", len(c.entries))
+	}
+}
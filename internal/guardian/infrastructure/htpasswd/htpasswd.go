@@ -0,0 +1,89 @@
+package htpasswd
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1" //nolint:gosec
+	"crypto/subtle"
+	"encoding/base64"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const shaPrefix = "{SHA}"
+
+// dummyHash compared when user not found, so response time doesn't reveal existing users
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("guardian"), bcrypt.DefaultCost)
+	return hash
+})
+
+// Users maps username to password hash
+type Users map[string]string
+
+// Parse parses htpasswd file with bcrypt and {SHA} hashes
+func Parse(data []byte) (Users, error) {
+	users := Users{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		username, hash, ok := strings.Cut(text, ":")
+		if !ok || username == "" {
+			return nil, errors.Errorf("line %d: expected user:hash", line)
+		}
+		if !IsSupportedHash(hash) {
+			return nil, errors.Errorf("line %d: unsupported hash of user %s, bcrypt and {SHA} supported", line, username)
+		}
+		if _, exists := users[username]; exists {
+			return nil, errors.Errorf("line %d: user %s defined twice", line, username)
+		}
+
+		users[username] = hash
+	}
+
+	return users, errors.WithStack(scanner.Err())
+}
+
+// Verify reports whether password matches hash of user
+func (users Users) Verify(username, password string) bool {
+	return CheckPassword(users[username], password)
+}
+
+// CheckPassword reports whether password matches bcrypt or {SHA} hash.
+// Empty hash of unknown user compared with dummy one, so response time doesn't reveal existing users
+func CheckPassword(hash, password string) bool {
+	if hash == "" {
+		_ = bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return false
+	}
+
+	if sha, isSHA := strings.CutPrefix(hash, shaPrefix); isSHA {
+		//nolint:gosec
+		sum := sha1.Sum([]byte(password))
+		return subtle.ConstantTimeCompare([]byte(sha), []byte(base64.StdEncoding.EncodeToString(sum[:]))) == 1
+	}
+
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// IsSupportedHash reports whether hash is bcrypt or {SHA} one
+func IsSupportedHash(hash string) bool {
+	return isBcrypt(hash) || strings.HasPrefix(hash, shaPrefix)
+}
+
+func isBcrypt(hash string) bool {
+	for _, prefix := range []string{"$2y$", "$2a$", "$2b$"} {
+		if strings.HasPrefix(hash, prefix) {
+			return true
+		}
+	}
+	return false
+}
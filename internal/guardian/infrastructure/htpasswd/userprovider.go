@@ -0,0 +1,63 @@
+package htpasswd
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/pkg/errors"
+
+	"guardian/internal/common/infrastructure/filewatch"
+	"guardian/internal/guardian/app/user"
+)
+
+// IndexedUsers holds htpasswd users with usernames indexed by UserID
+type IndexedUsers struct {
+	Users     Users
+	usernames map[uuid.UUID]string
+}
+
+// ParseIndexed parses htpasswd file like Parse and indexes users by ID, so it's done once per load of file
+func ParseIndexed(data []byte) (IndexedUsers, error) {
+	users, err := Parse(data)
+	if err != nil {
+		return IndexedUsers{}, err
+	}
+
+	usernames := make(map[uuid.UUID]string, len(users))
+	for username := range users {
+		usernames[UserID(username)] = username
+	}
+	return IndexedUsers{Users: users, usernames: usernames}, nil
+}
+
+// NewUserProvider returns provider of htpasswd users, ids of users derived by UserID
+func NewUserProvider(file *filewatch.File[IndexedUsers]) user.Authenticator {
+	return &userProvider{file: file}
+}
+
+type userProvider struct {
+	file *filewatch.File[IndexedUsers]
+}
+
+func (provider *userProvider) User(_ context.Context, token user.Token) (user.Descriptor, error) {
+	username, ok := provider.file.Get().usernames[token.ID]
+	if !ok {
+		return user.Descriptor{}, errors.Wrapf(user.ErrUserNotFound, "htpasswd: user %s", token.ID)
+	}
+	return user.Descriptor{ID: token.ID, Username: username}, nil
+}
+
+func (provider *userProvider) Authenticate(_ context.Context, username, password string) (user.Descriptor, error) {
+	if !provider.file.Get().Users.Verify(username, password) {
+		return user.Descriptor{}, errors.Wrapf(user.ErrInvalidCredentials, "htpasswd: user %s", username)
+	}
+	return user.Descriptor{ID: UserID(username), Username: username}, nil
+}
+
+func (provider *userProvider) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"type": "htpasswd",
+		"file": provider.file.Path(),
+	})
+}
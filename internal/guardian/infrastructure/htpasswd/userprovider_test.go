@@ -0,0 +1,65 @@
+package htpasswd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/pkg/errors"
+
+	"guardian/internal/common/infrastructure/filewatch"
+	"guardian/internal/guardian/app/user"
+)
+
+func TestUserProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "htpasswd")
+	write := func(data string, modTime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Now().Add(-time.Hour)
+	write("alice:"+bcryptHash(t, "secret")+"\nbob:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=\n", start)
+
+	file, err := filewatch.NewFile(path, 0, ParseIndexed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := NewUserProvider(file)
+
+	for username, found := range map[string]bool{"alice": true, "bob": true, "carol": false} {
+		d, err2 := provider.User(context.Background(), user.Token{ID: UserID(username)})
+		if !found {
+			if !errors.Is(err2, user.ErrUserNotFound) {
+				t.Errorf("%s: got %v, want %v", username, err2, user.ErrUserNotFound)
+			}
+			continue
+		}
+		if err2 != nil || d.ID != UserID(username) || d.Username != username {
+			t.Errorf("%s: got %+v, %v", username, d, err2)
+		}
+	}
+	if _, err = provider.User(context.Background(), user.Token{ID: uuid.Must(uuid.NewV4())}); !errors.Is(err, user.ErrUserNotFound) {
+		t.Errorf("got %v, want %v", err, user.ErrUserNotFound)
+	}
+
+	// index rebuilt on reload
+	write("carol:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=\n", start.Add(time.Second))
+	if d, err2 := provider.User(context.Background(), user.Token{ID: UserID("carol")}); err2 != nil || d.Username != "carol" {
+		t.Errorf("added user not found: %+v, %v", d, err2)
+	}
+	if _, err = provider.User(context.Background(), user.Token{ID: UserID("alice")}); !errors.Is(err, user.ErrUserNotFound) {
+		t.Errorf("removed user found: %v", err)
+	}
+
+	if d, err2 := provider.Authenticate(context.Background(), "carol", "secret"); err2 != nil || d.ID != UserID("carol") {
+		t.Errorf("got %+v, %v", d, err2)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/pkg/errors"
 
 	"guardian/internal/guardian/app/proxy/downstream"
+	"guardian/internal/guardian/app/user"
 )
 
 type ErrUnauthorized struct {
@@ -17,15 +18,66 @@ func (e ErrUnauthorized) Error() string {
 	return fmt.Sprintf("unauthorized: %s", e.Reason)
 }
 
-func (p *proxy) handleErr(err error, w http.ResponseWriter, log proxyLog) {
+// ErrForbidden returned when policy denies user access to downstream
+type ErrForbidden struct {
+	Username string
+	// Policy is name of downstream policy rule denied access
+	Policy string
+}
+
+func (e ErrForbidden) Error() string {
+	return fmt.Sprintf("forbidden: user %s denied by policy %s", e.Username, e.Policy)
+}
+
+func (p *proxy) handleErr(err error, w http.ResponseWriter, r *http.Request, log proxyLog) {
+	if redirect, ok := errors.Cause(err).(*downstream.ErrRedirect); ok {
+		// redirect is regular flow like login, so it isn't logged as error
+		for name, values := range redirect.Header {
+			w.Header()[name] = values
+		}
+		for _, c := range redirect.Cookies {
+			http.SetCookie(w, c)
+		}
+		http.Redirect(w, r, redirect.URL, http.StatusFound)
+		p.logProxy(log)
+		return
+	}
+
 	p.logProxyErr(err, log)
 
+	var challenge *downstream.ErrChallenge
+	if errors.As(err, &challenge) {
+		w.Header().Set("WWW-Authenticate", challenge.Challenge)
+	}
+
+	var response *downstream.ErrResponse
+	if errors.As(err, &response) {
+		for name, values := range response.Header {
+			w.Header()[name] = values
+		}
+		if len(response.Body) == 0 {
+			http.Error(w, http.StatusText(response.StatusCode), response.StatusCode)
+			return
+		}
+		w.WriteHeader(response.StatusCode)
+		_, _ = w.Write(response.Body)
+		return
+	}
+
 	switch errors.Cause(err) {
 	case ErrRequestNotMatched,
 		downstream.ErrAuthDataNotFound,
-		downstream.ErrAuthDataInvalid:
+		downstream.ErrAuthDataInvalid,
+		user.ErrUserNotFound,
+		downstream.ErrSecondFactorRequired:
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
+	case user.ErrProviderUnavailable:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	case downstream.ErrAuthDenied:
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
 	}
 
 	//nolint:gocritic
@@ -33,6 +85,10 @@ func (p *proxy) handleErr(err error, w http.ResponseWriter, log proxyLog) {
 	case *ErrUnauthorized:
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
+	case *ErrForbidden:
+		// policy details aren't exposed to client
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
 	}
 
 	http.Error(w, err.Error(), http.StatusInternalServerError)
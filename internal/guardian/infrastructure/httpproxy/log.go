@@ -4,6 +4,8 @@ import (
 	"net/url"
 	"time"
 
+	"github.com/pkg/errors"
+
 	"guardian/internal/common/infrastructure/logger"
 )
 
@@ -20,8 +22,16 @@ func (p *proxy) logProxy(l proxyLog) {
 }
 
 func (p *proxy) logProxyErr(err error, l proxyLog) {
+	fields := transformFields(l)
+
+	var forbidden *ErrForbidden
+	if errors.As(err, &forbidden) {
+		fields["user"] = forbidden.Username
+		fields["policy"] = forbidden.Policy
+	}
+
 	p.logger.
-		WithFields(transformFields(l)).
+		WithFields(fields).
 		Error(err)
 }
 
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"sync/atomic"
 	"time"
 
 	"github.com/UsingCoding/fpgo/pkg/maybe"
@@ -30,29 +31,49 @@ func NewProxy(
 	limit config.Limit,
 	l logger.Logger,
 ) Proxy {
-	var limiter *rate.Limiter
-	if limit.RPS != 0 && limit.Burst != 0 {
-		limiter = rate.NewLimiter(rate.Limit(limit.RPS), limit.Burst)
-	}
-
-	return &proxy{
-		d:       d,
-		u:       u,
-		limiter: limiter,
-		logger:  l,
+	p := &proxy{
+		logger: l,
 	}
+	p.Update(d, u, limit)
+	return p
 }
 
 type Proxy interface {
 	Proxy() http.Handler
+	// Update atomically replaces routing of running proxy.
+	// Requests in flight complete with previous routing
+	Update(d []downstream.Downstream, u []upstream.Upstream, limit config.Limit)
 }
 
 type proxy struct {
+	state atomic.Pointer[proxyState]
+
+	logger logger.Logger
+}
+
+type proxyState struct {
 	d []downstream.Downstream
 	u []upstream.Upstream
 
+	limit   config.Limit
 	limiter *rate.Limiter
-	logger  logger.Logger
+}
+
+func (p *proxy) Update(d []downstream.Downstream, u []upstream.Upstream, limit config.Limit) {
+	var limiter *rate.Limiter
+	if prev := p.state.Load(); prev != nil && prev.limit == limit {
+		// keep limiter to not reset its bucket on reload
+		limiter = prev.limiter
+	} else if limit.RPS != 0 && limit.Burst != 0 {
+		limiter = rate.NewLimiter(rate.Limit(limit.RPS), limit.Burst)
+	}
+
+	p.state.Store(&proxyState{
+		d:       d,
+		u:       u,
+		limit:   limit,
+		limiter: limiter,
+	})
 }
 
 type transport struct {
@@ -66,15 +87,29 @@ func (t *transport) RoundTrip(request *http.Request) (*http.Response, error) {
 func (p *proxy) Proxy() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
+		state := p.state.Load()
 
-		if p.limiter != nil && !p.limiter.Allow() {
+		if state.limiter != nil && !state.limiter.Allow() {
 			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
 			return
 		}
 
-		res, err := p.proceedRequest(r.Context(), *r)
+		if served, err := state.serveAuth(w, r); served {
+			l := proxyLog{
+				DownstreamURL: r.URL,
+				Start:         start,
+			}
+			if err != nil {
+				p.handleErr(err, w, r, l)
+				return
+			}
+			p.logProxy(l)
+			return
+		}
+
+		res, err := p.proceedRequest(r.Context(), state, *r)
 		if err != nil {
-			p.handleErr(err, w, proxyLog{
+			p.handleErr(err, w, r, proxyLog{
 				DownstreamURL: r.URL,
 				UpstreamURL:   nil,
 				Start:         start,
@@ -116,29 +151,60 @@ type proceedRes struct {
 	ResponseReceiver     func(*http.Response) error
 }
 
-func (p *proxy) proceedRequest(ctx context.Context, r http.Request) (proceedRes, error) {
-	d, ok := maybe.JustValid(p.matchDownstream(ctx, r))
+func (p *proxy) proceedRequest(ctx context.Context, state *proxyState, r http.Request) (proceedRes, error) {
+	d, ok := maybe.JustValid(state.matchDownstream(ctx, r))
 	if !ok {
 		return proceedRes{}, errors.WithStack(ErrRequestNotMatched)
 	}
 
-	u, ok := maybe.JustValid(p.matchUpstream(d.UpstreamID))
+	u, ok := maybe.JustValid(state.matchUpstream(d.UpstreamID))
 	if !ok {
 		return proceedRes{}, errors.WithStack(ErrUpstreamNotFound)
 	}
 
 	var descriptor maybe.Maybe[user.Descriptor]
+	var responseModifier downstream.ResponseModifier
 	if auth, ok := maybe.JustValid(d.Authorizer); ok {
 		desc, err := auth.Auth(ctx, r)
-		if err != nil {
-			return proceedRes{}, err
+		// URL and header shared with incoming request, so stripped credentials neither proxied nor logged
+		if stripper, ok := auth.(downstream.CredentialsStripper); ok {
+			stripper.StripCredentials(r.URL, r.Header)
+		}
+		switch {
+		case err == nil:
+			descriptor = maybe.NewJust(desc)
+			responseModifier, _ = auth.(downstream.ResponseModifier)
+		case d.AuthOptional && downstream.IsUnauthenticated(err):
+			// request proceeds anonymously
+		default:
+			return proceedRes{}, respondUnauthenticated(d, r, err)
+		}
+
+		if policy, ok := maybe.JustValid(d.Policy); ok {
+			decision := policy.Evaluate(r, desc)
+			if decision.Effect != downstream.EffectAllow {
+				if !maybe.Valid(descriptor) {
+					// anonymous user denied, so authentication required
+					return proceedRes{}, respondUnauthenticated(d, r, err)
+				}
+				return proceedRes{}, &ErrForbidden{
+					Username: desc.Username,
+					Policy:   decision.Rule,
+				}
+			}
+		}
+
+		if secondFactor, ok := maybe.JustValid(d.SecondFactor); ok && maybe.Valid(descriptor) {
+			err := secondFactor.Verify(r, desc, downstream.SessionID(auth, r))
+			if err != nil {
+				return proceedRes{}, err
+			}
 		}
-		descriptor = maybe.NewJust(desc)
 	}
 
 	var authorizer maybe.Maybe[upstream.Authorizer]
 	if a, ok := maybe.JustValid(u.Authorizer); ok {
-		if !maybe.Valid(descriptor) {
+		if !maybe.Valid(descriptor) && !d.AuthOptional {
 			return proceedRes{}, &ErrUnauthorized{
 				Reason: "no user for authorized zone",
 			}
@@ -158,14 +224,56 @@ func (p *proxy) proceedRequest(ctx context.Context, r http.Request) (proceedRes,
 				)
 			}
 		},
-		ResponseReceiver: func(_ *http.Response) error {
-			return nil
+		ResponseReceiver: func(resp *http.Response) error {
+			if responseModifier == nil {
+				return nil
+			}
+			return responseModifier.ModifyResponse(&r, resp, maybe.Just(descriptor))
 		},
 	}, nil
 }
 
-func (p *proxy) matchDownstream(ctx context.Context, r http.Request) maybe.Maybe[downstream.Downstream] {
-	for _, d := range p.d {
+func respondUnauthenticated(d downstream.Downstream, r http.Request, err error) error {
+	if u, ok := maybe.JustValid(d.OnUnauthenticated); ok {
+		return u.Respond(r, err)
+	}
+	return err
+}
+
+// serveAuth lets authorizer and second factor of matched downstream serve own endpoints
+func (s *proxyState) serveAuth(w http.ResponseWriter, r *http.Request) (bool, error) {
+	d, ok := maybe.JustValid(s.matchDownstream(r.Context(), *r))
+	if !ok {
+		return false, nil
+	}
+
+	auth, ok := maybe.JustValid(d.Authorizer)
+	if !ok {
+		return false, nil
+	}
+
+	if handler, ok := auth.(downstream.Handler); ok {
+		served, err := handler.ServeAuth(w, r)
+		if served {
+			return true, err
+		}
+	}
+
+	secondFactor, ok := maybe.JustValid(d.SecondFactor)
+	if !ok || !secondFactor.Challenges(r) {
+		return false, nil
+	}
+
+	// challenge served only to users authenticated by first factor
+	descriptor, err := auth.Auth(r.Context(), *r)
+	if err != nil {
+		return true, respondUnauthenticated(d, *r, err)
+	}
+	return true, secondFactor.ServeChallenge(w, r, descriptor, downstream.SessionID(auth, *r))
+}
+
+func (s *proxyState) matchDownstream(ctx context.Context, r http.Request) maybe.Maybe[downstream.Downstream] {
+	for _, d := range s.d {
 		match := true
 		for _, rule := range d.Rules {
 			if !rule.Match(ctx, r) {
@@ -182,8 +290,8 @@ func (p *proxy) matchDownstream(ctx context.Context, r http.Request) maybe.Maybe
 	return maybe.Maybe[downstream.Downstream]{}
 }
 
-func (p *proxy) matchUpstream(upstreamID string) maybe.Maybe[upstream.Upstream] {
-	for _, u := range p.u {
+func (s *proxyState) matchUpstream(upstreamID string) maybe.Maybe[upstream.Upstream] {
+	for _, u := range s.u {
 		if u.ID == upstreamID {
 			return maybe.NewJust(u)
 		}
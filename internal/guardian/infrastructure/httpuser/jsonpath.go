@@ -0,0 +1,114 @@
+package httpuser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Path is JSONPath-style selector of value in JSON document: $.profile.login, $.emails[0] or profile.login
+type Path []string
+
+func ParsePath(s string) (Path, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "$"), ".")
+	if s == "" {
+		return nil, errors.New("empty path")
+	}
+
+	var path Path
+	for _, segment := range strings.Split(s, ".") {
+		name, rest, _ := strings.Cut(segment, "[")
+		if name != "" {
+			path = append(path, name)
+		}
+
+		for rest != "" {
+			index, tail, ok := strings.Cut(rest, "]")
+			if !ok {
+				return nil, errors.Errorf("unclosed index in %s", s)
+			}
+			if _, err := strconv.Atoi(index); err != nil {
+				return nil, errors.Errorf("invalid index %s in %s", index, s)
+			}
+			path = append(path, index)
+			rest = strings.TrimPrefix(tail, "[")
+		}
+	}
+	return path, nil
+}
+
+// Lookup returns value selected by path from decoded JSON document
+func (p Path) Lookup(doc any) (any, bool) {
+	v := doc
+	for _, segment := range p {
+		switch node := v.(type) {
+		case map[string]any:
+			next, ok := node[segment]
+			if !ok {
+				return nil, false
+			}
+			v = next
+		case []any:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			v = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// LookupString returns value selected by path formatted as string
+func (p Path) LookupString(doc any) (string, bool) {
+	v, ok := p.Lookup(doc)
+	if !ok || v == nil {
+		return "", false
+	}
+
+	switch value := v.(type) {
+	case string:
+		return value, true
+	case map[string]any, []any:
+		return "", false
+	default:
+		return fmt.Sprint(value), true
+	}
+}
+
+// LookupStrings returns array of strings selected by path, single value treated as array of one element
+func (p Path) LookupStrings(doc any) ([]string, bool) {
+	v, ok := p.Lookup(doc)
+	if !ok || v == nil {
+		return nil, false
+	}
+
+	items, isArray := v.([]any)
+	if !isArray {
+		s, ok2 := p.LookupString(doc)
+		if !ok2 {
+			return nil, false
+		}
+		return []string{s}, true
+	}
+
+	res := make([]string, 0, len(items))
+	for _, item := range items {
+		switch value := item.(type) {
+		case string:
+			res = append(res, value)
+		case map[string]any, []any, nil:
+		default:
+			res = append(res, fmt.Sprint(value))
+		}
+	}
+	return res, true
+}
+
+func (p Path) String() string {
+	return "$." + strings.Join(p, ".")
+}
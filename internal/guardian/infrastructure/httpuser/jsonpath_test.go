@@ -0,0 +1,30 @@
+package httpuser
+
+import "testing"
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+		ok   bool
+	}{
+		{"$.profile.login", "$.profile.login", true},
+		{"profile.login", "$.profile.login", true},
+		{"$.emails[0]", "$.emails.0", true},
+		{"$.matrix[1][2].name", "$.matrix.1.2.name", true},
+		{"$", "", false},
+		{"", "", false},
+		{"$.emails[0", "", false},
+		{"$.emails[first]", "", false},
+	}
+	for _, tt := range tests {
+		p, err := ParsePath(tt.path)
+		if (err == nil) != tt.ok {
+			t.Errorf("%q: %v", tt.path, err)
+			continue
+		}
+		if tt.ok && p.String() != tt.want {
+			t.Errorf("%q parsed as %s, want %s", tt.path, p, tt.want)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package httpuser
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/pkg/errors"
+
+	"guardian/internal/guardian/app/user"
+)
+
+const (
+	// IDPlaceholder replaced in URL with token ID
+	IDPlaceholder = "{id}"
+
+	DefaultTimeout = 5 * time.Second
+
+	maxResponseSize = 1 << 20
+)
+
+type Config struct {
+	// URL template with IDPlaceholder: https://identity/users/{id}
+	URL     string
+	Headers map[string]string
+
+	BasicAuth *BasicAuth
+
+	Mapping Mapping
+	Timeout time.Duration
+
+	// Transport used for requests, http.DefaultTransport when nil
+	Transport http.RoundTripper
+}
+
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+// Mapping maps fields of response document to user.Descriptor
+type Mapping struct {
+	ID          Path
+	Username    Path
+	Email       Path
+	DisplayName Path
+	// Groups selects array of strings or single string
+	Groups     Path
+	Attributes map[string]Path
+}
+
+func NewUserProvider(config Config) user.Provider {
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+	if config.Mapping.ID == nil {
+		config.Mapping.ID = Path{"id"}
+	}
+	if config.Mapping.Username == nil {
+		config.Mapping.Username = Path{"username"}
+	}
+	if config.Mapping.Email == nil {
+		config.Mapping.Email = Path{"email"}
+	}
+	if config.Mapping.DisplayName == nil {
+		config.Mapping.DisplayName = Path{"displayName"}
+	}
+	if config.Mapping.Groups == nil {
+		config.Mapping.Groups = Path{"groups"}
+	}
+
+	transport := config.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	return &userProvider{
+		config: config,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   config.Timeout,
+		},
+	}
+}
+
+type userProvider struct {
+	config Config
+	client *http.Client
+}
+
+func (provider *userProvider) User(ctx context.Context, token user.Token) (user.Descriptor, error) {
+	req, err := provider.request(ctx, token)
+	if err != nil {
+		return user.Descriptor{}, err
+	}
+
+	resp, err := provider.client.Do(req)
+	if err != nil {
+		return user.Descriptor{}, errors.Wrapf(user.ErrProviderUnavailable, "http %s: %s", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return user.Descriptor{}, errors.Wrapf(user.ErrUserNotFound, "http: user %s", token.ID)
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return user.Descriptor{}, errors.Wrapf(user.ErrProviderUnavailable, "http %s: status %d", req.URL.Host, resp.StatusCode)
+	case resp.StatusCode != http.StatusOK:
+		return user.Descriptor{}, errors.Errorf("http %s: unexpected status %d", req.URL.Host, resp.StatusCode)
+	}
+
+	var doc any
+	err = json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&doc)
+	if err != nil {
+		return user.Descriptor{}, errors.Wrapf(err, "http %s: failed to decode user %s", req.URL.Host, token.ID)
+	}
+
+	return provider.descriptor(doc, token)
+}
+
+func (provider *userProvider) request(ctx context.Context, token user.Token) (*http.Request, error) {
+	u := strings.ReplaceAll(provider.config.URL, IDPlaceholder, url.PathEscape(token.ID.String()))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build user request")
+	}
+
+	req.Header.Set("Accept", "application/json")
+	for name, value := range provider.config.Headers {
+		req.Header.Set(name, value)
+	}
+	if auth := provider.config.BasicAuth; auth != nil {
+		req.SetBasicAuth(auth.Username, auth.Password)
+	}
+
+	return req, nil
+}
+
+func (provider *userProvider) descriptor(doc any, token user.Token) (user.Descriptor, error) {
+	rawID, ok := provider.config.Mapping.ID.LookupString(doc)
+	if !ok {
+		return user.Descriptor{}, errors.Errorf("http: %s not found in user %s", provider.config.Mapping.ID, token.ID)
+	}
+
+	id, err := uuid.FromString(rawID)
+	if err != nil {
+		return user.Descriptor{}, errors.Wrapf(err, "http: invalid id of user %s", token.ID)
+	}
+
+	m := provider.config.Mapping
+	username, _ := m.Username.LookupString(doc)
+	email, _ := m.Email.LookupString(doc)
+	displayName, _ := m.DisplayName.LookupString(doc)
+	groups, _ := m.Groups.LookupStrings(doc)
+
+	var attributes map[string]string
+	for name, path := range m.Attributes {
+		if v, ok := path.LookupString(doc); ok {
+			if attributes == nil {
+				attributes = map[string]string{}
+			}
+			attributes[name] = v
+		}
+	}
+
+	return user.Descriptor{
+		ID:          id,
+		Username:    username,
+		Email:       email,
+		DisplayName: displayName,
+		Groups:      groups,
+		Attributes:  attributes,
+	}, nil
+}
+
+func (m Mapping) MarshalJSON() ([]byte, error) {
+	attributes := make(map[string]string, len(m.Attributes))
+	for name, path := range m.Attributes {
+		attributes[name] = path.String()
+	}
+
+	return json.Marshal(map[string]any{
+		"id":          m.ID.String(),
+		"username":    m.Username.String(),
+		"email":       m.Email.String(),
+		"displayName": m.DisplayName.String(),
+		"groups":      m.Groups.String(),
+		"attributes":  attributes,
+	})
+}
+
+func (provider *userProvider) MarshalJSON() ([]byte, error) {
+	headers := make([]string, 0, len(provider.config.Headers))
+	for name := range provider.config.Headers {
+		// values omitted since they may hold credentials
+		headers = append(headers, name)
+	}
+	sort.Strings(headers)
+
+	return json.Marshal(map[string]any{
+		"type":      "http",
+		"url":       provider.config.URL,
+		"headers":   headers,
+		"basicAuth": provider.config.BasicAuth != nil,
+		"mapping":   provider.config.Mapping,
+		"timeout":   provider.config.Timeout.String(),
+	})
+}
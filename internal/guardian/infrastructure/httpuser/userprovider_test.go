@@ -0,0 +1,133 @@
+package httpuser
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/pkg/errors"
+
+	"guardian/internal/guardian/app/user"
+)
+
+var (
+	alice   = uuid.Must(uuid.FromString("6f1d8c0e-5a3b-4f7e-9c2d-1e8a7b6c5d4f"))
+	missing = uuid.Must(uuid.FromString("00000000-0000-4000-8000-000000000404"))
+	broken  = uuid.Must(uuid.FromString("00000000-0000-4000-8000-000000000500"))
+	invalid = uuid.Must(uuid.FromString("00000000-0000-4000-8000-000000000400"))
+)
+
+// newTestService returns identity service serving users by /users/{id}
+func newTestService(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, _ := r.BasicAuth(); username != "guardian" || password != "secret" ||
+			r.Header.Get("X-Tenant") != "acme" || r.Header.Get("Accept") != "application/json" {
+			http.Error(w, "unexpected user request", http.StatusBadRequest)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/users/" + alice.String():
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{
+				"data": {
+					"uid": "` + alice.String() + `",
+					"profile": {"login": "alice", "emails": ["alice@example.com", "a@example.com"], "name": "Alice"},
+					"roles": ["staff", "admins", 42, null],
+					"team": {"name": "platform", "size": 5}
+				}
+			}`))
+		case "/users/" + missing.String():
+			http.NotFound(w, r)
+		case "/users/" + broken.String():
+			http.Error(w, "broken", http.StatusBadGateway)
+		case "/users/" + invalid.String():
+			_, _ = w.Write([]byte(`{"data": {"profile": {"login": "nobody"}}}`))
+		default:
+			http.Error(w, "unexpected path", http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func mustParsePath(t *testing.T, s string) Path {
+	t.Helper()
+
+	p, err := ParsePath(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestUserProvider(t *testing.T) {
+	s := newTestService(t)
+	provider := NewUserProvider(Config{
+		URL:       s.URL + "/users/" + IDPlaceholder,
+		Headers:   map[string]string{"X-Tenant": "acme"},
+		BasicAuth: &BasicAuth{Username: "guardian", Password: "secret"},
+		Mapping: Mapping{
+			ID:          mustParsePath(t, "$.data.uid"),
+			Username:    mustParsePath(t, "$.data.profile.login"),
+			Email:       mustParsePath(t, "$.data.profile.emails[0]"),
+			DisplayName: mustParsePath(t, "data.profile.name"),
+			Groups:      mustParsePath(t, "$.data.roles"),
+			Attributes: map[string]Path{
+				"team":    mustParsePath(t, "$.data.team.name"),
+				"size":    mustParsePath(t, "$.data.team.size"),
+				"missing": mustParsePath(t, "$.data.team.lead"),
+			},
+		},
+	})
+
+	d, err := provider.User(context.Background(), user.Token{ID: alice})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.ID != alice || d.Username != "alice" || d.Email != "alice@example.com" || d.DisplayName != "Alice" ||
+		strings.Join(d.Groups, ",") != "staff,admins,42" || len(d.Attributes) != 2 ||
+		d.Attributes["team"] != "platform" || d.Attributes["size"] != "5" {
+		t.Errorf("unexpected descriptor %+v", d)
+	}
+
+	tests := []struct {
+		name string
+		id   uuid.UUID
+		err  error
+	}{
+		{"not found", missing, user.ErrUserNotFound},
+		{"service failed", broken, user.ErrProviderUnavailable},
+		// user found but ID path selects nothing
+		{"id not found", invalid, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := provider.User(context.Background(), user.Token{ID: tt.id})
+			if err == nil {
+				t.Fatal("user resolved")
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("got %v, want %v", err, tt.err)
+			}
+			if tt.err == nil && (errors.Is(err, user.ErrUserNotFound) || errors.Is(err, user.ErrProviderUnavailable)) {
+				t.Errorf("invalid user reported as %v", err)
+			}
+		})
+	}
+}
+
+func TestUserProviderServiceUnreachable(t *testing.T) {
+	s := newTestService(t)
+	s.Close()
+
+	provider := NewUserProvider(Config{URL: s.URL + "/users/" + IDPlaceholder})
+	if _, err := provider.User(context.Background(), user.Token{ID: alice}); !errors.Is(err, user.ErrProviderUnavailable) {
+		t.Errorf("got %v, want %v", err, user.ErrProviderUnavailable)
+	}
+}
@@ -0,0 +1,142 @@
+package jwtauth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/UsingCoding/fpgo/pkg/maybe"
+	"github.com/UsingCoding/fpgo/pkg/slices"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+
+	"guardian/internal/common/infrastructure/filewatch"
+	"guardian/internal/guardian/app/proxy/downstream"
+	"guardian/internal/guardian/app/user"
+)
+
+type Config struct {
+	// Cookie holds token when request has no bearer token, cookie not read when empty
+	Cookie string
+
+	Keys []Key
+	// JWKS is key set reloaded from file
+	JWKS *filewatch.File[[]Key]
+
+	// Issuer and Audience checked when not empty
+	Issuer   string
+	Audience string
+	// Leeway tolerates clock skew when checking exp and nbf
+	Leeway time.Duration
+
+	Claims Claims
+}
+
+// NewAuthorizer returns authorizer of users by JWT.
+// User resolved by userProvider with ID from token when provider set, otherwise built from claims
+func NewAuthorizer(config Config, userProvider maybe.Maybe[user.Provider]) downstream.Authorizer {
+	config.Claims = config.Claims.WithDefaults()
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{HS256, RS256, ES256}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(config.Leeway),
+	}
+	if config.Issuer != "" {
+		options = append(options, jwt.WithIssuer(config.Issuer))
+	}
+	if config.Audience != "" {
+		options = append(options, jwt.WithAudience(config.Audience))
+	}
+
+	return &authorizer{
+		config:       config,
+		parser:       jwt.NewParser(options...),
+		userProvider: userProvider,
+	}
+}
+
+type authorizer struct {
+	config       Config
+	parser       *jwt.Parser
+	userProvider maybe.Maybe[user.Provider]
+}
+
+func (a *authorizer) Auth(ctx context.Context, r http.Request) (user.Descriptor, error) {
+	raw, ok := a.token(r)
+	if !ok {
+		return user.Descriptor{}, errors.WithStack(downstream.ErrAuthDataNotFound)
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := a.parser.ParseWithClaims(raw, claims, KeyFunc(a.keys))
+	if err != nil {
+		return user.Descriptor{}, errors.Wrapf(downstream.ErrAuthDataInvalid, "jwt: %s", err)
+	}
+
+	id, err := a.config.Claims.UserID(claims)
+	if err != nil {
+		return user.Descriptor{}, errors.Wrapf(downstream.ErrAuthDataInvalid, "jwt: %s", err)
+	}
+
+	if provider, ok := maybe.JustValid(a.userProvider); ok {
+		descriptor, err2 := provider.User(ctx, user.Token{ID: id})
+		return descriptor, errors.WithStack(err2)
+	}
+
+	return a.config.Claims.Descriptor(id, claims), nil
+}
+
+func (a *authorizer) token(r http.Request) (string, bool) {
+	if scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token), token != ""
+	}
+
+	if a.config.Cookie == "" {
+		return "", false
+	}
+	c, err := r.Cookie(a.config.Cookie)
+	if err != nil || c.Value == "" {
+		return "", false
+	}
+	return c.Value, true
+}
+
+func (a *authorizer) keys() []Key {
+	if a.config.JWKS == nil {
+		return a.config.Keys
+	}
+	return append(a.config.Keys[:len(a.config.Keys):len(a.config.Keys)], a.config.JWKS.Get()...)
+}
+
+func (a *authorizer) MarshalJSON() ([]byte, error) {
+	res := map[string]any{
+		"type": "jwt",
+		// secrets and keys material omitted
+		"keys": slices.Map(a.config.Keys, func(k Key) string {
+			return k.Algorithm + ":" + k.ID
+		}),
+		"claims": a.config.Claims,
+	}
+	if a.config.Cookie != "" {
+		res["cookie"] = a.config.Cookie
+	}
+	if a.config.JWKS != nil {
+		res["jwksFile"] = a.config.JWKS.Path()
+	}
+	if a.config.Issuer != "" {
+		res["issuer"] = a.config.Issuer
+	}
+	if a.config.Audience != "" {
+		res["audience"] = a.config.Audience
+	}
+	if a.config.Leeway != 0 {
+		res["leeway"] = a.config.Leeway.String()
+	}
+	if provider, ok := maybe.JustValid(a.userProvider); ok {
+		res["userProvider"] = provider
+	}
+	return json.Marshal(res)
+}
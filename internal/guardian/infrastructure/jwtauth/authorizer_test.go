@@ -0,0 +1,204 @@
+package jwtauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/UsingCoding/fpgo/pkg/maybe"
+	"github.com/gofrs/uuid/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+
+	"guardian/internal/guardian/app/proxy/downstream"
+	"guardian/internal/guardian/app/user"
+)
+
+var hmacSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func sign(t *testing.T, method jwt.SigningMethod, key any, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	raw, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":                "alice-subject",
+		"iss":                "https://idp.example.com",
+		"aud":                "guardian",
+		"exp":                time.Now().Add(time.Hour).Unix(),
+		"preferred_username": "alice",
+		"email":              "alice@example.com",
+		"groups":             []string{"staff", "admins"},
+		"team":               "platform",
+	}
+}
+
+func withClaims(claims jwt.MapClaims, change map[string]any) jwt.MapClaims {
+	for k, v := range change {
+		if v == nil {
+			delete(claims, k)
+			continue
+		}
+		claims[k] = v
+	}
+	return claims
+}
+
+func TestAuthorizerAuth(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherRSAKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := NewAuthorizer(Config{
+		Cookie: "token",
+		Keys: []Key{
+			NewHMACKey("", hmacSecret),
+			{ID: "rsa-1", Algorithm: RS256, Material: &rsaKey.PublicKey},
+			{ID: "rsa-2", Algorithm: RS256, Material: &otherRSAKey.PublicKey},
+		},
+		Issuer:   "https://idp.example.com",
+		Audience: "guardian",
+		Claims:   Claims{Attributes: map[string]string{"team": "team"}},
+	}, maybe.Maybe[user.Provider]{})
+
+	tests := []struct {
+		name   string
+		header string
+		cookie string
+		err    error
+	}{
+		{name: "hs256 bearer", header: "Bearer " + sign(t, jwt.SigningMethodHS256, hmacSecret, "", validClaims())},
+		{name: "rs256 by kid", header: "Bearer " + sign(t, jwt.SigningMethodRS256, otherRSAKey, "rsa-2", validClaims())},
+		{name: "rs256 without kid", header: "bearer " + sign(t, jwt.SigningMethodRS256, rsaKey, "", validClaims())},
+		{name: "cookie", cookie: sign(t, jwt.SigningMethodHS256, hmacSecret, "", validClaims())},
+		{name: "no token", err: downstream.ErrAuthDataNotFound},
+		{name: "other scheme", header: "Basic YWxpY2U6c2VjcmV0", err: downstream.ErrAuthDataNotFound},
+		{
+			name:   "wrong kid",
+			header: "Bearer " + sign(t, jwt.SigningMethodRS256, rsaKey, "rsa-2", validClaims()),
+			err:    downstream.ErrAuthDataInvalid,
+		},
+		{
+			name:   "unknown key",
+			header: "Bearer " + sign(t, jwt.SigningMethodHS256, []byte("other-secret"), "", validClaims()),
+			err:    downstream.ErrAuthDataInvalid,
+		},
+		{
+			// public key must not verify HMAC signature
+			name:   "algorithm confusion",
+			header: "Bearer " + sign(t, jwt.SigningMethodHS256, []byte("rsa-public-key"), "rsa-1", validClaims()),
+			err:    downstream.ErrAuthDataInvalid,
+		},
+		{
+			name:   "none algorithm",
+			header: "Bearer " + sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", validClaims()),
+			err:    downstream.ErrAuthDataInvalid,
+		},
+		{
+			name:   "expired",
+			header: "Bearer " + sign(t, jwt.SigningMethodHS256, hmacSecret, "", withClaims(validClaims(), map[string]any{"exp": time.Now().Add(-time.Minute).Unix()})),
+			err:    downstream.ErrAuthDataInvalid,
+		},
+		{
+			name:   "no expiration",
+			header: "Bearer " + sign(t, jwt.SigningMethodHS256, hmacSecret, "", withClaims(validClaims(), map[string]any{"exp": nil})),
+			err:    downstream.ErrAuthDataInvalid,
+		},
+		{
+			name:   "wrong issuer",
+			header: "Bearer " + sign(t, jwt.SigningMethodHS256, hmacSecret, "", withClaims(validClaims(), map[string]any{"iss": "https://evil.example.com"})),
+			err:    downstream.ErrAuthDataInvalid,
+		},
+		{
+			name:   "wrong audience",
+			header: "Bearer " + sign(t, jwt.SigningMethodHS256, hmacSecret, "", withClaims(validClaims(), map[string]any{"aud": "other"})),
+			err:    downstream.ErrAuthDataInvalid,
+		},
+		{
+			name:   "no subject",
+			header: "Bearer " + sign(t, jwt.SigningMethodHS256, hmacSecret, "", withClaims(validClaims(), map[string]any{"sub": nil})),
+			err:    downstream.ErrAuthDataInvalid,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: "token", Value: tt.cookie})
+			}
+
+			descriptor, err := a.Auth(context.Background(), *r)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Errorf("got %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			wantID := uuid.NewV5(uuid.NamespaceURL, "https://idp.example.com#alice-subject")
+			if descriptor.ID != wantID || descriptor.Username != "alice" || descriptor.Email != "alice@example.com" ||
+				len(descriptor.Groups) != 2 || descriptor.Attributes["team"] != "platform" {
+				t.Errorf("unexpected descriptor %+v", descriptor)
+			}
+		})
+	}
+}
+
+func TestAuthorizerUserProvider(t *testing.T) {
+	id := uuid.Must(uuid.NewV4())
+	provider := providerFunc(func(_ context.Context, token user.Token) (user.Descriptor, error) {
+		if token.ID != id {
+			return user.Descriptor{}, errors.WithStack(user.ErrUserNotFound)
+		}
+		return user.Descriptor{ID: id, Username: "from-provider"}, nil
+	})
+	a := NewAuthorizer(Config{Keys: []Key{NewHMACKey("", hmacSecret)}}, maybe.NewJust[user.Provider](provider))
+
+	for sub, err := range map[string]error{id.String(): nil, uuid.Must(uuid.NewV4()).String(): user.ErrUserNotFound} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", "Bearer "+sign(t, jwt.SigningMethodHS256, hmacSecret, "", jwt.MapClaims{
+			"sub":                sub,
+			"exp":                time.Now().Add(time.Hour).Unix(),
+			"preferred_username": "from-token",
+		}))
+
+		descriptor, authErr := a.Auth(context.Background(), *r)
+		if !errors.Is(authErr, err) {
+			t.Errorf("sub %s: got %v, want %v", sub, authErr, err)
+		}
+		if err == nil && descriptor.Username != "from-provider" {
+			t.Errorf("descriptor not resolved by provider: %+v", descriptor)
+		}
+	}
+}
+
+type providerFunc func(ctx context.Context, token user.Token) (user.Descriptor, error)
+
+func (f providerFunc) User(ctx context.Context, token user.Token) (user.Descriptor, error) {
+	return f(ctx, token)
+}
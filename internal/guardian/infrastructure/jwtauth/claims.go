@@ -0,0 +1,121 @@
+package jwtauth
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/UsingCoding/fpgo/pkg/slices"
+	"github.com/gofrs/uuid/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+
+	"guardian/internal/guardian/app/user"
+)
+
+const (
+	DefaultIDClaim          = "sub"
+	DefaultUsernameClaim    = "preferred_username"
+	DefaultEmailClaim       = "email"
+	DefaultDisplayNameClaim = "name"
+	DefaultGroupsClaim      = "groups"
+)
+
+// Claims maps token claims to user.Descriptor
+type Claims struct {
+	// ID claim must hold UUID, otherwise ID derived from issuer and claim value
+	ID          string
+	Username    string
+	Email       string
+	DisplayName string
+	// Groups claim is array of strings or single string
+	Groups string
+	// Attributes maps descriptor attributes to claims
+	Attributes map[string]string
+}
+
+// WithDefaults returns claims with default names of omitted claims
+func (c Claims) WithDefaults() Claims {
+	for claim, def := range map[*string]string{
+		&c.ID:          DefaultIDClaim,
+		&c.Username:    DefaultUsernameClaim,
+		&c.Email:       DefaultEmailClaim,
+		&c.DisplayName: DefaultDisplayNameClaim,
+		&c.Groups:      DefaultGroupsClaim,
+	} {
+		if *claim == "" {
+			*claim = def
+		}
+	}
+	return c
+}
+
+// UserID returns ID of user, ID derived from issuer and subject when subject isn't UUID
+func (c Claims) UserID(claims jwt.MapClaims) (uuid.UUID, error) {
+	sub, ok := claimString(claims, c.ID)
+	if !ok {
+		return uuid.UUID{}, errors.Errorf("no %s claim", c.ID)
+	}
+
+	if id, err := uuid.FromString(sub); err == nil {
+		return id, nil
+	}
+
+	// stable ID for subjects not being UUIDs
+	iss, _ := claimString(claims, "iss")
+	return uuid.NewV5(uuid.NamespaceURL, iss+"#"+sub), nil
+}
+
+func (c Claims) Descriptor(id uuid.UUID, claims jwt.MapClaims) user.Descriptor {
+	d := user.Descriptor{
+		ID: id,
+	}
+	d.Username, _ = claimString(claims, c.Username)
+	d.Email, _ = claimString(claims, c.Email)
+	d.DisplayName, _ = claimString(claims, c.DisplayName)
+
+	switch groups := claims[c.Groups].(type) {
+	case []any:
+		d.Groups = slices.Map(groups, func(g any) string {
+			return fmt.Sprint(g)
+		})
+	case string:
+		d.Groups = []string{groups}
+	}
+
+	for name, claim := range c.Attributes {
+		if v, ok := claimString(claims, claim); ok {
+			if d.Attributes == nil {
+				d.Attributes = map[string]string{}
+			}
+			d.Attributes[name] = v
+		}
+	}
+
+	return d
+}
+
+func (c Claims) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"id":          c.ID,
+		"username":    c.Username,
+		"email":       c.Email,
+		"displayName": c.DisplayName,
+		"groups":      c.Groups,
+		"attributes":  c.Attributes,
+	})
+}
+
+func claimString(claims jwt.MapClaims, name string) (string, bool) {
+	switch v := claims[name].(type) {
+	case nil, map[string]any, []any:
+		return "", false
+	case string:
+		return v, v != ""
+	case float64:
+		// numbers decoded as float64, format them without exponent
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	default:
+		return fmt.Sprint(v), true
+	}
+}
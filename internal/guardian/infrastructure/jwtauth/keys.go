@@ -0,0 +1,180 @@
+package jwtauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+)
+
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	ES256 = "ES256"
+)
+
+// Key verifies tokens signed with Algorithm
+type Key struct {
+	// ID matched with kid header of token, key without ID verifies tokens without kid
+	ID        string
+	Algorithm string
+	// Material is []byte for HS256, *rsa.PublicKey for RS256 and *ecdsa.PublicKey for ES256
+	Material any
+}
+
+// NewHMACKey returns HS256 key
+func NewHMACKey(id string, secret []byte) Key {
+	return Key{ID: id, Algorithm: HS256, Material: secret}
+}
+
+// ParsePublicKey parses PEM encoded public key of RS256 or ES256 algorithm
+func ParsePublicKey(id, algorithm string, data []byte) (Key, error) {
+	var material any
+	var err error
+	switch algorithm {
+	case RS256:
+		material, err = jwt.ParseRSAPublicKeyFromPEM(data)
+	case ES256:
+		material, err = jwt.ParseECPublicKeyFromPEM(data)
+	default:
+		return Key{}, errors.Errorf("unsupported public key algorithm %s, expected %s or %s", algorithm, RS256, ES256)
+	}
+	if err != nil {
+		return Key{}, errors.Wrapf(err, "failed to parse key %s", id)
+	}
+
+	return Key{ID: id, Algorithm: algorithm, Material: material}, nil
+}
+
+// KeyFunc selects keys of token algorithm, kid narrows them to single key.
+// Matching algorithm of key prevents verifying token with key of other type
+func KeyFunc(keys func() []Key) jwt.Keyfunc {
+	return func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+
+		var candidates []jwt.VerificationKey
+		for _, k := range keys() {
+			if k.Algorithm != t.Method.Alg() {
+				continue
+			}
+			if kid != "" && k.ID != kid {
+				continue
+			}
+			candidates = append(candidates, k.Material)
+		}
+
+		switch len(candidates) {
+		case 0:
+			return nil, errors.Errorf("no %s key %s", t.Method.Alg(), kid)
+		case 1:
+			return candidates[0], nil
+		default:
+			return jwt.VerificationKeySet{Keys: candidates}, nil
+		}
+	}
+}
+
+// ParseJWKS parses JSON Web Key Set, keys of unsupported types skipped
+func ParseJWKS(data []byte) ([]Key, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	err := json.Unmarshal(data, &set)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse jwks")
+	}
+
+	keys := make([]Key, 0, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, ok, err2 := k.key()
+		if err2 != nil {
+			return nil, errors.Wrapf(err2, "invalid jwk %s", k.Kid)
+		}
+		if ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+
+	// symmetric
+	K string `json:"k"`
+}
+
+func (k jwk) key() (Key, bool, error) {
+	switch {
+	case k.Kty == "RSA" && (k.Alg == "" || k.Alg == RS256):
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return Key{}, false, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return Key{}, false, err
+		}
+
+		return Key{
+			ID:        k.Kid,
+			Algorithm: RS256,
+			Material:  &rsa.PublicKey{N: n, E: int(e.Int64())},
+		}, true, nil
+	case k.Kty == "EC" && k.Crv == "P-256" && (k.Alg == "" || k.Alg == ES256):
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return Key{}, false, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return Key{}, false, err
+		}
+
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+		//nolint:staticcheck
+		if !pub.Curve.IsOnCurve(x, y) {
+			return Key{}, false, errors.New("point is not on curve")
+		}
+
+		return Key{ID: k.Kid, Algorithm: ES256, Material: pub}, true, nil
+	case k.Kty == "oct" && (k.Alg == "" || k.Alg == HS256):
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil {
+			return Key{}, false, errors.Wrap(err, "invalid k")
+		}
+
+		return NewHMACKey(k.Kid, secret), true, nil
+	default:
+		return Key{}, false, nil
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(data) == 0 {
+		return nil, errors.New("invalid base64url integer")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
@@ -0,0 +1,129 @@
+package jwtauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func TestParseJWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(map[string]any{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64(ecKey.X.Bytes()), "y": b64(ecKey.Y.Bytes())},
+		{"kty": "oct", "kid": "hmac", "alg": HS256, "k": b64(hmacSecret)},
+		// skipped
+		{"kty": "RSA", "kid": "encryption", "use": "enc", "n": b64(rsaKey.N.Bytes()), "e": "AQAB"},
+		{"kty": "RSA", "kid": "ps256", "alg": "PS256", "n": b64(rsaKey.N.Bytes()), "e": "AQAB"},
+		{"kty": "EC", "kid": "p384", "crv": "P-384", "x": "AA", "y": "AA"},
+		{"kty": "OKP", "kid": "ed25519", "crv": "Ed25519", "x": "AA"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 3 {
+		t.Fatalf("expected 3 keys, got %+v", keys)
+	}
+
+	// parsed keys verify tokens of their private keys
+	for _, tt := range []struct {
+		method jwt.SigningMethod
+		key    any
+		kid    string
+	}{
+		{jwt.SigningMethodRS256, rsaKey, "rsa"},
+		{jwt.SigningMethodES256, ecKey, "ec"},
+		{jwt.SigningMethodHS256, hmacSecret, "hmac"},
+	} {
+		raw := sign(t, tt.method, tt.key, tt.kid, validClaims())
+		if _, err = jwt.Parse(raw, KeyFunc(func() []Key { return keys })); err != nil {
+			t.Errorf("%s: %v", tt.kid, err)
+		}
+	}
+}
+
+func TestParseJWKSInvalid(t *testing.T) {
+	for name, data := range map[string]string{
+		"not json":         `keys`,
+		"rsa without n":    `{"keys":[{"kty":"RSA","kid":"k","e":"AQAB"}]}`,
+		"ec point off":     `{"keys":[{"kty":"EC","kid":"k","crv":"P-256","x":"AQ","y":"AQ"}]}`,
+		"oct bad encoding": `{"keys":[{"kty":"oct","kid":"k","k":"!!"}]}`,
+	} {
+		if _, err := ParseJWKS([]byte(data)); err == nil {
+			t.Errorf("%s: invalid jwks accepted", name)
+		}
+	}
+}
+
+func TestKeyFunc(t *testing.T) {
+	keys := func() []Key {
+		return []Key{
+			NewHMACKey("a", []byte("secret-a")),
+			NewHMACKey("b", []byte("secret-b")),
+			{ID: "a", Algorithm: RS256, Material: &rsa.PublicKey{}},
+		}
+	}
+	keyFunc := KeyFunc(keys)
+
+	tests := []struct {
+		name   string
+		method jwt.SigningMethod
+		kid    string
+		want   string
+	}{
+		{"kid selects key", jwt.SigningMethodHS256, "b", "single"},
+		{"no kid tries all keys of algorithm", jwt.SigningMethodHS256, "", "set"},
+		{"unknown kid", jwt.SigningMethodHS256, "c", "none"},
+		{"no keys of algorithm", jwt.SigningMethodES256, "", "none"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := jwt.New(tt.method)
+			if tt.kid != "" {
+				token.Header["kid"] = tt.kid
+			}
+
+			key, err := keyFunc(token)
+			got := "none"
+			switch k := key.(type) {
+			case []byte:
+				got = "single"
+				if string(k) != "secret-"+tt.kid {
+					t.Errorf("selected key %s", k)
+				}
+			case jwt.VerificationKeySet:
+				got = "set"
+				if len(k.Keys) != 2 {
+					t.Errorf("set of %d keys", len(k.Keys))
+				}
+			}
+			if got != tt.want || (got == "none") != (err != nil) {
+				t.Errorf("got %s, %v, want %s", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package ldap
+
+import (
+	"context"
+	"sync"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+func newPool(size int, dial func(ctx context.Context) (*ldap.Conn, error)) *pool {
+	return &pool{
+		dial:  dial,
+		idle:  make(chan *ldap.Conn, size),
+		slots: make(chan struct{}, size),
+	}
+}
+
+// pool keeps bound connections for reuse and limits number of open connections
+type pool struct {
+	dial func(ctx context.Context) (*ldap.Conn, error)
+
+	idle  chan *ldap.Conn
+	slots chan struct{}
+
+	mu sync.Mutex
+	// closed pool still serves in-flight requests, but closes connections returned to it
+	closed bool
+}
+
+// get returns idle connection or dials new one, waits when all connections in use
+func (p *pool) get(ctx context.Context) (*ldap.Conn, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	for {
+		select {
+		case conn := <-p.idle:
+			if conn.IsClosing() {
+				_ = conn.Close()
+				continue
+			}
+			return conn, nil
+		default:
+		}
+
+		conn, err := p.dial(ctx)
+		if err != nil {
+			<-p.slots
+			return nil, err
+		}
+		return conn, nil
+	}
+}
+
+// put returns connection to pool
+func (p *pool) put(conn *ldap.Conn) {
+	p.mu.Lock()
+	if p.closed {
+		_ = conn.Close()
+	} else {
+		select {
+		case p.idle <- conn:
+		default:
+			_ = conn.Close()
+		}
+	}
+	p.mu.Unlock()
+	<-p.slots
+}
+
+// discard closes broken connection
+func (p *pool) discard(conn *ldap.Conn) {
+	_ = conn.Close()
+	<-p.slots
+}
+
+// close closes idle connections, connections in use closed when returned
+func (p *pool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	for {
+		select {
+		case conn := <-p.idle:
+			_ = conn.Close()
+		default:
+			return
+		}
+	}
+}
@@ -1,25 +1,393 @@
-//nolint:revive,unused
 package ldap
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
+	stderrors "errors"
+	"net"
+	"strings"
+	"time"
 
+	"github.com/go-ldap/ldap/v3"
 	"github.com/gofrs/uuid/v5"
+	"github.com/pkg/errors"
 
 	"guardian/internal/guardian/app/user"
 )
 
-func NewUserProvider(address string) user.Provider {
-	return &userProvider{}
+const (
+	// IDPlaceholder replaced in Filter with escaped token ID
+	IDPlaceholder = "{id}"
+	// UsernamePlaceholder replaced in LoginFilter with escaped username
+	UsernamePlaceholder = "{username}"
+
+	DefaultFilter               = "(entryUUID=" + IDPlaceholder + ")"
+	DefaultIDAttribute          = "entryUUID"
+	DefaultUsernameAttribute    = "uid"
+	DefaultEmailAttribute       = "mail"
+	DefaultDisplayNameAttribute = "displayName"
+	DefaultGroupsAttribute      = "memberOf"
+	DefaultPoolSize             = 4
+	DefaultTimeout              = 5 * time.Second
+
+	// dummyRDN of entry bound when user not found
+	dummyRDN = "cn=guardian-dummy"
+)
+
+// errAmbiguous returned when filter matches more than one entry
+var errAmbiguous = stderrors.New("more than one entry found")
+
+type Config struct {
+	// Address in form ldap://host:389 or ldaps://host:636, host:port treated as ldap://
+	Address  string
+	StartTLS bool
+	TLS      *tls.Config
+
+	// BindDN and BindPassword used to bind connection before search, anonymous search used when BindDN empty
+	BindDN       string
+	BindPassword string
+
+	BaseDN string
+	// Filter to search user, IDPlaceholder replaced with token ID
+	Filter string
+	// LoginFilter to search user by username on Authenticate, UsernamePlaceholder replaced with username.
+	// Username attribute matched when empty
+	LoginFilter string
+	Attributes  Attributes
+
+	// PoolSize limits number of open connections
+	PoolSize int
+	Timeout  time.Duration
+}
+
+// Attributes maps ldap entry attributes to user.Descriptor
+type Attributes struct {
+	ID          string
+	Username    string
+	Email       string
+	DisplayName string
+	// Groups attribute values may be DNs like cn=admins,ou=groups,dc=example,dc=com, group name is first RDN value then
+	Groups string
+	// Extra maps descriptor attributes to ldap attributes
+	Extra map[string]string
+}
+
+// UserProvider resolves users by ldap search, Close releases pooled connections
+type UserProvider interface {
+	user.Authenticator
+	Close() error
+}
+
+func NewUserProvider(config Config) UserProvider {
+	if config.Filter == "" {
+		config.Filter = DefaultFilter
+	}
+	if config.Attributes.ID == "" {
+		config.Attributes.ID = DefaultIDAttribute
+	}
+	if config.Attributes.Username == "" {
+		config.Attributes.Username = DefaultUsernameAttribute
+	}
+	if config.LoginFilter == "" {
+		config.LoginFilter = "(" + config.Attributes.Username + "=" + UsernamePlaceholder + ")"
+	}
+	if config.Attributes.Email == "" {
+		config.Attributes.Email = DefaultEmailAttribute
+	}
+	if config.Attributes.DisplayName == "" {
+		config.Attributes.DisplayName = DefaultDisplayNameAttribute
+	}
+	if config.Attributes.Groups == "" {
+		config.Attributes.Groups = DefaultGroupsAttribute
+	}
+	if config.PoolSize <= 0 {
+		config.PoolSize = DefaultPoolSize
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+	if !strings.Contains(config.Address, "://") {
+		config.Address = "ldap://" + config.Address
+	}
+
+	p := &userProvider{
+		config: config,
+	}
+	p.pool = newPool(config.PoolSize, p.dial)
+	return p
 }
 
 type userProvider struct {
-	address string
+	config Config
+	pool   *pool
 }
 
 func (provider *userProvider) User(ctx context.Context, token user.Token) (user.Descriptor, error) {
+	filter := strings.ReplaceAll(provider.config.Filter, IDPlaceholder, ldap.EscapeFilter(token.ID.String()))
+	entry, err := provider.search(ctx, filter)
+	if err != nil {
+		if errors.Is(err, errAmbiguous) {
+			return user.Descriptor{}, errors.Wrapf(err, "ldap: user %s", token.ID)
+		}
+		return user.Descriptor{}, err
+	}
+	if entry == nil {
+		return user.Descriptor{}, errors.Wrapf(user.ErrUserNotFound, "ldap: user %s", token.ID)
+	}
+
+	return provider.descriptor(entry, token.ID.String())
+}
+
+// Authenticate searches user by username and binds with its DN and password
+func (provider *userProvider) Authenticate(ctx context.Context, username, password string) (user.Descriptor, error) {
+	// bind with empty password is unauthenticated one and succeeds
+	if username == "" || password == "" {
+		return user.Descriptor{}, errors.Wrap(user.ErrInvalidCredentials, "ldap: empty username or password")
+	}
+
+	filter := strings.ReplaceAll(provider.config.LoginFilter, UsernamePlaceholder, ldap.EscapeFilter(username))
+	entry, err := provider.search(ctx, filter)
+	switch {
+	case errors.Is(err, errAmbiguous) || errors.Is(err, user.ErrUserNotFound):
+		provider.dummyBind(ctx, password)
+		return user.Descriptor{}, errors.Wrapf(user.ErrInvalidCredentials, "ldap: username %s: %s", username, err)
+	case err != nil:
+		return user.Descriptor{}, err
+	case entry == nil:
+		provider.dummyBind(ctx, password)
+		return user.Descriptor{}, errors.Wrapf(user.ErrInvalidCredentials, "ldap: username %s not found", username)
+	}
+
+	// pooled connections are bound as service, so user bound on own connection
+	userConn, err := provider.connect(ctx)
+	if err != nil {
+		return user.Descriptor{}, errors.Wrapf(user.ErrProviderUnavailable, "ldap %s: %s", provider.config.Address, err)
+	}
+	defer userConn.Close()
+
+	err = userConn.Bind(entry.DN, password)
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return user.Descriptor{}, errors.Wrapf(user.ErrInvalidCredentials, "ldap: user %s", username)
+		}
+		return user.Descriptor{}, errors.Wrapf(user.ErrProviderUnavailable, "ldap %s: bind: %s", provider.config.Address, err)
+	}
+
+	return provider.descriptor(entry, username)
+}
+
+// dummyBind binds DN of no entry like bind of found user, so response time doesn't reveal existing users
+func (provider *userProvider) dummyBind(ctx context.Context, password string) {
+	conn, err := provider.connect(ctx)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	_ = conn.Bind(dummyRDN+","+provider.config.BaseDN, password)
+}
+
+// Close closes pooled connections, requests in flight still complete
+func (provider *userProvider) Close() error {
+	provider.pool.close()
+	return nil
+}
+
+// search returns single entry matched by filter or nil when nothing matched
+func (provider *userProvider) search(ctx context.Context, filter string) (*ldap.Entry, error) {
+	conn, err := provider.pool.get(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(user.ErrProviderUnavailable, "ldap %s: %s", provider.config.Address, err)
+	}
+
+	res, err := conn.Search(provider.newSearchRequest(filter))
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.ErrorNetwork) {
+			provider.pool.discard(conn)
+			return nil, errors.Wrapf(user.ErrProviderUnavailable, "ldap %s: %s", provider.config.Address, err)
+		}
+
+		provider.pool.put(conn)
+		switch {
+		case ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded):
+			// size limit is 2, so server stops on second entry
+			return nil, errors.WithStack(errAmbiguous)
+		case ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject):
+			return nil, errors.Wrapf(user.ErrUserNotFound, "ldap: base %s not found", provider.config.BaseDN)
+		}
+		return nil, errors.Wrap(err, "failed to search ldap user")
+	}
+	provider.pool.put(conn)
+
+	switch len(res.Entries) {
+	case 0:
+		return nil, nil
+	case 1:
+		return res.Entries[0], nil
+	default:
+		return nil, errors.WithStack(errAmbiguous)
+	}
+}
+
+func (provider *userProvider) newSearchRequest(filter string) *ldap.SearchRequest {
+	return ldap.NewSearchRequest(
+		provider.config.BaseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		2, // more than one entry is an error, no need to fetch all
+		int(provider.config.Timeout.Seconds()),
+		false,
+		filter,
+		provider.attributes(),
+		nil,
+	)
+}
+
+func (provider *userProvider) attributes() []string {
+	a := provider.config.Attributes
+	res := []string{a.ID, a.Username, a.Email, a.DisplayName, a.Groups}
+	for _, attr := range a.Extra {
+		res = append(res, attr)
+	}
+	return res
+}
+
+// descriptor maps entry to user, name identifies user in errors
+func (provider *userProvider) descriptor(entry *ldap.Entry, name string) (user.Descriptor, error) {
+	id, err := parseID(entry.GetRawAttributeValue(provider.config.Attributes.ID))
+	if err != nil {
+		return user.Descriptor{}, errors.Wrapf(err, "ldap: invalid %s of user %s", provider.config.Attributes.ID, name)
+	}
+
+	a := provider.config.Attributes
+	var attributes map[string]string
+	for name, attr := range a.Extra {
+		if v := entry.GetAttributeValue(attr); v != "" {
+			if attributes == nil {
+				attributes = map[string]string{}
+			}
+			attributes[name] = v
+		}
+	}
+
+	groups := entry.GetAttributeValues(a.Groups)
+	for i, g := range groups {
+		groups[i] = groupName(g)
+	}
+
 	return user.Descriptor{
-		ID:       uuid.Must(uuid.FromString("e1790eb1-e4dd-49ea-9e55-6132a6446d55")),
-		Username: "vadim.makerov",
+		ID:          id,
+		Username:    entry.GetAttributeValue(a.Username),
+		Email:       entry.GetAttributeValue(a.Email),
+		DisplayName: entry.GetAttributeValue(a.DisplayName),
+		Groups:      groups,
+		Attributes:  attributes,
 	}, nil
 }
+
+// groupName returns value of first RDN when group is DN
+func groupName(group string) string {
+	dn, err := ldap.ParseDN(group)
+	if err != nil || len(dn.RDNs) == 0 || len(dn.RDNs[0].Attributes) == 0 {
+		return group
+	}
+	return dn.RDNs[0].Attributes[0].Value
+}
+
+// parseID parses textual uuid like entryUUID or binary one like objectGUID
+func parseID(raw []byte) (uuid.UUID, error) {
+	if len(raw) == uuid.Size {
+		return uuid.FromBytes(raw)
+	}
+	return uuid.FromString(string(raw))
+}
+
+// dial connects and binds connection as service
+func (provider *userProvider) dial(ctx context.Context) (*ldap.Conn, error) {
+	conn, err := provider.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if provider.config.BindDN != "" {
+		err = conn.Bind(provider.config.BindDN, provider.config.BindPassword)
+		if err != nil {
+			_ = conn.Close()
+			return nil, errors.Wrap(err, "failed to bind")
+		}
+	}
+
+	return conn, nil
+}
+
+func (provider *userProvider) connect(ctx context.Context) (*ldap.Conn, error) {
+	dialer := &net.Dialer{
+		Timeout: provider.config.Timeout,
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		dialer.Deadline = deadline
+	}
+
+	conn, err := ldap.DialURL(
+		provider.config.Address,
+		ldap.DialWithDialer(dialer),
+		ldap.DialWithTLSConfig(provider.tlsConfig()),
+	)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(provider.config.Timeout)
+
+	if provider.config.StartTLS {
+		err = conn.StartTLS(provider.tlsConfig())
+		if err != nil {
+			_ = conn.Close()
+			return nil, errors.Wrap(err, "failed to start tls")
+		}
+	}
+
+	return conn, nil
+}
+
+// tlsConfig verifies server by host of address unless configured ServerName
+func (provider *userProvider) tlsConfig() *tls.Config {
+	c := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	if provider.config.TLS != nil {
+		c = provider.config.TLS.Clone()
+	}
+
+	if c.ServerName == "" {
+		address := strings.TrimPrefix(strings.TrimPrefix(provider.config.Address, "ldaps://"), "ldap://")
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			host = address
+		}
+		c.ServerName = host
+	}
+	return c
+}
+
+func (provider *userProvider) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"type":        "ldap",
+		"address":     provider.config.Address,
+		"startTLS":    provider.config.StartTLS,
+		"bindDN":      provider.config.BindDN,
+		"baseDN":      provider.config.BaseDN,
+		"filter":      provider.config.Filter,
+		"loginFilter": provider.config.LoginFilter,
+		"attributes": map[string]any{
+			"id":          provider.config.Attributes.ID,
+			"username":    provider.config.Attributes.Username,
+			"email":       provider.config.Attributes.Email,
+			"displayName": provider.config.Attributes.DisplayName,
+			"groups":      provider.config.Attributes.Groups,
+			"extra":       provider.config.Attributes.Extra,
+		},
+		"poolSize": provider.config.PoolSize,
+		"timeout":  provider.config.Timeout.String(),
+	})
+}
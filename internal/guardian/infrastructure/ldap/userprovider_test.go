@@ -0,0 +1,452 @@
+package ldap
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/gofrs/uuid/v5"
+	"github.com/pkg/errors"
+
+	"guardian/internal/guardian/app/user"
+)
+
+const (
+	serviceDN       = "cn=guardian,dc=example,dc=com"
+	servicePassword = "service"
+	baseDN          = "ou=people,dc=example,dc=com"
+)
+
+var (
+	aliceID = uuid.Must(uuid.FromString("5b3c0a44-3a7e-4b6e-9d55-5d1f1c2a7a01"))
+	bobID   = uuid.Must(uuid.FromString("5b3c0a44-3a7e-4b6e-9d55-5d1f1c2a7a02"))
+)
+
+func TestUserProviderUser(t *testing.T) {
+	s := newTestServer(t)
+	p := newTestProvider(s.address())
+
+	d, err := p.User(context.Background(), user.Token{ID: aliceID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.ID != aliceID || d.Username != "alice" || d.Email != "alice@example.com" {
+		t.Errorf("unexpected user %+v", d)
+	}
+	if len(d.Groups) != 2 || d.Groups[0] != "admins" || d.Groups[1] != "users" {
+		t.Errorf("unexpected groups %v", d.Groups)
+	}
+	if d.Attributes["department"] != "ops" {
+		t.Errorf("unexpected attributes %v", d.Attributes)
+	}
+
+	_, err = p.User(context.Background(), user.Token{ID: uuid.Must(uuid.NewV4())})
+	if errors.Cause(err) != user.ErrUserNotFound {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+
+	s.addEntry(entry("uid=alice2,"+baseDN, aliceID, "alice2", "secret"))
+	_, err = p.User(context.Background(), user.Token{ID: aliceID})
+	if !errors.Is(err, errAmbiguous) {
+		t.Errorf("expected ambiguous user error, got %v", err)
+	}
+
+	// server stops search on size limit
+	s.addEntry(entry("uid=alice3,"+baseDN, aliceID, "alice3", "secret"))
+	_, err = p.User(context.Background(), user.Token{ID: aliceID})
+	if !errors.Is(err, errAmbiguous) {
+		t.Errorf("expected ambiguous user error on size limit, got %v", err)
+	}
+}
+
+func TestUserProviderAuthenticate(t *testing.T) {
+	s := newTestServer(t)
+	p := newTestProvider(s.address())
+
+	d, err := p.Authenticate(context.Background(), "alice", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.ID != aliceID {
+		t.Errorf("unexpected user %+v", d)
+	}
+
+	s.takeUserBinds()
+
+	// unknown users bound as dummy entry, so response time doesn't reveal existing ones
+	dummyDN := dummyRDN + "," + baseDN
+	tests := []struct {
+		name     string
+		username string
+		password string
+		bind     string
+	}{
+		{"wrong password", "alice", "wrong", "uid=alice," + baseDN},
+		{"unknown user", "mallory", "secret", dummyDN},
+		{"empty password", "alice", "", ""},
+		{"filter injection", "*", "secret", dummyDN},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := p.Authenticate(context.Background(), tt.username, tt.password)
+			if errors.Cause(err) != user.ErrInvalidCredentials {
+				t.Errorf("expected ErrInvalidCredentials, got %v", err)
+			}
+			if binds := strings.Join(s.takeUserBinds(), ";"); binds != tt.bind {
+				t.Errorf("bound %q, want %q", binds, tt.bind)
+			}
+		})
+	}
+
+	s.addEntry(entry("uid=bob2,"+baseDN, uuid.Must(uuid.NewV4()), "bob", "other"))
+	s.addEntry(entry("uid=bob3,"+baseDN, uuid.Must(uuid.NewV4()), "bob", "other"))
+	_, err = p.Authenticate(context.Background(), "bob", "secret")
+	if errors.Cause(err) != user.ErrInvalidCredentials {
+		t.Errorf("expected ErrInvalidCredentials for ambiguous username, got %v", err)
+	}
+	if binds := s.takeUserBinds(); len(binds) != 1 || binds[0] != dummyDN {
+		t.Errorf("ambiguous username bound %v, want dummy entry", binds)
+	}
+}
+
+func TestUserProviderUnavailable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := ln.Addr().String()
+	_ = ln.Close()
+
+	p := newTestProvider(address)
+	_, err = p.User(context.Background(), user.Token{ID: aliceID})
+	if errors.Cause(err) != user.ErrProviderUnavailable {
+		t.Errorf("expected ErrProviderUnavailable, got %v", err)
+	}
+	_, err = p.Authenticate(context.Background(), "alice", "secret")
+	if errors.Cause(err) != user.ErrProviderUnavailable {
+		t.Errorf("expected ErrProviderUnavailable, got %v", err)
+	}
+}
+
+func TestUserProviderServiceBindFailed(t *testing.T) {
+	s := newTestServer(t)
+	p := NewUserProvider(Config{
+		Address:      s.address(),
+		BindDN:       serviceDN,
+		BindPassword: "wrong",
+		BaseDN:       baseDN,
+		Timeout:      time.Second,
+	})
+
+	_, err := p.User(context.Background(), user.Token{ID: aliceID})
+	if errors.Cause(err) != user.ErrProviderUnavailable {
+		t.Errorf("expected ErrProviderUnavailable, got %v", err)
+	}
+}
+
+func TestUserProviderClose(t *testing.T) {
+	s := newTestServer(t)
+	p := newTestProvider(s.address())
+
+	_, err := p.User(context.Background(), user.Token{ID: aliceID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := s.openConns(); n != 1 {
+		t.Fatalf("expected 1 pooled connection, got %d", n)
+	}
+
+	err = p.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.waitConns(t, 0)
+
+	// requests in flight during reload still served, connection closed after them
+	_, err = p.User(context.Background(), user.Token{ID: aliceID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.waitConns(t, 0)
+}
+
+func TestTLSConfigServerName(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		tls     *tls.Config
+		want    string
+	}{
+		{"default", "ldaps://ldap.example.com:636", nil, "ldap.example.com"},
+		{"configured tls", "ldap://ldap.example.com:389", &tls.Config{MinVersion: tls.VersionTLS13}, "ldap.example.com"},
+		{"configured server name", "ldap://10.0.0.1:389", &tls.Config{ServerName: "ldap.internal"}, "ldap.internal"},
+		{"without port", "ldaps://ldap.example.com", nil, "ldap.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewUserProvider(Config{Address: tt.address, TLS: tt.tls}).(*userProvider)
+
+			c := p.tlsConfig()
+			if c.ServerName != tt.want {
+				t.Errorf("got %q, want %q", c.ServerName, tt.want)
+			}
+			if tt.tls != nil && c == tt.tls {
+				t.Error("configured tls must be cloned, it's shared by connections")
+			}
+		})
+	}
+}
+
+func newTestProvider(address string) UserProvider {
+	return NewUserProvider(Config{
+		Address:      address,
+		BindDN:       serviceDN,
+		BindPassword: servicePassword,
+		BaseDN:       baseDN,
+		Attributes: Attributes{
+			Extra: map[string]string{"department": "departmentNumber"},
+		},
+		PoolSize: 2,
+		Timeout:  time.Second,
+	})
+}
+
+type testEntry struct {
+	dn         string
+	password   string
+	attributes map[string][]string
+}
+
+func entry(dn string, id uuid.UUID, uid, password string) testEntry {
+	return testEntry{
+		dn:       dn,
+		password: password,
+		attributes: map[string][]string{
+			"entryUUID": {id.String()},
+			"uid":       {uid},
+		},
+	}
+}
+
+// testServer is in-process ldap stand-in serving bind and equality search
+type testServer struct {
+	ln net.Listener
+
+	mu      sync.Mutex
+	entries []testEntry
+	conns   map[net.Conn]bool
+	// userBinds holds DNs bound other than service one
+	userBinds []string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alice := entry("uid=alice,"+baseDN, aliceID, "alice", "secret")
+	alice.attributes["mail"] = []string{"alice@example.com"}
+	alice.attributes["memberOf"] = []string{"cn=admins,ou=groups,dc=example,dc=com", "users"}
+	alice.attributes["departmentNumber"] = []string{"ops"}
+
+	s := &testServer{
+		ln: ln,
+		entries: []testEntry{
+			{dn: serviceDN, password: servicePassword},
+			alice,
+			entry("uid=bob,"+baseDN, bobID, "bob", "secret"),
+		},
+		conns: map[net.Conn]bool{},
+	}
+	t.Cleanup(func() {
+		_ = ln.Close()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for c := range s.conns {
+			_ = c.Close()
+		}
+	})
+
+	go s.serve()
+	return s
+}
+
+func (s *testServer) address() string {
+	return s.ln.Addr().String()
+}
+
+func (s *testServer) addEntry(e testEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, e)
+}
+
+// takeUserBinds returns DNs bound other than service one since last call
+func (s *testServer) takeUserBinds() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	binds := s.userBinds
+	s.userBinds = nil
+	return binds
+}
+
+func (s *testServer) openConns() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+func (s *testServer) waitConns(t *testing.T, n int) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		if s.openConns() == n {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected %d open connections, got %d", n, s.openConns())
+}
+
+func (s *testServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = true
+		s.mu.Unlock()
+
+		go s.handle(conn)
+	}
+}
+
+func (s *testServer) handle(conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+	}()
+
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+
+		messageID := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			dn := op.Children[1].Data.String()
+			password := op.Children[2].Data.String()
+			if dn != serviceDN {
+				s.mu.Lock()
+				s.userBinds = append(s.userBinds, dn)
+				s.mu.Unlock()
+			}
+			code := uint16(ldap.LDAPResultInvalidCredentials)
+			if e, ok := s.find(dn); ok && e.password != "" && e.password == password {
+				code = ldap.LDAPResultSuccess
+			}
+			s.write(conn, messageID, result(ldap.ApplicationBindResponse, code))
+		case ldap.ApplicationSearchRequest:
+			s.search(conn, messageID, op)
+		case ldap.ApplicationUnbindRequest:
+			return
+		default:
+			s.write(conn, messageID, result(ldap.ApplicationExtendedResponse, ldap.LDAPResultUnwillingToPerform))
+		}
+	}
+}
+
+func (s *testServer) search(conn net.Conn, messageID int64, op *ber.Packet) {
+	base := op.Children[0].Data.String()
+	sizeLimit := op.Children[3].Value.(int64)
+	filter, err := ldap.DecompileFilter(op.Children[6])
+	if err != nil {
+		s.write(conn, messageID, result(ldap.ApplicationSearchResultDone, ldap.LDAPResultProtocolError))
+		return
+	}
+
+	if !strings.HasSuffix(baseDN, base) {
+		s.write(conn, messageID, result(ldap.ApplicationSearchResultDone, ldap.LDAPResultNoSuchObject))
+		return
+	}
+
+	// stand-in supports only equality filters like (uid=alice)
+	attr, value, _ := strings.Cut(strings.Trim(filter, "()"), "=")
+
+	s.mu.Lock()
+	var found []testEntry
+	for _, e := range s.entries {
+		for _, v := range e.attributes[attr] {
+			if v == value {
+				found = append(found, e)
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	for i, e := range found {
+		if sizeLimit > 0 && int64(i) >= sizeLimit {
+			s.write(conn, messageID, result(ldap.ApplicationSearchResultDone, ldap.LDAPResultSizeLimitExceeded))
+			return
+		}
+
+		res := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "")
+		res.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.dn, ""))
+		attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+		for name, values := range e.attributes {
+			a := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+			a.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, ""))
+			vals := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "")
+			for _, v := range values {
+				vals.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, ""))
+			}
+			a.AppendChild(vals)
+			attributes.AppendChild(a)
+		}
+		res.AppendChild(attributes)
+		s.write(conn, messageID, res)
+	}
+
+	s.write(conn, messageID, result(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess))
+}
+
+func (s *testServer) find(dn string) (testEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.entries {
+		if e.dn == dn {
+			return e, true
+		}
+	}
+	return testEntry{}, false
+}
+
+func (s *testServer) write(conn net.Conn, messageID int64, op *ber.Packet) {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, ""))
+	packet.AppendChild(op)
+	_, _ = conn.Write(packet.Bytes())
+}
+
+func result(tag ber.Tag, code uint16) *ber.Packet {
+	res := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "")
+	res.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), ""))
+	res.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	res.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	return res
+}
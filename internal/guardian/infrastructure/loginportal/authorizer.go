@@ -0,0 +1,307 @@
+package loginportal
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"guardian/internal/common/infrastructure/filewatch"
+	"guardian/internal/guardian/app/proxy/downstream"
+	"guardian/internal/guardian/app/session"
+	"guardian/internal/guardian/app/user"
+)
+
+const (
+	DefaultPath       = "/login"
+	DefaultLogoutPath = "/logout"
+	DefaultCookie     = "guardian_session"
+	DefaultSessionTTL = 12 * time.Hour
+
+	csrfSuffix = "_csrf"
+	// csrfTTL limits time user may spend on login page
+	csrfTTL = time.Hour
+	// maxFormSize limits body of login form
+	maxFormSize = 16 << 10
+)
+
+//go:embed login.html
+var defaultTemplate string
+
+// DefaultTemplate renders built-in login page
+var DefaultTemplate = template.Must(template.New("login").Parse(defaultTemplate))
+
+// ParseTemplate parses login page template, it's executed with TemplateData
+func ParseTemplate(data []byte) (*template.Template, error) {
+	return template.New("login").Parse(string(data))
+}
+
+// TemplateData passed to login page template
+type TemplateData struct {
+	// Action is path form posted to
+	Action string
+	// Logout set when page asks to confirm logout, form posts only csrf_token then
+	Logout    bool
+	ReturnTo  string
+	Username  string
+	Error     string
+	CSRFToken string
+}
+
+type Config struct {
+	// Path serves login page on GET and checks credentials on POST
+	Path string
+	// LogoutPath serves logout confirmation on GET, removes session and redirects to Path on POST
+	LogoutPath string
+	// ReturnToParam of query with URL user redirected to after login
+	ReturnToParam string
+
+	Cookie     string
+	Codec      session.Codec
+	SessionTTL time.Duration
+
+	// Template overrides DefaultTemplate, reloaded on change
+	Template *filewatch.File[*template.Template]
+}
+
+// NewAuthorizer returns authorizer serving login page which checks credentials with authenticator
+// and issues session accepted by cookie authorizer with same cookie and codec
+func NewAuthorizer(config Config, authenticator user.Authenticator) downstream.Authorizer {
+	if config.Path == "" {
+		config.Path = DefaultPath
+	}
+	if config.LogoutPath == "" {
+		config.LogoutPath = DefaultLogoutPath
+	}
+	if config.ReturnToParam == "" {
+		config.ReturnToParam = downstream.DefaultReturnToParam
+	}
+	if config.Cookie == "" {
+		config.Cookie = DefaultCookie
+	}
+	if config.SessionTTL <= 0 {
+		config.SessionTTL = DefaultSessionTTL
+	}
+
+	return &authorizer{
+		config:        config,
+		sessions:      downstream.NewCookieAuthorizer(config.Cookie, config.Codec, authenticator).(sessionAuthorizer),
+		authenticator: authenticator,
+	}
+}
+
+// sessionAuthorizer authorizes users by issued sessions and renews them
+type sessionAuthorizer interface {
+	downstream.Authorizer
+	downstream.ResponseModifier
+	downstream.SessionAuthorizer
+}
+
+type authorizer struct {
+	config        Config
+	sessions      sessionAuthorizer
+	authenticator user.Authenticator
+}
+
+func (a *authorizer) Auth(ctx context.Context, r http.Request) (user.Descriptor, error) {
+	return a.sessions.Auth(ctx, r)
+}
+
+func (a *authorizer) SessionID(r http.Request) (string, bool) {
+	return a.sessions.SessionID(r)
+}
+
+func (a *authorizer) ServeAuth(w http.ResponseWriter, r *http.Request) (bool, error) {
+	switch r.URL.Path {
+	case a.config.Path:
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			a.page(w, r, TemplateData{ReturnTo: r.URL.Query().Get(a.config.ReturnToParam)}, http.StatusOK)
+			return true, nil
+		case http.MethodPost:
+			return true, a.login(w, r)
+		default:
+			w.Header().Set("Allow", "GET, HEAD, POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return true, nil
+		}
+	case a.config.LogoutPath:
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			// logout changes state, so GET only asks to confirm it
+			a.page(w, r, TemplateData{Logout: true}, http.StatusOK)
+			return true, nil
+		case http.MethodPost:
+			return true, a.logout(w, r)
+		default:
+			w.Header().Set("Allow", "GET, HEAD, POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return true, nil
+		}
+	default:
+		return false, nil
+	}
+}
+
+func (a *authorizer) ModifyResponse(r *http.Request, resp *http.Response, descriptor user.Descriptor) error {
+	return a.sessions.ModifyResponse(r, resp, descriptor)
+}
+
+// login checks credentials of posted form and issues session
+func (a *authorizer) login(w http.ResponseWriter, r *http.Request) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormSize)
+	err := r.ParseForm()
+	if err != nil {
+		return errors.Wrapf(downstream.ErrAuthDataInvalid, "login form: %s", err)
+	}
+
+	data := TemplateData{
+		ReturnTo: r.PostForm.Get("return_to"),
+		Username: r.PostForm.Get("username"),
+	}
+
+	if !a.validCSRF(r) {
+		data.Error = "Your session expired, please try again."
+		a.page(w, r, data, http.StatusForbidden)
+		return nil
+	}
+
+	descriptor, err := a.authenticator.Authenticate(r.Context(), data.Username, r.PostForm.Get("password"))
+	switch errors.Cause(err) {
+	case nil:
+	case user.ErrInvalidCredentials, user.ErrUserNotFound:
+		data.Error = "Invalid username or password."
+		a.page(w, r, data, http.StatusUnauthorized)
+		return nil
+	default:
+		return err
+	}
+
+	now := time.Now()
+	value, err := a.config.Codec.Encode(session.Session{
+		// provider is source of user, so only ID kept
+		User:      user.Descriptor{ID: descriptor.ID},
+		IssuedAt:  now,
+		ExpiresAt: now.Add(a.config.SessionTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, a.cookie(r, a.config.Cookie, value, now.Add(a.config.SessionTTL)))
+	http.SetCookie(w, a.expiredCookie(r, a.config.Cookie+csrfSuffix))
+	http.Redirect(w, r, downstream.ReturnTo(r, data.ReturnTo), http.StatusSeeOther)
+	return nil
+}
+
+// logout revokes session when posted form carries csrf token, so other sites can't log user out
+func (a *authorizer) logout(w http.ResponseWriter, r *http.Request) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormSize)
+	err := r.ParseForm()
+	if err != nil {
+		return errors.Wrapf(downstream.ErrAuthDataInvalid, "logout form: %s", err)
+	}
+
+	if !a.validCSRF(r) {
+		a.page(w, r, TemplateData{Logout: true, Error: "Your session expired, please try again."}, http.StatusForbidden)
+		return nil
+	}
+
+	if revoker, ok := a.config.Codec.(session.Revoker); ok {
+		if c, err2 := r.Cookie(a.config.Cookie); err2 == nil && c.Value != "" {
+			err = revoker.Revoke(c.Value)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	http.SetCookie(w, a.expiredCookie(r, a.config.Cookie))
+	http.SetCookie(w, a.expiredCookie(r, a.config.Cookie+csrfSuffix))
+	http.Redirect(w, r, a.config.Path, http.StatusSeeOther)
+	return nil
+}
+
+// validCSRF reports whether posted csrf_token matches token of page cookie
+func (a *authorizer) validCSRF(r *http.Request) bool {
+	c, err := r.Cookie(a.config.Cookie + csrfSuffix)
+	return err == nil && c.Value != "" &&
+		subtle.ConstantTimeCompare([]byte(c.Value), []byte(r.PostForm.Get("csrf_token"))) == 1
+}
+
+// page renders login or logout form with new csrf token
+func (a *authorizer) page(w http.ResponseWriter, r *http.Request, data TemplateData, status int) {
+	data.Action = a.config.Path
+	if data.Logout {
+		data.Action = a.config.LogoutPath
+	}
+	data.CSRFToken = randomString()
+
+	t := DefaultTemplate
+	if a.config.Template != nil {
+		t = a.config.Template.Get()
+	}
+
+	var buf bytes.Buffer
+	err := t.Execute(&buf, data)
+	if err != nil {
+		http.Error(w, "failed to render login page", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, a.cookie(r, a.config.Cookie+csrfSuffix, data.CSRFToken, time.Now().Add(csrfTTL)))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(status)
+	_, _ = w.Write(buf.Bytes())
+}
+
+func (a *authorizer) cookie(r *http.Request, name, value string, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		Secure:   r.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+func (a *authorizer) expiredCookie(r *http.Request, name string) *http.Cookie {
+	c := a.cookie(r, name, "", time.Unix(0, 0))
+	c.MaxAge = -1
+	return c
+}
+
+func (a *authorizer) MarshalJSON() ([]byte, error) {
+	res := map[string]any{
+		"type":          "login",
+		"path":          a.config.Path,
+		"logoutPath":    a.config.LogoutPath,
+		"returnToParam": a.config.ReturnToParam,
+		"cookie":        a.config.Cookie,
+		"session":       a.config.Codec,
+		"sessionTTL":    a.config.SessionTTL.String(),
+		"userProvider":  a.authenticator,
+	}
+	if a.config.Template != nil {
+		res["template"] = a.config.Template.Path()
+	}
+	return json.Marshal(res)
+}
+
+func randomString() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,186 @@
+package loginportal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/pkg/errors"
+
+	"guardian/internal/guardian/app/user"
+	"guardian/internal/guardian/infrastructure/sessioncookie"
+)
+
+type testAuthenticator struct {
+	alice user.Descriptor
+}
+
+func (a testAuthenticator) User(_ context.Context, token user.Token) (user.Descriptor, error) {
+	if token.ID != a.alice.ID {
+		return user.Descriptor{}, errors.WithStack(user.ErrUserNotFound)
+	}
+	return a.alice, nil
+}
+
+func (a testAuthenticator) Authenticate(_ context.Context, username, password string) (user.Descriptor, error) {
+	if username != a.alice.Username || password != "secret" {
+		return user.Descriptor{}, errors.WithStack(user.ErrInvalidCredentials)
+	}
+	return a.alice, nil
+}
+
+// revokingCodec records revoked sessions
+type revokingCodec struct {
+	*sessioncookie.Codec
+	revoked []string
+}
+
+func (c *revokingCodec) Revoke(value string) error {
+	c.revoked = append(c.revoked, value)
+	return nil
+}
+
+func newTestAuthorizer(t *testing.T) (*authorizer, *revokingCodec) {
+	t.Helper()
+
+	codec, err := sessioncookie.NewCodec([]sessioncookie.Key{{ID: "k1", Secret: []byte("0123456789abcdef0123456789abcdef")}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &revokingCodec{Codec: codec}
+	a := NewAuthorizer(Config{Codec: c}, testAuthenticator{
+		alice: user.Descriptor{ID: uuid.Must(uuid.NewV4()), Username: "alice"},
+	})
+	return a.(*authorizer), c
+}
+
+func serve(t *testing.T, a *authorizer, r *http.Request) *httptest.ResponseRecorder {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	served, err := a.ServeAuth(w, r)
+	if !served || err != nil {
+		t.Fatalf("%s %s not served: %v", r.Method, r.URL, err)
+	}
+	return w
+}
+
+// pageToken requests page and returns csrf cookie it issued
+func pageToken(t *testing.T, a *authorizer, path string) *http.Cookie {
+	t.Helper()
+
+	w := serve(t, a, httptest.NewRequest(http.MethodGet, path, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("page %s: status %d", path, w.Code)
+	}
+	c := responseCookie(w, DefaultCookie+csrfSuffix)
+	if c == nil || !strings.Contains(w.Body.String(), c.Value) {
+		t.Fatalf("page %s has no csrf token", path)
+	}
+	return c
+}
+
+func post(path string, form url.Values, cookies ...*http.Cookie) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	return r
+}
+
+func responseCookie(w *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestAuthorizerLogin(t *testing.T) {
+	a, _ := newTestAuthorizer(t)
+	csrf := pageToken(t, a, DefaultPath)
+
+	tests := []struct {
+		name     string
+		token    string
+		password string
+		status   int
+	}{
+		{"bad csrf", "wrong", "secret", http.StatusForbidden},
+		{"wrong password", csrf.Value, "wrong", http.StatusUnauthorized},
+		{"valid", csrf.Value, "secret", http.StatusSeeOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(t, a, post(DefaultPath, url.Values{
+				"csrf_token": {tt.token},
+				"username":   {"alice"},
+				"password":   {tt.password},
+				"return_to":  {"/app"},
+			}, csrf))
+			if w.Code != tt.status {
+				t.Fatalf("got %d, want %d", w.Code, tt.status)
+			}
+
+			sessionCookie := responseCookie(w, DefaultCookie)
+			if tt.status != http.StatusSeeOther {
+				if sessionCookie != nil {
+					t.Error("session issued")
+				}
+				return
+			}
+			if w.Header().Get("Location") != "/app" {
+				t.Errorf("redirected to %s", w.Header().Get("Location"))
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/app", nil)
+			r.AddCookie(sessionCookie)
+			descriptor, err := a.Auth(context.Background(), *r)
+			if err != nil || descriptor.Username != "alice" {
+				t.Errorf("session not accepted: %+v, %v", descriptor, err)
+			}
+		})
+	}
+}
+
+func TestAuthorizerLogout(t *testing.T) {
+	a, codec := newTestAuthorizer(t)
+	sessionCookie := &http.Cookie{Name: DefaultCookie, Value: "session-value"}
+
+	// GET only asks to confirm, so links and images of other sites can't log user out
+	w := serve(t, a, httptest.NewRequest(http.MethodGet, DefaultLogoutPath, nil))
+	if responseCookie(w, DefaultCookie) != nil || len(codec.revoked) != 0 {
+		t.Fatal("GET logged user out")
+	}
+	if !strings.Contains(w.Body.String(), `action="`+DefaultLogoutPath+`"`) {
+		t.Error("confirmation doesn't post to logout path")
+	}
+
+	csrf := pageToken(t, a, DefaultLogoutPath)
+	w = serve(t, a, post(DefaultLogoutPath, url.Values{"csrf_token": {"forged"}}, csrf, sessionCookie))
+	if w.Code != http.StatusForbidden || responseCookie(w, DefaultCookie) != nil || len(codec.revoked) != 0 {
+		t.Fatalf("logout with forged token: status %d, revoked %v", w.Code, codec.revoked)
+	}
+
+	w = serve(t, a, post(DefaultLogoutPath, url.Values{"csrf_token": {csrf.Value}}, csrf, sessionCookie))
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != DefaultPath {
+		t.Fatalf("expected redirect to login page, got %d %s", w.Code, w.Header().Get("Location"))
+	}
+	if c := responseCookie(w, DefaultCookie); c == nil || c.MaxAge >= 0 {
+		t.Error("session cookie not expired")
+	}
+	if len(codec.revoked) != 1 || codec.revoked[0] != sessionCookie.Value {
+		t.Errorf("session not revoked: %v", codec.revoked)
+	}
+
+	w = httptest.NewRecorder()
+	if _, err := a.ServeAuth(w, httptest.NewRequest(http.MethodDelete, DefaultLogoutPath, nil)); err != nil || w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE: status %d, %v", w.Code, err)
+	}
+}
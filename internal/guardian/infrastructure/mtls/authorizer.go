@@ -0,0 +1,117 @@
+package mtls
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+
+	"github.com/UsingCoding/fpgo/pkg/maybe"
+	"github.com/gofrs/uuid/v5"
+	"github.com/pkg/errors"
+
+	"guardian/internal/guardian/app/proxy/downstream"
+	"guardian/internal/guardian/app/user"
+)
+
+// Mapping selects user fields from client certificate
+type Mapping struct {
+	// ID is taken as is when it's UUID, otherwise UUIDv5 derived from it. Username used when empty
+	ID          Source            `json:"id,omitempty"`
+	Username    Source            `json:"username"`
+	Email       Source            `json:"email"`
+	DisplayName Source            `json:"displayName,omitempty"`
+	Groups      Source            `json:"groups"`
+	Attributes  map[string]Source `json:"attributes,omitempty"`
+}
+
+func (m Mapping) WithDefaults() Mapping {
+	if m.Username == "" {
+		m.Username = SourceCN
+	}
+	if m.Email == "" {
+		m.Email = SourceEmail
+	}
+	if m.Groups == "" {
+		m.Groups = SourceOU
+	}
+	return m
+}
+
+// NewAuthorizer returns authorizer of clients by certificate verified by proxy listener
+func NewAuthorizer(mapping Mapping, userProvider maybe.Maybe[user.Provider]) downstream.Authorizer {
+	return &authorizer{
+		mapping:      mapping.WithDefaults(),
+		userProvider: userProvider,
+	}
+}
+
+type authorizer struct {
+	mapping      Mapping
+	userProvider maybe.Maybe[user.Provider]
+}
+
+func (a *authorizer) Auth(ctx context.Context, r http.Request) (user.Descriptor, error) {
+	// only certificates verified against client CA of listener trusted
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return user.Descriptor{}, errors.WithStack(downstream.ErrAuthDataNotFound)
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+
+	id, err := a.userID(cert)
+	if err != nil {
+		return user.Descriptor{}, err
+	}
+
+	if provider, ok := maybe.JustValid(a.userProvider); ok {
+		descriptor, err2 := provider.User(ctx, user.Token{ID: id})
+		return descriptor, errors.WithStack(err2)
+	}
+
+	var attributes map[string]string
+	for name, src := range a.mapping.Attributes {
+		if v := src.Value(cert); v != "" {
+			if attributes == nil {
+				attributes = map[string]string{}
+			}
+			attributes[name] = v
+		}
+	}
+
+	return user.Descriptor{
+		ID:          id,
+		Username:    a.mapping.Username.Value(cert),
+		Email:       a.mapping.Email.Value(cert),
+		DisplayName: a.mapping.DisplayName.Value(cert),
+		Groups:      a.mapping.Groups.Values(cert),
+		Attributes:  attributes,
+	}, nil
+}
+
+func (a *authorizer) userID(cert *x509.Certificate) (uuid.UUID, error) {
+	src := a.mapping.ID
+	if src == "" {
+		src = a.mapping.Username
+	}
+
+	v := src.Value(cert)
+	if v == "" {
+		return uuid.Nil, errors.Wrapf(downstream.ErrAuthDataInvalid, "mtls: certificate %s has no %s", cert.Subject, src)
+	}
+
+	if id, err := uuid.FromString(v); err == nil {
+		return id, nil
+	}
+	return uuid.NewV5(uuid.NamespaceURL, "mtls#"+v), nil
+}
+
+func (a *authorizer) MarshalJSON() ([]byte, error) {
+	res := map[string]any{
+		"type":    "mtls",
+		"mapping": a.mapping,
+	}
+	if provider, ok := maybe.JustValid(a.userProvider); ok {
+		res["userProvider"] = provider
+	}
+	return json.Marshal(res)
+}
@@ -0,0 +1,116 @@
+package mtls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/UsingCoding/fpgo/pkg/maybe"
+	"github.com/gofrs/uuid/v5"
+	"github.com/pkg/errors"
+
+	"guardian/internal/guardian/app/proxy/downstream"
+	"guardian/internal/guardian/app/user"
+)
+
+var oidEmployeeID = asn1.ObjectIdentifier{2, 5, 4, 5}
+
+func TestAuthorizerAuth(t *testing.T) {
+	ca := newTestCA(t, "client ca")
+	id := uuid.Must(uuid.NewV4())
+	spiffe, _ := url.Parse("spiffe://example.com/alice")
+
+	alice := ca.issue(t, &x509.Certificate{
+		SerialNumber: big.NewInt(10),
+		Subject: pkix.Name{
+			CommonName:         "alice",
+			OrganizationalUnit: []string{"staff", "admins"},
+			ExtraNames:         []pkix.AttributeTypeAndValue{{Type: oidEmployeeID, Value: "E-42"}},
+		},
+		EmailAddresses: []string{"alice@example.com"},
+		URIs:           []*url.URL{spiffe},
+	}).Leaf
+	withID := ca.issue(t, &x509.Certificate{
+		SerialNumber: big.NewInt(11),
+		Subject:      pkix.Name{CommonName: id.String()},
+	}).Leaf
+	noCN := ca.issue(t, clientTemplate(12, "")).Leaf
+
+	a := NewAuthorizer(Mapping{
+		Attributes: map[string]Source{
+			"employee": Source("oid:2.5.4.5"),
+			"spiffe":   SourceURI,
+		},
+	}, maybe.Maybe[user.Provider]{})
+
+	tests := []struct {
+		name  string
+		state *tls.ConnectionState
+		check func(t *testing.T, d user.Descriptor)
+		err   error
+	}{
+		{
+			name:  "mapped",
+			state: &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{alice, ca.cert}}},
+			check: func(t *testing.T, d user.Descriptor) {
+				if d.ID != uuid.NewV5(uuid.NamespaceURL, "mtls#alice") || d.Username != "alice" || d.Email != "alice@example.com" ||
+					len(d.Groups) != 2 || d.Attributes["employee"] != "E-42" || d.Attributes["spiffe"] != spiffe.String() {
+					t.Errorf("unexpected descriptor %+v", d)
+				}
+			},
+		},
+		{
+			name:  "uuid kept",
+			state: &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{withID, ca.cert}}},
+			check: func(t *testing.T, d user.Descriptor) {
+				if d.ID != id {
+					t.Errorf("ID %s, want %s", d.ID, id)
+				}
+			},
+		},
+		{name: "plain http", err: downstream.ErrAuthDataNotFound},
+		// certificate presented without verification isn't trusted
+		{name: "unverified", state: &tls.ConnectionState{PeerCertificates: []*x509.Certificate{alice}}, err: downstream.ErrAuthDataNotFound},
+		{name: "no id", state: &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{noCN, ca.cert}}}, err: downstream.ErrAuthDataInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.TLS = tt.state
+
+			d, err := a.Auth(context.Background(), *r)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Errorf("got %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, d)
+		})
+	}
+}
+
+func TestParseSource(t *testing.T) {
+	for s, ok := range map[string]bool{
+		"cn":          true,
+		"email":       true,
+		"oid:2.5.4.5": true,
+		"oid:2":       false,
+		"oid:2.x":     false,
+		"subject":     false,
+	} {
+		if _, err := ParseSource(s); (err == nil) != ok {
+			t.Errorf("%s: %v", s, err)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package mtls
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RevocationLists holds CRLs of client certificate issuers
+type RevocationLists []*x509.RevocationList
+
+// ParseRevocationLists returns parser of PEM or DER CRL file, CRLs must be signed by one of issuers and not expired
+func ParseRevocationLists(issuers []*x509.Certificate) func(data []byte) (RevocationLists, error) {
+	return func(data []byte) (RevocationLists, error) {
+		var ders [][]byte
+		rest := data
+		for {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			if block.Type == "X509 CRL" {
+				ders = append(ders, block.Bytes)
+			}
+		}
+		if len(ders) == 0 {
+			// file isn't PEM, so it's single DER encoded CRL
+			ders = append(ders, data)
+		}
+
+		lists := make(RevocationLists, 0, len(ders))
+		for _, der := range ders {
+			crl, err := x509.ParseRevocationList(der)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to parse CRL")
+			}
+
+			err = checkSignature(crl, issuers)
+			if err != nil {
+				return nil, err
+			}
+			if expired(crl, time.Now()) {
+				return nil, errors.Errorf("CRL of %s expired at %s", crl.Issuer, crl.NextUpdate.Format(time.RFC3339))
+			}
+			lists = append(lists, crl)
+		}
+		return lists, nil
+	}
+}
+
+// Revoked reports whether cert revoked by CRL of its issuer
+func (lists RevocationLists) Revoked(cert *x509.Certificate) bool {
+	for _, crl := range lists {
+		if !bytes.Equal(crl.RawIssuer, cert.RawIssuer) {
+			continue
+		}
+
+		for _, entry := range crl.RevokedCertificateEntries {
+			if entry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Expired reports whether CRL of cert issuer passed its next update, so revocations since then are unknown
+func (lists RevocationLists) Expired(cert *x509.Certificate, now time.Time) bool {
+	for _, crl := range lists {
+		if bytes.Equal(crl.RawIssuer, cert.RawIssuer) && expired(crl, now) {
+			return true
+		}
+	}
+	return false
+}
+
+func expired(crl *x509.RevocationList, now time.Time) bool {
+	return !crl.NextUpdate.IsZero() && now.After(crl.NextUpdate)
+}
+
+func checkSignature(crl *x509.RevocationList, issuers []*x509.Certificate) error {
+	for _, issuer := range issuers {
+		if !bytes.Equal(issuer.RawSubject, crl.RawIssuer) {
+			continue
+		}
+		if crl.CheckSignatureFrom(issuer) == nil {
+			return nil
+		}
+	}
+	return errors.Errorf("CRL of %s isn't signed by client CA", crl.Issuer)
+}
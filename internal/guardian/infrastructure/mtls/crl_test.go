@@ -0,0 +1,228 @@
+package mtls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"guardian/internal/common/infrastructure/filewatch"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T, name string) testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testCA{cert: cert, key: key}
+}
+
+// issue returns certificate of template signed by CA
+func (ca testCA) issue(t *testing.T, template *x509.Certificate) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}
+}
+
+func (ca testCA) crl(t *testing.T, revoked ...*big.Int) []byte {
+	t.Helper()
+
+	return ca.crlUntil(t, time.Now().Add(time.Hour), revoked...)
+}
+
+// crlUntil returns CRL with next update at nextUpdate
+func (ca testCA) crlUntil(t *testing.T, nextUpdate time.Time, revoked ...*big.Int) []byte {
+	t.Helper()
+
+	template := &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: nextUpdate.Add(-2 * time.Hour),
+		NextUpdate: nextUpdate,
+	}
+	for _, serial := range revoked {
+		template.RevokedCertificateEntries = append(template.RevokedCertificateEntries, x509.RevocationListEntry{
+			SerialNumber:   serial,
+			RevocationTime: time.Now().Add(-time.Minute),
+		})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, template, ca.cert, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func clientTemplate(serial int64, cn string) *x509.Certificate {
+	return &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+}
+
+func TestParseRevocationLists(t *testing.T) {
+	ca := newTestCA(t, "client ca")
+	otherCA := newTestCA(t, "other ca")
+	parse := ParseRevocationLists([]*x509.Certificate{ca.cert})
+
+	revoked := ca.issue(t, clientTemplate(10, "revoked")).Leaf
+	valid := ca.issue(t, clientTemplate(11, "valid")).Leaf
+	// same serial of other issuer isn't revoked
+	foreign := otherCA.issue(t, clientTemplate(10, "foreign")).Leaf
+
+	der := ca.crl(t, revoked.SerialNumber)
+	for name, data := range map[string][]byte{
+		"der": der,
+		"pem": pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}),
+	} {
+		lists, err := parse(data)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !lists.Revoked(revoked) || lists.Revoked(valid) || lists.Revoked(foreign) {
+			t.Errorf("%s: revoked %v, valid %v, foreign %v", name, lists.Revoked(revoked), lists.Revoked(valid), lists.Revoked(foreign))
+		}
+
+		// list loaded before next update goes stale after it
+		if lists.Expired(valid, time.Now()) || !lists.Expired(valid, time.Now().Add(2*time.Hour)) || lists.Expired(foreign, time.Now().Add(2*time.Hour)) {
+			t.Errorf("%s: expiry of CRL not reported by its next update", name)
+		}
+	}
+
+	if _, err := parse(ca.crlUntil(t, time.Now().Add(-time.Minute))); err == nil {
+		t.Error("expired CRL accepted")
+	}
+
+	if _, err := parse(otherCA.crl(t)); err == nil {
+		t.Error("CRL of other CA accepted")
+	}
+	if _, err := parse([]byte("garbage")); err == nil {
+		t.Error("garbage accepted as CRL")
+	}
+}
+
+func TestServerTLSRejectsRevoked(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "client ca")
+
+	server := ca.issue(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "guardian"},
+		DNSNames:     []string{"guardian"},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	keyDER, err := x509.MarshalPKCS8PrivateKey(server.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(dir, "server.crt")
+	keyFile := filepath.Join(dir, "server.key")
+	crlFile := filepath.Join(dir, "clients.crl")
+	writeFile(t, certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate[0]}))
+	writeFile(t, keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}))
+
+	revoked := ca.issue(t, clientTemplate(10, "revoked"))
+	valid := ca.issue(t, clientTemplate(11, "valid"))
+	writeFile(t, crlFile, ca.crl(t, revoked.Leaf.SerialNumber))
+
+	crl, err := filewatch.NewFile(crlFile, time.Hour, ParseRevocationLists([]*x509.Certificate{ca.cert}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := NewServerTLS(ServerConfig{
+		CertFile:   certFile,
+		KeyFile:    keyFile,
+		ClientCAs:  []*x509.Certificate{ca.cert},
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		CRL:        crl,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	for name, tt := range map[string]struct {
+		cert tls.Certificate
+		ok   bool
+	}{
+		"valid":   {valid, true},
+		"revoked": {revoked, false},
+	} {
+		err = handshake(config, &tls.Config{
+			ServerName:   "guardian",
+			RootCAs:      roots,
+			Certificates: []tls.Certificate{tt.cert},
+		})
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: handshake error %v", name, err)
+		}
+	}
+}
+
+// handshake returns error of server side of handshake
+func handshake(serverConfig, clientConfig *tls.Config) error {
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+
+	go func() {
+		defer clientConn.Close()
+		_ = tls.Client(clientConn, clientConfig).Handshake()
+	}()
+	return tls.Server(serverConn, serverConfig).Handshake()
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,92 @@
+package mtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"guardian/internal/common/infrastructure/filewatch"
+)
+
+type ServerConfig struct {
+	CertFile string
+	KeyFile  string
+
+	// ClientCAs verify client certificates, certificates aren't requested when empty
+	ClientCAs  []*x509.Certificate
+	ClientAuth tls.ClientAuthType
+	// CRL checked on handshake, reloaded on change, certificates of issuer with expired CRL rejected
+	CRL *filewatch.File[RevocationLists]
+}
+
+// NewServerTLS returns tls config of proxy listener verifying client certificates
+func NewServerTLS(config ServerConfig) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load certificate")
+	}
+
+	res := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+	if len(config.ClientCAs) == 0 {
+		return res, nil
+	}
+
+	pool := x509.NewCertPool()
+	for _, ca := range config.ClientCAs {
+		pool.AddCert(ca)
+	}
+	res.ClientCAs = pool
+	res.ClientAuth = config.ClientAuth
+
+	if crl := config.CRL; crl != nil {
+		res.VerifyConnection = func(state tls.ConnectionState) error {
+			lists := crl.Get()
+			now := time.Now()
+			for _, chain := range state.VerifiedChains {
+				for _, c := range chain {
+					if lists.Revoked(c) {
+						return errors.Errorf("certificate %s revoked", c.Subject)
+					}
+					if lists.Expired(c, now) {
+						return errors.Errorf("CRL of %s expired, certificate %s can't be checked", c.Issuer, c.Subject)
+					}
+				}
+			}
+			return nil
+		}
+	}
+
+	return res, nil
+}
+
+// ParseCertificates parses PEM bundle of certificates
+func ParseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse certificate")
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New("no certificates found")
+	}
+	return certs, nil
+}
@@ -0,0 +1,110 @@
+package mtls
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Source selects values of client certificate: cn, o, ou, serial, dns, email, uri SANs or subject attribute by oid:<dotted OID>
+type Source string
+
+const (
+	SourceCN     = Source("cn")
+	SourceO      = Source("o")
+	SourceOU     = Source("ou")
+	SourceSerial = Source("serial")
+	SourceDNS    = Source("dns")
+	SourceEmail  = Source("email")
+	SourceURI    = Source("uri")
+
+	oidPrefix = "oid:"
+)
+
+func ParseSource(s string) (Source, error) {
+	switch src := Source(s); src {
+	case SourceCN, SourceO, SourceOU, SourceSerial, SourceDNS, SourceEmail, SourceURI:
+		return src, nil
+	}
+
+	oid, ok := strings.CutPrefix(s, oidPrefix)
+	if !ok {
+		return "", errors.Errorf("unknown certificate field %s, expected cn, o, ou, serial, dns, email, uri or oid:<OID>", s)
+	}
+	if _, err := parseOID(oid); err != nil {
+		return "", err
+	}
+	return Source(s), nil
+}
+
+// Values returns values selected from cert, empty source selects nothing
+func (s Source) Values(cert *x509.Certificate) []string {
+	switch s {
+	case "":
+		return nil
+	case SourceCN:
+		if cert.Subject.CommonName == "" {
+			return nil
+		}
+		return []string{cert.Subject.CommonName}
+	case SourceO:
+		return cert.Subject.Organization
+	case SourceOU:
+		return cert.Subject.OrganizationalUnit
+	case SourceSerial:
+		return []string{fmt.Sprintf("%x", cert.SerialNumber)}
+	case SourceDNS:
+		return cert.DNSNames
+	case SourceEmail:
+		return cert.EmailAddresses
+	case SourceURI:
+		res := make([]string, 0, len(cert.URIs))
+		for _, u := range cert.URIs {
+			res = append(res, u.String())
+		}
+		return res
+	}
+
+	// oid validated by ParseSource
+	oid, _ := parseOID(strings.TrimPrefix(string(s), oidPrefix))
+	var res []string
+	for _, name := range cert.Subject.Names {
+		if !name.Type.Equal(oid) {
+			continue
+		}
+		if v, ok := name.Value.(string); ok {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// Value returns first value selected from cert
+func (s Source) Value(cert *x509.Certificate) string {
+	values := s.Values(cert)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func parseOID(s string) (asn1.ObjectIdentifier, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) < 2 {
+		return nil, errors.Errorf("invalid OID %s", s)
+	}
+
+	oid := make(asn1.ObjectIdentifier, 0, len(parts))
+	for _, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nil, errors.Errorf("invalid OID %s", s)
+		}
+		oid = append(oid, n)
+	}
+	return oid, nil
+}
@@ -0,0 +1,638 @@
+package oidc
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/UsingCoding/fpgo/pkg/maybe"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+
+	"guardian/internal/guardian/app/proxy/downstream"
+	"guardian/internal/guardian/app/session"
+	"guardian/internal/guardian/app/user"
+	"guardian/internal/guardian/infrastructure/jwtauth"
+)
+
+const (
+	DefaultCookie     = "guardian_session"
+	DefaultLogoutPath = "/oauth2/logout"
+	DefaultSessionTTL = 12 * time.Hour
+	DefaultTimeout    = 10 * time.Second
+
+	// loginTTL limits time user may spend on login page of identity provider
+	loginTTL    = 10 * time.Minute
+	stateSuffix = "_state_"
+	csrfSuffix  = "_csrf"
+	// csrfTTL limits time user may spend on logout confirmation
+	csrfTTL     = time.Hour
+	maxFormSize = 1 << 16
+
+	// leeway tolerates clock skew between guardian and identity provider
+	leeway = time.Minute
+)
+
+var DefaultScopes = []string{"openid", "profile", "email"}
+
+// logoutTemplate asks to confirm logout, form posts csrf token of cookie
+var logoutTemplate = template.Must(template.New("logout").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Log out</title></head>
+<body>
+<form method="post" action="{{.Action}}">
+{{if .Error}}<p>{{.Error}}</p>{{end}}
+<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+<button type="submit">Log out</button>
+</form>
+</body>
+</html>
+`))
+
+// Codec encodes sessions and seals login state
+type Codec interface {
+	session.Codec
+	Seal(data []byte) (string, error)
+	Open(value string) ([]byte, error)
+}
+
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is callback registered in identity provider, guardian serves its path
+	RedirectURL *url.URL
+	Scopes      []string
+	// Endpoints override discovered ones, discovery skipped when authorization, token and jwks endpoints set
+	Endpoints Endpoints
+
+	// LogoutPath served by guardian, GET asks to confirm logout,
+	// POST with csrf token removes session and redirects to end session endpoint of provider
+	LogoutPath            string
+	PostLogoutRedirectURL string
+
+	Cookie     string
+	Codec      Codec
+	SessionTTL time.Duration
+	// SessionMaxAge limits renewal of active sessions, sessions aren't renewed when zero
+	SessionMaxAge time.Duration
+
+	Claims jwtauth.Claims
+	// Transport used for requests to identity provider, http.DefaultTransport when nil
+	Transport http.RoundTripper
+}
+
+// NewAuthorizer returns authorizer redirecting users without session to identity provider
+// and authorizing users by session issued after login.
+// User resolved by userProvider with ID from ID token when provider set, otherwise built from claims
+func NewAuthorizer(config Config, userProvider maybe.Maybe[user.Provider]) downstream.Authorizer {
+	if config.Cookie == "" {
+		config.Cookie = DefaultCookie
+	}
+	if config.LogoutPath == "" {
+		config.LogoutPath = DefaultLogoutPath
+	}
+	if config.SessionTTL <= 0 {
+		config.SessionTTL = DefaultSessionTTL
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = DefaultScopes
+	}
+	config.Claims = config.Claims.WithDefaults()
+
+	transport := config.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   DefaultTimeout,
+		// token endpoint must answer itself
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	return &authorizer{
+		config: config,
+		provider: &provider{
+			issuer:     config.Issuer,
+			configured: config.Endpoints,
+			client:     client,
+		},
+		client: client,
+		parser: jwt.NewParser(
+			jwt.WithValidMethods([]string{jwtauth.RS256, jwtauth.ES256}),
+			jwt.WithIssuer(config.Issuer),
+			jwt.WithAudience(config.ClientID),
+			jwt.WithExpirationRequired(),
+			jwt.WithLeeway(leeway),
+		),
+		userProvider: userProvider,
+	}
+}
+
+type authorizer struct {
+	config       Config
+	provider     *provider
+	client       *http.Client
+	parser       *jwt.Parser
+	userProvider maybe.Maybe[user.Provider]
+}
+
+// loginState kept in sealed cookie between redirect to identity provider and callback
+type loginState struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	ReturnTo string `json:"returnTo"`
+	// ExpiresAt in unix seconds, cookie expiration isn't trusted
+	ExpiresAt int64 `json:"exp"`
+}
+
+func (a *authorizer) Auth(ctx context.Context, r http.Request) (user.Descriptor, error) {
+	s, err := a.session(r)
+	if err != nil {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			// request body would be lost on redirect
+			return user.Descriptor{}, err
+		}
+		return user.Descriptor{}, a.login(ctx, r)
+	}
+
+	if provider, ok := maybe.JustValid(a.userProvider); ok {
+		descriptor, err2 := provider.User(ctx, user.Token{ID: s.User.ID})
+		return descriptor, errors.WithStack(err2)
+	}
+	return s.User, nil
+}
+
+func (a *authorizer) SessionID(r http.Request) (string, bool) {
+	s, err := a.session(r)
+	if err != nil || s.ID == "" {
+		return "", false
+	}
+	return s.ID, true
+}
+
+func (a *authorizer) session(r http.Request) (session.Session, error) {
+	c, err := r.Cookie(a.config.Cookie)
+	if err != nil || c.Value == "" {
+		return session.Session{}, errors.WithStack(downstream.ErrAuthDataNotFound)
+	}
+
+	s, err := a.config.Codec.Decode(c.Value)
+	if err != nil {
+		return session.Session{}, errors.Wrapf(downstream.ErrAuthDataInvalid, "cookie %s: %s", a.config.Cookie, err)
+	}
+	return s, nil
+}
+
+// login redirects user to authorization endpoint with PKCE challenge
+func (a *authorizer) login(ctx context.Context, r http.Request) error {
+	endpoints, err := a.provider.Endpoints(ctx)
+	if err != nil {
+		return err
+	}
+
+	state := loginState{
+		State:     randomString(),
+		Nonce:     randomString(),
+		Verifier:  randomString(),
+		ReturnTo:  r.URL.RequestURI(),
+		ExpiresAt: time.Now().Add(loginTTL).Unix(),
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	sealed, err := a.config.Codec.Seal(data)
+	if err != nil {
+		return err
+	}
+
+	challenge := sha256.Sum256([]byte(state.Verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {a.config.ClientID},
+		"redirect_uri":          {a.config.RedirectURL.String()},
+		"scope":                 {strings.Join(a.config.Scopes, " ")},
+		"state":                 {state.State},
+		"nonce":                 {state.Nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	return &downstream.ErrRedirect{
+		URL: withQuery(endpoints.Authorization, query),
+		Cookies: []*http.Cookie{
+			a.stateCookie(state.State, sealed, time.Now().Add(loginTTL)),
+		},
+	}
+}
+
+func (a *authorizer) ServeAuth(w http.ResponseWriter, r *http.Request) (bool, error) {
+	switch r.URL.Path {
+	case a.config.RedirectURL.Path:
+		return true, a.callback(w, r)
+	case a.config.LogoutPath:
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			// logout changes state, so GET only asks to confirm it
+			a.logoutPage(w, "", http.StatusOK)
+			return true, nil
+		case http.MethodPost:
+			return true, a.logout(w, r)
+		default:
+			w.Header().Set("Allow", "GET, HEAD, POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return true, nil
+		}
+	default:
+		return false, nil
+	}
+}
+
+func (a *authorizer) callback(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+	if e := query.Get("error"); e != "" {
+		return errors.Wrapf(downstream.ErrAuthDataInvalid, "oidc: login failed: %s: %s", e, query.Get("error_description"))
+	}
+
+	state, err := a.loginState(r, query.Get("state"))
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(state.State)) != 1 {
+		return errors.Wrap(downstream.ErrAuthDataInvalid, "oidc: state mismatch")
+	}
+
+	idToken, err := a.exchange(r.Context(), query.Get("code"), state.Verifier)
+	if err != nil {
+		return err
+	}
+
+	descriptor, err := a.verify(r.Context(), idToken, state.Nonce)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := maybe.JustValid(a.userProvider); ok {
+		// provider is source of user, so only ID kept
+		descriptor = user.Descriptor{ID: descriptor.ID}
+	}
+
+	now := time.Now()
+	s := session.Session{
+		User:      descriptor,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(a.config.SessionTTL),
+	}
+	value, err := a.config.Codec.Encode(s)
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, a.cookie(a.config.Cookie, value, s.ExpiresAt))
+	http.SetCookie(w, a.stateCookie(state.State, "", time.Unix(0, 0)))
+	http.Redirect(w, r, state.ReturnTo, http.StatusFound)
+	return nil
+}
+
+// loginState returns state of login started with state value,
+// state cookie keyed by it so logins started in several tabs don't replace each other
+func (a *authorizer) loginState(r *http.Request, value string) (loginState, error) {
+	if value == "" {
+		return loginState{}, errors.Wrap(downstream.ErrAuthDataInvalid, "oidc: no state")
+	}
+
+	c, err := r.Cookie(a.stateCookieName(value))
+	if err != nil {
+		return loginState{}, errors.Wrap(downstream.ErrAuthDataNotFound, "oidc: no login state")
+	}
+
+	data, err := a.config.Codec.Open(c.Value)
+	if err != nil {
+		return loginState{}, errors.Wrapf(downstream.ErrAuthDataInvalid, "oidc: login state: %s", err)
+	}
+
+	var state loginState
+	err = json.Unmarshal(data, &state)
+	if err != nil {
+		return loginState{}, errors.Wrapf(downstream.ErrAuthDataInvalid, "oidc: login state: %s", err)
+	}
+
+	if time.Now().Unix() >= state.ExpiresAt {
+		return loginState{}, errors.Wrap(downstream.ErrAuthDataInvalid, "oidc: login state expired")
+	}
+
+	if !isLocalPath(state.ReturnTo) {
+		state.ReturnTo = "/"
+	}
+	return state, nil
+}
+
+// exchange exchanges authorization code to ID token
+func (a *authorizer) exchange(ctx context.Context, code, verifier string) (string, error) {
+	endpoints, err := a.provider.Endpoints(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {a.config.RedirectURL.String()},
+		"client_id":     {a.config.ClientID},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoints.Token, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if a.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(a.config.ClientID), url.QueryEscape(a.config.ClientSecret))
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return "", errors.Wrapf(user.ErrProviderUnavailable, "oidc token endpoint: %s", err)
+	}
+	defer resp.Body.Close()
+
+	var res struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	err = json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&res)
+
+	switch {
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return "", errors.Wrapf(user.ErrProviderUnavailable, "oidc token endpoint: status %d", resp.StatusCode)
+	case err != nil:
+		return "", errors.Wrapf(err, "oidc token endpoint: status %d", resp.StatusCode)
+	case res.Error != "":
+		// invalid or reused code
+		return "", errors.Wrapf(downstream.ErrAuthDataInvalid, "oidc token endpoint: %s: %s", res.Error, res.ErrorDescription)
+	case res.IDToken == "":
+		return "", errors.Errorf("oidc token endpoint: no id_token, status %d", resp.StatusCode)
+	}
+	return res.IDToken, nil
+}
+
+// verify validates ID token and maps its claims to descriptor
+func (a *authorizer) verify(ctx context.Context, idToken, nonce string) (user.Descriptor, error) {
+	kid, err := tokenKeyID(idToken)
+	if err != nil {
+		return user.Descriptor{}, errors.Wrapf(downstream.ErrAuthDataInvalid, "oidc id token: %s", err)
+	}
+
+	keys, err := a.provider.Keys(ctx, kid)
+	if err != nil {
+		return user.Descriptor{}, err
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = a.parser.ParseWithClaims(idToken, claims, jwtauth.KeyFunc(func() []jwtauth.Key {
+		return keys
+	}))
+	if err != nil {
+		return user.Descriptor{}, errors.Wrapf(downstream.ErrAuthDataInvalid, "oidc id token: %s", err)
+	}
+
+	tokenNonce, _ := claims["nonce"].(string)
+	if subtle.ConstantTimeCompare([]byte(tokenNonce), []byte(nonce)) != 1 {
+		return user.Descriptor{}, errors.Wrap(downstream.ErrAuthDataInvalid, "oidc id token: nonce mismatch")
+	}
+
+	id, err := a.config.Claims.UserID(claims)
+	if err != nil {
+		return user.Descriptor{}, errors.Wrapf(downstream.ErrAuthDataInvalid, "oidc id token: %s", err)
+	}
+	return a.config.Claims.Descriptor(id, claims), nil
+}
+
+// logout removes session when posted form carries csrf token, so other sites can't log user out,
+// and redirects to end session endpoint of provider when it's known
+func (a *authorizer) logout(w http.ResponseWriter, r *http.Request) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormSize)
+	err := r.ParseForm()
+	if err != nil {
+		return errors.Wrapf(downstream.ErrAuthDataInvalid, "oidc: logout form: %s", err)
+	}
+
+	c, err := r.Cookie(a.config.Cookie + csrfSuffix)
+	if err != nil || c.Value == "" ||
+		subtle.ConstantTimeCompare([]byte(c.Value), []byte(r.PostForm.Get("csrf_token"))) != 1 {
+		a.logoutPage(w, "Your session expired, please try again.", http.StatusForbidden)
+		return nil
+	}
+
+	http.SetCookie(w, a.expiredCookie(a.config.Cookie))
+	http.SetCookie(w, a.csrfCookie("", time.Unix(0, 0)))
+
+	target := a.config.PostLogoutRedirectURL
+	if target == "" {
+		target = "/"
+	}
+
+	endpoints, err := a.provider.Endpoints(r.Context())
+	if err == nil && endpoints.EndSession != "" {
+		query := url.Values{
+			"client_id": {a.config.ClientID},
+		}
+		if a.config.PostLogoutRedirectURL != "" {
+			query.Set("post_logout_redirect_uri", a.config.PostLogoutRedirectURL)
+		}
+		target = withQuery(endpoints.EndSession, query)
+	}
+
+	http.Redirect(w, r, target, http.StatusSeeOther)
+	return nil
+}
+
+// logoutPage renders logout confirmation with new csrf token
+func (a *authorizer) logoutPage(w http.ResponseWriter, message string, status int) {
+	token := randomString()
+
+	var buf bytes.Buffer
+	err := logoutTemplate.Execute(&buf, struct {
+		Action    string
+		Error     string
+		CSRFToken string
+	}{
+		Action:    a.config.LogoutPath,
+		Error:     message,
+		CSRFToken: token,
+	})
+	if err != nil {
+		http.Error(w, "failed to render logout page", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, a.csrfCookie(token, time.Now().Add(csrfTTL)))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(status)
+	_, _ = w.Write(buf.Bytes())
+}
+
+// ModifyResponse renews session of active user when half of its lifetime passed, renewal limited by SessionMaxAge
+func (a *authorizer) ModifyResponse(r *http.Request, resp *http.Response, _ user.Descriptor) error {
+	if a.config.SessionMaxAge <= 0 {
+		return nil
+	}
+
+	s, err := a.session(*r)
+	if err != nil {
+		return nil
+	}
+
+	now := time.Now()
+	if s.ExpiresAt.Sub(now) > a.config.SessionTTL/2 {
+		return nil
+	}
+
+	expiresAt := now.Add(a.config.SessionTTL)
+	if limit := s.IssuedAt.Add(a.config.SessionMaxAge); expiresAt.After(limit) {
+		expiresAt = limit
+	}
+	if !expiresAt.After(s.ExpiresAt) {
+		return nil
+	}
+
+	s.ExpiresAt = expiresAt
+	value, err := a.config.Codec.Encode(s)
+	if err != nil {
+		return err
+	}
+
+	resp.Header.Add("Set-Cookie", a.cookie(a.config.Cookie, value, expiresAt).String())
+	return nil
+}
+
+func (a *authorizer) cookie(name, value string, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		Secure:   a.config.RedirectURL.Scheme == "https",
+		HttpOnly: true,
+		// Lax lets cookie be sent on redirect back from identity provider
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// stateCookie sent only to callback, expired when value is empty
+func (a *authorizer) stateCookie(state, value string, expires time.Time) *http.Cookie {
+	c := a.cookie(a.stateCookieName(state), value, expires)
+	c.Path = a.config.RedirectURL.Path
+	if value == "" {
+		c.MaxAge = -1
+	}
+	return c
+}
+
+// csrfCookie sent only to logout path, expired when value is empty
+func (a *authorizer) csrfCookie(value string, expires time.Time) *http.Cookie {
+	c := a.cookie(a.config.Cookie+csrfSuffix, value, expires)
+	c.Path = a.config.LogoutPath
+	if value == "" {
+		c.MaxAge = -1
+	}
+	return c
+}
+
+func (a *authorizer) stateCookieName(state string) string {
+	return a.config.Cookie + stateSuffix + state
+}
+
+func (a *authorizer) expiredCookie(name string) *http.Cookie {
+	c := a.cookie(name, "", time.Unix(0, 0))
+	c.MaxAge = -1
+	return c
+}
+
+func (a *authorizer) MarshalJSON() ([]byte, error) {
+	res := map[string]any{
+		"type":         "oidc",
+		"issuer":       a.config.Issuer,
+		"clientID":     a.config.ClientID,
+		"redirectURL":  a.config.RedirectURL.String(),
+		"scopes":       a.config.Scopes,
+		"endpoints":    a.config.Endpoints,
+		"logoutPath":   a.config.LogoutPath,
+		"cookie":       a.config.Cookie,
+		"session":      a.config.Codec,
+		"sessionTTL":   a.config.SessionTTL.String(),
+		"claims":       a.config.Claims,
+		"clientSecret": a.config.ClientSecret != "",
+	}
+	if a.config.PostLogoutRedirectURL != "" {
+		res["postLogoutRedirectURL"] = a.config.PostLogoutRedirectURL
+	}
+	if a.config.SessionMaxAge != 0 {
+		res["sessionMaxAge"] = a.config.SessionMaxAge.String()
+	}
+	if provider, ok := maybe.JustValid(a.userProvider); ok {
+		res["userProvider"] = provider
+	}
+	return json.Marshal(res)
+}
+
+// tokenKeyID returns kid header of token without verifying it
+func tokenKeyID(token string) (string, error) {
+	header, _, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", errors.New("malformed token")
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(header)
+	if err != nil {
+		return "", errors.New("malformed token header")
+	}
+
+	var h struct {
+		Kid string `json:"kid"`
+	}
+	err = json.Unmarshal(data, &h)
+	if err != nil {
+		return "", errors.New("malformed token header")
+	}
+	return h.Kid, nil
+}
+
+// isLocalPath reports whether redirect to path stays on same host
+func isLocalPath(p string) bool {
+	return strings.HasPrefix(p, "/") && !strings.HasPrefix(p, "//") && !strings.HasPrefix(p, "/\\")
+}
+
+func withQuery(endpoint string, query url.Values) string {
+	sep := "?"
+	if strings.Contains(endpoint, "?") {
+		sep = "&"
+	}
+	return endpoint + sep + query.Encode()
+}
+
+func randomString() string {
+	b := make([]byte, 32)
+	// crypto/rand never fails on supported platforms
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
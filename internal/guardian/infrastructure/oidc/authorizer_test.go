@@ -0,0 +1,518 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/UsingCoding/fpgo/pkg/maybe"
+	"github.com/gofrs/uuid/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+
+	"guardian/internal/guardian/app/proxy/downstream"
+	"guardian/internal/guardian/app/session"
+	"guardian/internal/guardian/app/user"
+	"guardian/internal/guardian/infrastructure/sessioncookie"
+)
+
+const (
+	testClientID     = "guardian"
+	testClientSecret = "client-secret"
+	testKeyID        = "idp-key"
+)
+
+// testIdP is identity provider serving discovery, token and jwks endpoints,
+// authorization endpoint simulated by authorize
+type testIdP struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+	sub uuid.UUID
+
+	mu     sync.Mutex
+	grants map[string]grant
+}
+
+type grant struct {
+	nonce       string
+	challenge   string
+	redirectURI string
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &testIdP{
+		key:    key,
+		sub:    uuid.Must(uuid.NewV4()),
+		grants: map[string]grant{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(discoveryPath, func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/jwks",
+			"end_session_endpoint":   idp.URL + "/logout",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": testKeyID,
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", idp.token)
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+// authorize acts as authorization endpoint with logged in user and returns callback URL with issued code
+func (idp *testIdP) authorize(t *testing.T, authorizationURL string) string {
+	t.Helper()
+
+	u, err := url.Parse(authorizationURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(authorizationURL, idp.URL+"/authorize?") {
+		t.Fatalf("unexpected authorization endpoint %s", authorizationURL)
+	}
+
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("client_id") != testClientID {
+		t.Fatalf("unexpected authorization request %s", u.RawQuery)
+	}
+
+	code := randomString()
+	idp.mu.Lock()
+	idp.grants[code] = grant{
+		nonce:       q.Get("nonce"),
+		challenge:   q.Get("code_challenge"),
+		redirectURI: q.Get("redirect_uri"),
+	}
+	idp.mu.Unlock()
+
+	return q.Get("redirect_uri") + "?" + url.Values{"code": {code}, "state": {q.Get("state")}}.Encode()
+}
+
+func (idp *testIdP) token(w http.ResponseWriter, r *http.Request) {
+	clientID, secret, ok := r.BasicAuth()
+	if !ok || clientID != testClientID || secret != testClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	idp.mu.Lock()
+	g, ok := idp.grants[r.PostFormValue("code")]
+	// code is single use
+	delete(idp.grants, r.PostFormValue("code"))
+	idp.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !ok ||
+		r.PostFormValue("grant_type") != "authorization_code" ||
+		r.PostFormValue("redirect_uri") != g.redirectURI ||
+		base64.RawURLEncoding.EncodeToString(challenge[:]) != g.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":                idp.URL,
+		"aud":                testClientID,
+		"sub":                idp.sub.String(),
+		"exp":                now.Add(time.Hour).Unix(),
+		"iat":                now.Unix(),
+		"nonce":              g.nonce,
+		"preferred_username": "alice",
+		"email":              "alice@example.com",
+	})
+	token.Header["kid"] = testKeyID
+	idToken, err := token.SignedString(idp.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"id_token": idToken, "token_type": "Bearer"})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func newTestAuthorizer(t *testing.T, idp *testIdP, sessionMaxAge time.Duration) (*authorizer, *sessioncookie.Codec) {
+	t.Helper()
+
+	codec, err := sessioncookie.NewCodec([]sessioncookie.Key{{ID: "k1", Secret: []byte("0123456789abcdef0123456789abcdef")}}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := NewAuthorizer(Config{
+		Issuer:                idp.URL,
+		ClientID:              testClientID,
+		ClientSecret:          testClientSecret,
+		RedirectURL:           &url.URL{Scheme: "https", Host: "app.example.com", Path: "/oauth2/callback"},
+		PostLogoutRedirectURL: "https://app.example.com/",
+		Codec:                 codec,
+		SessionTTL:            time.Hour,
+		SessionMaxAge:         sessionMaxAge,
+	}, maybe.Maybe[user.Provider]{})
+	return a.(*authorizer), codec
+}
+
+// startLogin requests protected page without session and returns redirect to identity provider
+func startLogin(t *testing.T, a *authorizer, target string) *downstream.ErrRedirect {
+	t.Helper()
+
+	err := authRequest(a, httptest.NewRequest(http.MethodGet, target, nil))
+	var redirect *downstream.ErrRedirect
+	if !errors.As(err, &redirect) {
+		t.Fatalf("expected redirect to identity provider, got %v", err)
+	}
+	if len(redirect.Cookies) != 1 {
+		t.Fatalf("expected state cookie, got %v", redirect.Cookies)
+	}
+	return redirect
+}
+
+func authRequest(a *authorizer, r *http.Request) error {
+	_, err := a.Auth(context.Background(), *r)
+	return err
+}
+
+// callback serves callback URL with cookies and returns response
+func callback(t *testing.T, a *authorizer, callbackURL string, cookies ...*http.Cookie) (*httptest.ResponseRecorder, error) {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodGet, callbackURL, nil)
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	served, err := a.ServeAuth(w, r)
+	if !served {
+		t.Fatalf("callback %s not served", callbackURL)
+	}
+	return w, err
+}
+
+func responseCookie(w *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestAuthorizerLogin(t *testing.T) {
+	idp := newTestIdP(t)
+	a, _ := newTestAuthorizer(t, idp, 0)
+
+	redirect := startLogin(t, a, "/app?tab=1")
+	stateCookie := redirect.Cookies[0]
+	if stateCookie.Path != "/oauth2/callback" || !strings.HasPrefix(stateCookie.Name, DefaultCookie+stateSuffix) {
+		t.Errorf("unexpected state cookie %s", stateCookie)
+	}
+
+	w, err := callback(t, a, idp.authorize(t, redirect.URL), stateCookie)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/app?tab=1" {
+		t.Fatalf("expected redirect back, got %d %s", w.Code, w.Header().Get("Location"))
+	}
+	if c := responseCookie(w, stateCookie.Name); c == nil || c.MaxAge >= 0 {
+		t.Error("state cookie not expired")
+	}
+	sessionCookie := responseCookie(w, DefaultCookie)
+	if sessionCookie == nil || sessionCookie.Value == "" {
+		t.Fatal("session cookie not set")
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/app", nil)
+	r.AddCookie(sessionCookie)
+	descriptor, err := a.Auth(context.Background(), *r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if descriptor.ID != idp.sub || descriptor.Username != "alice" || descriptor.Email != "alice@example.com" {
+		t.Errorf("unexpected user %+v", descriptor)
+	}
+	if id, ok := a.SessionID(*r); !ok || id == "" {
+		t.Error("session ID not found")
+	}
+}
+
+func TestAuthorizerConcurrentLogins(t *testing.T) {
+	idp := newTestIdP(t)
+	a, _ := newTestAuthorizer(t, idp, 0)
+
+	first := startLogin(t, a, "/first")
+	second := startLogin(t, a, "/second")
+	if first.Cookies[0].Name == second.Cookies[0].Name {
+		t.Fatal("logins share state cookie")
+	}
+
+	// browser sends both state cookies to callback
+	cookies := []*http.Cookie{first.Cookies[0], second.Cookies[0]}
+	for _, tt := range []struct {
+		redirect *downstream.ErrRedirect
+		returnTo string
+	}{
+		{second, "/second"},
+		{first, "/first"},
+	} {
+		w, err := callback(t, a, idp.authorize(t, tt.redirect.URL), cookies...)
+		if err != nil {
+			t.Fatalf("login to %s: %v", tt.returnTo, err)
+		}
+		if location := w.Header().Get("Location"); location != tt.returnTo {
+			t.Errorf("got redirect to %s, want %s", location, tt.returnTo)
+		}
+	}
+}
+
+func TestAuthorizerCallbackRejected(t *testing.T) {
+	idp := newTestIdP(t)
+	a, codec := newTestAuthorizer(t, idp, 0)
+
+	// forge sealed state under cookie of other login
+	forged := func(redirect *downstream.ErrRedirect, modify func(s *loginState)) *http.Cookie {
+		data, err := codec.Open(redirect.Cookies[0].Value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var s loginState
+		if err = json.Unmarshal(data, &s); err != nil {
+			t.Fatal(err)
+		}
+		modify(&s)
+		data, _ = json.Marshal(s)
+		sealed, err := codec.Seal(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := *redirect.Cookies[0]
+		c.Value = sealed
+		return &c
+	}
+
+	tests := []struct {
+		name    string
+		request func(redirect *downstream.ErrRedirect) (string, *http.Cookie)
+	}{
+		{
+			name: "no state cookie",
+			request: func(redirect *downstream.ErrRedirect) (string, *http.Cookie) {
+				return idp.authorize(t, redirect.URL), &http.Cookie{Name: "other", Value: "x"}
+			},
+		},
+		{
+			name: "unknown state",
+			request: func(redirect *downstream.ErrRedirect) (string, *http.Cookie) {
+				callbackURL := idp.authorize(t, redirect.URL)
+				return strings.Replace(callbackURL, "state=", "state=x", 1), redirect.Cookies[0]
+			},
+		},
+		{
+			name: "state mismatch",
+			request: func(redirect *downstream.ErrRedirect) (string, *http.Cookie) {
+				return idp.authorize(t, redirect.URL), forged(redirect, func(s *loginState) { s.State = randomString() })
+			},
+		},
+		{
+			name: "pkce mismatch",
+			request: func(redirect *downstream.ErrRedirect) (string, *http.Cookie) {
+				return idp.authorize(t, redirect.URL), forged(redirect, func(s *loginState) { s.Verifier = randomString() })
+			},
+		},
+		{
+			name: "nonce mismatch",
+			request: func(redirect *downstream.ErrRedirect) (string, *http.Cookie) {
+				return idp.authorize(t, redirect.URL), forged(redirect, func(s *loginState) { s.Nonce = randomString() })
+			},
+		},
+		{
+			name: "login state expired",
+			request: func(redirect *downstream.ErrRedirect) (string, *http.Cookie) {
+				return idp.authorize(t, redirect.URL), forged(redirect, func(s *loginState) { s.ExpiresAt = time.Now().Unix() - 1 })
+			},
+		},
+		{
+			name: "login failed",
+			request: func(redirect *downstream.ErrRedirect) (string, *http.Cookie) {
+				return "/oauth2/callback?error=access_denied", redirect.Cookies[0]
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			callbackURL, c := tt.request(startLogin(t, a, "/app"))
+			w, err := callback(t, a, callbackURL, c)
+			if !errors.Is(err, downstream.ErrAuthDataInvalid) && !errors.Is(err, downstream.ErrAuthDataNotFound) {
+				t.Errorf("expected invalid auth data, got %v", err)
+			}
+			if responseCookie(w, DefaultCookie) != nil {
+				t.Error("session issued")
+			}
+		})
+	}
+}
+
+func TestAuthorizerLogout(t *testing.T) {
+	idp := newTestIdP(t)
+	a, _ := newTestAuthorizer(t, idp, 0)
+	sessionCookie := &http.Cookie{Name: DefaultCookie, Value: "session-value"}
+
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		served, err := a.ServeAuth(w, r)
+		if !served || err != nil {
+			t.Fatalf("%s %s not served: %v", r.Method, r.URL, err)
+		}
+		return w
+	}
+	post := func(token string, cookies ...*http.Cookie) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, DefaultLogoutPath, strings.NewReader(url.Values{"csrf_token": {token}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+		return r
+	}
+
+	// GET from links and images of other sites only asks to confirm
+	r := httptest.NewRequest(http.MethodGet, DefaultLogoutPath, nil)
+	r.AddCookie(sessionCookie)
+	w := serve(r)
+	csrf := responseCookie(w, DefaultCookie+csrfSuffix)
+	if w.Code != http.StatusOK || responseCookie(w, DefaultCookie) != nil || w.Header().Get("Location") != "" {
+		t.Fatalf("GET logged user out: status %d", w.Code)
+	}
+	if csrf == nil || csrf.Path != DefaultLogoutPath || !strings.Contains(w.Body.String(), csrf.Value) {
+		t.Fatal("confirmation has no csrf token")
+	}
+
+	for name, r := range map[string]*http.Request{
+		"forged token":   post("forged", csrf, sessionCookie),
+		"no csrf cookie": post(csrf.Value, sessionCookie),
+	} {
+		w = serve(r)
+		if w.Code != http.StatusForbidden || responseCookie(w, DefaultCookie) != nil {
+			t.Errorf("%s: status %d", name, w.Code)
+		}
+	}
+
+	w = serve(post(csrf.Value, csrf, sessionCookie))
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("status %d", w.Code)
+	}
+	if c := responseCookie(w, DefaultCookie); c == nil || c.MaxAge >= 0 {
+		t.Error("session cookie not expired")
+	}
+	location, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := location.Query()
+	if !strings.HasPrefix(location.String(), idp.URL+"/logout?") ||
+		q.Get("client_id") != testClientID ||
+		q.Get("post_logout_redirect_uri") != "https://app.example.com/" {
+		t.Errorf("unexpected redirect to %s", location)
+	}
+
+	w = httptest.NewRecorder()
+	if _, err = a.ServeAuth(w, httptest.NewRequest(http.MethodDelete, DefaultLogoutPath, nil)); err != nil || w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE: status %d, %v", w.Code, err)
+	}
+}
+
+func TestAuthorizerRenewal(t *testing.T) {
+	idp := newTestIdP(t)
+	a, codec := newTestAuthorizer(t, idp, 3*time.Hour)
+
+	now := time.Now()
+	tests := []struct {
+		name      string
+		issuedAt  time.Time
+		expiresAt time.Time
+		// renewedTo is expected expiration, zero when session isn't renewed
+		renewedTo time.Time
+	}{
+		{"fresh", now, now.Add(time.Hour), time.Time{}},
+		{"half passed", now.Add(-40 * time.Minute), now.Add(20 * time.Minute), now.Add(time.Hour)},
+		{"limited by max age", now.Add(-170 * time.Minute), now.Add(5 * time.Minute), now.Add(10 * time.Minute)},
+		{"max age reached", now.Add(-175 * time.Minute), now.Add(5 * time.Minute), time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := codec.Encode(session.Session{
+				User:      user.Descriptor{ID: idp.sub},
+				IssuedAt:  tt.issuedAt,
+				ExpiresAt: tt.expiresAt,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := httptest.NewRequest(http.MethodGet, "/app", nil)
+			r.AddCookie(&http.Cookie{Name: DefaultCookie, Value: value})
+			resp := &http.Response{Header: http.Header{}}
+
+			if err = a.ModifyResponse(r, resp, user.Descriptor{}); err != nil {
+				t.Fatal(err)
+			}
+
+			cookies := (&http.Response{Header: resp.Header}).Cookies()
+			if tt.renewedTo.IsZero() {
+				if len(cookies) != 0 {
+					t.Errorf("session renewed: %v", cookies)
+				}
+				return
+			}
+			if len(cookies) != 1 {
+				t.Fatalf("session not renewed")
+			}
+
+			renewed, err := codec.Decode(cookies[0].Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := renewed.ExpiresAt.Sub(tt.renewedTo); d < -time.Second || d > time.Second {
+				t.Errorf("renewed to %s, want %s", renewed.ExpiresAt, tt.renewedTo)
+			}
+			if d := renewed.IssuedAt.Sub(tt.issuedAt); d < -time.Second || d > time.Second {
+				t.Errorf("issue time changed to %s", renewed.IssuedAt)
+			}
+		})
+	}
+}
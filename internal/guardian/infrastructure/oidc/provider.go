@@ -0,0 +1,160 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"guardian/internal/guardian/app/user"
+	"guardian/internal/guardian/infrastructure/jwtauth"
+)
+
+const (
+	discoveryPath = "/.well-known/openid-configuration"
+
+	// keysTTL is how long fetched keys used before refetch
+	keysTTL = time.Hour
+	// refetchInterval limits refetching keys when token signed by unknown key
+	refetchInterval = time.Minute
+
+	maxResponseSize = 1 << 20
+)
+
+// Endpoints of identity provider
+type Endpoints struct {
+	Authorization string `json:"authorization_endpoint"`
+	Token         string `json:"token_endpoint"`
+	JWKS          string `json:"jwks_uri"`
+	// EndSession is optional logout endpoint
+	EndSession string `json:"end_session_endpoint"`
+}
+
+func (e Endpoints) complete() bool {
+	return e.Authorization != "" && e.Token != "" && e.JWKS != ""
+}
+
+// merge returns endpoints with empty ones taken from other
+func (e Endpoints) merge(other Endpoints) Endpoints {
+	for field, value := range map[*string]string{
+		&e.Authorization: other.Authorization,
+		&e.Token:         other.Token,
+		&e.JWKS:          other.JWKS,
+		&e.EndSession:    other.EndSession,
+	} {
+		if *field == "" {
+			*field = value
+		}
+	}
+	return e
+}
+
+// provider discovers endpoints of identity provider and fetches its keys lazily,
+// so guardian starts when identity provider unavailable
+type provider struct {
+	issuer     string
+	configured Endpoints
+	client     *http.Client
+
+	mu        sync.Mutex
+	endpoints *Endpoints
+	keys      []jwtauth.Key
+	fetchedAt time.Time
+}
+
+func (p *provider) Endpoints(ctx context.Context) (Endpoints, error) {
+	if p.configured.complete() {
+		return p.configured, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.endpoints != nil {
+		return *p.endpoints, nil
+	}
+
+	var discovered Endpoints
+	err := p.getJSON(ctx, strings.TrimSuffix(p.issuer, "/")+discoveryPath, &discovered)
+	if err != nil {
+		return Endpoints{}, errors.Wrap(err, "oidc discovery")
+	}
+
+	endpoints := p.configured.merge(discovered)
+	if !endpoints.complete() {
+		return Endpoints{}, errors.Errorf("oidc discovery of %s: incomplete provider metadata", p.issuer)
+	}
+
+	p.endpoints = &endpoints
+	return endpoints, nil
+}
+
+// Keys returns keys of provider, keys refetched when kid is unknown
+func (p *provider) Keys(ctx context.Context, kid string) ([]jwtauth.Key, error) {
+	endpoints, err := p.Endpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	since := time.Since(p.fetchedAt)
+	if since < keysTTL && (hasKey(p.keys, kid) || since < refetchInterval) {
+		return p.keys, nil
+	}
+
+	var data json.RawMessage
+	err = p.getJSON(ctx, endpoints.JWKS, &data)
+	if err != nil {
+		if p.keys != nil {
+			// keep serving known keys while provider unavailable
+			return p.keys, nil
+		}
+		return nil, errors.Wrap(err, "oidc jwks")
+	}
+
+	keys, err := jwtauth.ParseJWKS(data)
+	if err != nil {
+		return nil, err
+	}
+
+	p.keys = keys
+	p.fetchedAt = time.Now()
+	return keys, nil
+}
+
+func hasKey(keys []jwtauth.Key, kid string) bool {
+	for _, k := range keys {
+		if kid == "" || k.ID == kid {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *provider) getJSON(ctx context.Context, u string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return errors.Wrapf(user.ErrProviderUnavailable, "%s: %s", u, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Wrapf(user.ErrProviderUnavailable, "%s: status %d", u, resp.StatusCode)
+	}
+
+	err = json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v)
+	return errors.Wrapf(err, "failed to decode %s", u)
+}
@@ -0,0 +1,317 @@
+package sessioncookie
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/pkg/errors"
+
+	"guardian/internal/guardian/app/session"
+	"guardian/internal/guardian/app/user"
+)
+
+// Value formats:
+//
+//	signed:    v1.<kid>.<base64url payload>.<base64url HMAC-SHA256 of preceding part>
+//	encrypted: v1e.<kid>.<base64url nonce and AES-256-GCM sealed payload>
+//
+// Payload is JSON {"sid": "<session id>", "sub": "<user id>", "iat": <unix>, "exp": <unix>}
+// with optional binding "bnd" and user fields
+//
+// Sealed state is s1.<kid>.<base64url nonce and AES-256-GCM sealed data>
+const (
+	signedVersion    = "v1"
+	encryptedVersion = "v1e"
+	sealedVersion    = "s1"
+
+	// MinSecretSize is minimal size of key secret in bytes
+	MinSecretSize = 32
+
+	// clockSkew tolerated for sessions issued by other hosts
+	clockSkew = time.Minute
+
+	sessionIDSize = 16
+)
+
+var encoding = base64.RawURLEncoding
+
+type Key struct {
+	// ID put into session to find key verifying it
+	ID     string
+	Secret []byte
+}
+
+// NewCodec returns codec encoding sessions with first key and decoding sessions of any key,
+// so new key added first and old one kept while issued sessions alive
+func NewCodec(keys []Key, encrypt bool) (*Codec, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no session keys")
+	}
+
+	c := &Codec{
+		encrypt: encrypt,
+		keys:    map[string]derivedKey{},
+	}
+	for _, k := range keys {
+		if k.ID == "" || strings.Contains(k.ID, ".") {
+			return nil, errors.Errorf("invalid session key id %q", k.ID)
+		}
+		if len(k.Secret) < MinSecretSize {
+			return nil, errors.Errorf("secret of session key %s shorter than %d bytes", k.ID, MinSecretSize)
+		}
+		if _, ok := c.keys[k.ID]; ok {
+			return nil, errors.Errorf("session key %s defined twice", k.ID)
+		}
+
+		c.keys[k.ID] = derive(k.Secret)
+		c.keyIDs = append(c.keyIDs, k.ID)
+	}
+
+	return c, nil
+}
+
+var _ session.Codec = (*Codec)(nil)
+
+// Codec encodes sessions and seals short living state like login requests
+type Codec struct {
+	encrypt bool
+	keys    map[string]derivedKey
+	// keyIDs in order of definition, first one used to encode
+	keyIDs []string
+}
+
+// derivedKey holds independent keys for signing and encryption derived from secret
+type derivedKey struct {
+	sign    []byte
+	encrypt []byte
+	seal    []byte
+}
+
+func derive(secret []byte) derivedKey {
+	return derivedKey{
+		sign:    mac(secret, []byte("guardian session sign")),
+		encrypt: mac(secret, []byte("guardian session encrypt")),
+		seal:    mac(secret, []byte("guardian state seal")),
+	}
+}
+
+type payload struct {
+	Sid string    `json:"sid"`
+	Bnd string    `json:"bnd,omitempty"`
+	Sub uuid.UUID `json:"sub"`
+	Iat int64     `json:"iat"`
+	Exp int64     `json:"exp"`
+
+	Username    string            `json:"username,omitempty"`
+	Email       string            `json:"email,omitempty"`
+	DisplayName string            `json:"displayName,omitempty"`
+	Groups      []string          `json:"groups,omitempty"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+}
+
+func (c *Codec) Encode(s session.Session) (string, error) {
+	id := s.ID
+	if id == "" {
+		b := make([]byte, sessionIDSize)
+		_, err := rand.Read(b)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to generate session id")
+		}
+		id = encoding.EncodeToString(b)
+	}
+
+	data, err := json.Marshal(payload{
+		Sid:         id,
+		Bnd:         s.Binding,
+		Sub:         s.User.ID,
+		Iat:         s.IssuedAt.Unix(),
+		Exp:         s.ExpiresAt.Unix(),
+		Username:    s.User.Username,
+		Email:       s.User.Email,
+		DisplayName: s.User.DisplayName,
+		Groups:      s.User.Groups,
+		Attributes:  s.User.Attributes,
+	})
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	kid := c.keyIDs[0]
+	key := c.keys[kid]
+
+	if !c.encrypt {
+		signed := signedVersion + "." + kid + "." + encoding.EncodeToString(data)
+		return signed + "." + encoding.EncodeToString(mac(key.sign, []byte(signed))), nil
+	}
+
+	return seal(key.encrypt, encryptedVersion+"."+kid, data)
+}
+
+// Seal encrypts data with first key
+func (c *Codec) Seal(data []byte) (string, error) {
+	kid := c.keyIDs[0]
+	return seal(c.keys[kid].seal, sealedVersion+"."+kid, data)
+}
+
+// Open decrypts sealed data, returns session.ErrInvalid when data tampered
+func (c *Codec) Open(value string) ([]byte, error) {
+	parts := strings.Split(value, ".")
+	if len(parts) != 3 || parts[0] != sealedVersion {
+		return nil, errors.Wrap(session.ErrInvalid, "malformed sealed data")
+	}
+
+	key, ok := c.keys[parts[1]]
+	if !ok {
+		return nil, errors.Wrapf(session.ErrInvalid, "unknown key %s", parts[1])
+	}
+	return open(key.seal, parts)
+}
+
+func seal(key []byte, header string, data []byte) (string, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to generate nonce")
+	}
+
+	sealed := aead.Seal(nonce, nonce, data, []byte(header))
+	return header + "." + encoding.EncodeToString(sealed), nil
+}
+
+// Decode accepts both signed and encrypted sessions to allow switching encryption without logging out users
+func (c *Codec) Decode(value string) (session.Session, error) {
+	parts := strings.Split(value, ".")
+	if len(parts) < 3 {
+		return session.Session{}, errors.Wrap(session.ErrInvalid, "malformed session")
+	}
+
+	key, ok := c.keys[parts[1]]
+	if !ok {
+		return session.Session{}, errors.Wrapf(session.ErrInvalid, "unknown session key %s", parts[1])
+	}
+
+	var data []byte
+	var err error
+	switch {
+	case parts[0] == signedVersion && len(parts) == 4:
+		data, err = verify(key, parts)
+	case parts[0] == encryptedVersion && len(parts) == 3:
+		data, err = open(key.encrypt, parts)
+	default:
+		return session.Session{}, errors.Wrap(session.ErrInvalid, "unknown session format")
+	}
+	if err != nil {
+		return session.Session{}, err
+	}
+
+	var p payload
+	err = json.Unmarshal(data, &p)
+	if err != nil {
+		return session.Session{}, errors.Wrapf(session.ErrInvalid, "malformed payload: %s", err)
+	}
+
+	if p.Sid == "" {
+		return session.Session{}, errors.Wrap(session.ErrInvalid, "session has no id")
+	}
+
+	s := session.Session{
+		ID:      p.Sid,
+		Binding: p.Bnd,
+		User: user.Descriptor{
+			ID:          p.Sub,
+			Username:    p.Username,
+			Email:       p.Email,
+			DisplayName: p.DisplayName,
+			Groups:      p.Groups,
+			Attributes:  p.Attributes,
+		},
+		IssuedAt:  time.Unix(p.Iat, 0),
+		ExpiresAt: time.Unix(p.Exp, 0),
+	}
+
+	now := time.Now()
+	if !now.Before(s.ExpiresAt) {
+		return session.Session{}, errors.Wrapf(session.ErrExpired, "expired at %s", s.ExpiresAt.UTC().Format(time.RFC3339))
+	}
+	if s.IssuedAt.After(now.Add(clockSkew)) {
+		return session.Session{}, errors.Wrap(session.ErrInvalid, "issued in future")
+	}
+
+	return s, nil
+}
+
+func verify(key derivedKey, parts []string) ([]byte, error) {
+	sig, err := encoding.DecodeString(parts[3])
+	if err != nil {
+		return nil, errors.Wrap(session.ErrInvalid, "malformed signature")
+	}
+
+	signed := strings.Join(parts[:3], ".")
+	if !hmac.Equal(sig, mac(key.sign, []byte(signed))) {
+		return nil, errors.Wrap(session.ErrInvalid, "signature mismatch")
+	}
+
+	data, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.Wrap(session.ErrInvalid, "malformed payload")
+	}
+	return data, nil
+}
+
+func open(key []byte, parts []string) ([]byte, error) {
+	sealed, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.Wrap(session.ErrInvalid, "malformed payload")
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.Wrap(session.ErrInvalid, "malformed payload")
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	data, err := aead.Open(nil, nonce, ciphertext, []byte(parts[0]+"."+parts[1]))
+	if err != nil {
+		return nil, errors.Wrap(session.ErrInvalid, "decryption failed")
+	}
+	return data, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	return aead, errors.WithStack(err)
+}
+
+func mac(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func (c *Codec) MarshalJSON() ([]byte, error) {
+	// secrets omitted
+	return json.Marshal(map[string]any{
+		"encrypt": c.encrypt,
+		"keys":    c.keyIDs,
+	})
+}
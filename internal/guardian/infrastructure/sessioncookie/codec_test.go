@@ -0,0 +1,220 @@
+package sessioncookie
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/pkg/errors"
+
+	"guardian/internal/guardian/app/session"
+	"guardian/internal/guardian/app/user"
+)
+
+var (
+	oldKey = Key{ID: "2024-01", Secret: []byte("old-secret-old-secret-old-secret")}
+	newKey = Key{ID: "2024-06", Secret: []byte("new-secret-new-secret-new-secret")}
+)
+
+func newTestCodec(t *testing.T, encrypt bool, keys ...Key) *Codec {
+	t.Helper()
+
+	c, err := NewCodec(keys, encrypt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func testSession() session.Session {
+	now := time.Now().Truncate(time.Second)
+	return session.Session{
+		Binding: "primary",
+		User: user.Descriptor{
+			ID:         uuid.Must(uuid.NewV4()),
+			Username:   "alice",
+			Email:      "alice@example.com",
+			Groups:     []string{"staff"},
+			Attributes: map[string]string{"team": "platform"},
+		},
+		IssuedAt:  now,
+		ExpiresAt: now.Add(time.Hour),
+	}
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	for encrypt, version := range map[bool]string{false: signedVersion, true: encryptedVersion} {
+		c := newTestCodec(t, encrypt, newKey)
+		s := testSession()
+
+		value, err := c.Encode(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(value, version+"."+newKey.ID+".") {
+			t.Errorf("encrypt %v: unexpected format %s", encrypt, value)
+		}
+		if encrypt && strings.Count(value, ".") != 2 {
+			t.Errorf("encrypted session has plain payload: %s", value)
+		}
+
+		decoded, err := c.Decode(value)
+		if err != nil {
+			t.Fatalf("encrypt %v: %v", encrypt, err)
+		}
+		if decoded.ID == "" || decoded.Binding != s.Binding || decoded.User.ID != s.User.ID ||
+			decoded.User.Username != "alice" || decoded.User.Attributes["team"] != "platform" ||
+			!decoded.IssuedAt.Equal(s.IssuedAt) || !decoded.ExpiresAt.Equal(s.ExpiresAt) {
+			t.Errorf("encrypt %v: unexpected session %+v", encrypt, decoded)
+		}
+
+		// renewed session keeps ID
+		decoded.ExpiresAt = decoded.ExpiresAt.Add(time.Hour)
+		renewed, err := c.Encode(decoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r, _ := c.Decode(renewed); r.ID != decoded.ID {
+			t.Errorf("encrypt %v: renewal changed ID %s to %s", encrypt, decoded.ID, r.ID)
+		}
+	}
+}
+
+func TestCodecKeyRotation(t *testing.T) {
+	old := newTestCodec(t, false, oldKey)
+	rotated := newTestCodec(t, true, newKey, oldKey)
+	dropped := newTestCodec(t, false, newKey)
+
+	value, err := old.Encode(testSession())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// signed session of old key accepted after switching to encryption
+	if _, err = rotated.Decode(value); err != nil {
+		t.Errorf("session of old key rejected: %v", err)
+	}
+	if _, err = dropped.Decode(value); !errors.Is(err, session.ErrInvalid) {
+		t.Errorf("session of removed key accepted: %v", err)
+	}
+
+	value, err = rotated.Encode(testSession())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(value, encryptedVersion+"."+newKey.ID+".") {
+		t.Errorf("session not encoded with first key: %s", value)
+	}
+}
+
+func TestCodecRejects(t *testing.T) {
+	c := newTestCodec(t, false, newKey)
+	encrypted := newTestCodec(t, true, newKey)
+
+	valid, err := c.Encode(testSession())
+	if err != nil {
+		t.Fatal(err)
+	}
+	validEncrypted, err := encrypted.Encode(testSession())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expiredSession := testSession()
+	expiredSession.ExpiresAt = time.Now().Add(-time.Second)
+	expired, err := c.Encode(expiredSession)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	futureSession := testSession()
+	futureSession.IssuedAt = time.Now().Add(time.Hour)
+	futureSession.ExpiresAt = time.Now().Add(2 * time.Hour)
+	future, err := c.Encode(futureSession)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// validly signed session without ID
+	signed := signedVersion + "." + newKey.ID + "." + encoding.EncodeToString([]byte(fmt.Sprintf(
+		`{"sub":"%s","iat":%d,"exp":%d}`, uuid.Must(uuid.NewV4()), time.Now().Unix(), time.Now().Add(time.Hour).Unix(),
+	)))
+	withoutID := signed + "." + encoding.EncodeToString(mac(c.keys[newKey.ID].sign, []byte(signed)))
+
+	parts := strings.Split(valid, ".")
+	tamperedPayload := encoding.EncodeToString([]byte(`{"sub":"` + uuid.Must(uuid.NewV4()).String() + `","iat":0,"exp":9999999999}`))
+
+	tests := []struct {
+		name  string
+		value string
+		err   error
+	}{
+		{"empty", "", session.ErrInvalid},
+		{"malformed", "v1.x", session.ErrInvalid},
+		{"unknown version", "v9." + strings.Join(parts[1:], "."), session.ErrInvalid},
+		{"tampered payload", strings.Join([]string{parts[0], parts[1], tamperedPayload, parts[3]}, "."), session.ErrInvalid},
+		{"tampered signature", valid[:len(valid)-2] + "AA", session.ErrInvalid},
+		{"tampered ciphertext", validEncrypted[:len(validEncrypted)-2] + "AA", session.ErrInvalid},
+		{"key id swapped", strings.Replace(valid, newKey.ID, oldKey.ID, 1), session.ErrInvalid},
+		{"issued in future", future, session.ErrInvalid},
+		{"without id", withoutID, session.ErrInvalid},
+		{"expired", expired, session.ErrExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := c.Decode(tt.value); !errors.Is(err, tt.err) {
+				t.Errorf("got %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestCodecSeal(t *testing.T) {
+	c := newTestCodec(t, false, newKey, oldKey)
+
+	sealed, err := c.Seal([]byte("state"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := c.Open(sealed)
+	if err != nil || string(data) != "state" {
+		t.Fatalf("got %q, %v", data, err)
+	}
+
+	// sealed state can't pass for session and back
+	if _, err = c.Decode(sealed); !errors.Is(err, session.ErrInvalid) {
+		t.Errorf("sealed state decoded as session: %v", err)
+	}
+	value, err := newTestCodec(t, true, newKey).Encode(testSession())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Open(value); !errors.Is(err, session.ErrInvalid) {
+		t.Errorf("session opened as sealed state: %v", err)
+	}
+	if _, err = c.Open(sealed[:len(sealed)-2] + "AA"); !errors.Is(err, session.ErrInvalid) {
+		t.Errorf("tampered state opened: %v", err)
+	}
+}
+
+func TestNewCodecValidatesKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []Key
+	}{
+		{"no keys", nil},
+		{"empty id", []Key{{Secret: newKey.Secret}}},
+		{"id with dot", []Key{{ID: "a.b", Secret: newKey.Secret}}},
+		{"short secret", []Key{{ID: "k", Secret: []byte("short")}}},
+		{"duplicate", []Key{newKey, newKey}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewCodec(tt.keys, false); err == nil {
+				t.Error("invalid keys accepted")
+			}
+		})
+	}
+}
@@ -0,0 +1,315 @@
+package sessionstore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/pkg/errors"
+
+	"guardian/internal/guardian/app/session"
+)
+
+const (
+	opPut        = "put"
+	opTouch      = "touch"
+	opDelete     = "delete"
+	opDeleteUser = "deleteUser"
+
+	// log compacted when it has more than compactMinEntries entries and compactRatio times more entries than sessions
+	compactMinEntries = 1000
+	compactRatio      = 4
+
+	// maxEntrySize limits line of log
+	maxEntrySize = 64 << 10
+)
+
+// NewFileStore returns store keeping sessions in memory and appending changes to log file,
+// sessions restored from file on start. File must be used by single guardian process.
+// File opened on first use, so config can be validated without creating it
+func NewFileStore(path string) (session.Store, error) {
+	dir := filepath.Dir(path)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "session store directory")
+	}
+	if !info.IsDir() {
+		return nil, errors.Errorf("session store directory %s is not a directory", dir)
+	}
+
+	return &fileStore{
+		path:    path,
+		records: newRecords(),
+	}, nil
+}
+
+type fileStore struct {
+	path string
+
+	mu      sync.Mutex
+	file    *os.File
+	records *records
+	// entries is number of entries in log
+	entries int
+	// torn set when log doesn't end with line break
+	torn bool
+}
+
+// entry is line of log
+type entry struct {
+	Op     string          `json:"op"`
+	ID     string          `json:"id,omitempty"`
+	UserID *uuid.UUID      `json:"user,omitempty"`
+	Record *session.Record `json:"record,omitempty"`
+	At     *time.Time      `json:"at,omitempty"`
+}
+
+func (s *fileStore) Create(r session.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.append(entry{Op: opPut, Record: &r})
+	if err != nil {
+		return err
+	}
+	s.records.sweep(time.Now())
+	s.records.put(r)
+	return s.compact()
+}
+
+func (s *fileStore) Get(id string) (session.Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.open()
+	if err != nil {
+		return session.Record{}, err
+	}
+	return s.records.get(id)
+}
+
+func (s *fileStore) Touch(id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.open()
+	if err != nil {
+		return err
+	}
+	if _, err = s.records.get(id); err != nil {
+		return err
+	}
+
+	err = s.append(entry{Op: opTouch, ID: id, At: &at})
+	if err != nil {
+		return err
+	}
+	_ = s.records.touch(id, at)
+	return s.compact()
+}
+
+func (s *fileStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.open()
+	if err != nil {
+		return err
+	}
+	if _, err = s.records.get(id); err != nil {
+		return err
+	}
+
+	err = s.append(entry{Op: opDelete, ID: id})
+	if err != nil {
+		return err
+	}
+	_ = s.records.delete(id)
+	return s.compact()
+}
+
+func (s *fileStore) DeleteUser(userID uuid.UUID) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.append(entry{Op: opDeleteUser, UserID: &userID})
+	if err != nil {
+		return 0, err
+	}
+	n := s.records.deleteUser(userID)
+	return n, s.compact()
+}
+
+func (s *fileStore) append(e entry) error {
+	err := s.open()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = s.file.Write(append(data, '\n'))
+	if err != nil {
+		return errors.Wrapf(err, "failed to write session store %s", s.path)
+	}
+	s.entries++
+	return nil
+}
+
+// open replays log into memory and opens it for appending, done once
+func (s *fileStore) open() error {
+	if s.file != nil {
+		return nil
+	}
+
+	err := s.replay()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return errors.Wrap(err, "failed to open session store")
+	}
+	if s.torn {
+		// terminate partially written entry, so next one isn't appended to it
+		_, err = f.Write([]byte{'\n'})
+		if err != nil {
+			_ = f.Close()
+			return errors.Wrapf(err, "failed to write session store %s", s.path)
+		}
+	}
+	s.file = f
+	return nil
+}
+
+func (s *fileStore) replay() error {
+	s.records = newRecords()
+	s.entries = 0
+	s.torn = false
+
+	f, err := os.Open(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrap(err, "failed to open session store")
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, maxEntrySize)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var e entry
+		// last entry may be partially written on crash
+		if json.Unmarshal(line, &e) != nil {
+			continue
+		}
+		s.entries++
+
+		switch e.Op {
+		case opPut:
+			if e.Record != nil {
+				s.records.put(*e.Record)
+			}
+		case opTouch:
+			if e.At != nil {
+				_ = s.records.touch(e.ID, *e.At)
+			}
+		case opDelete:
+			_ = s.records.delete(e.ID)
+		case opDeleteUser:
+			if e.UserID != nil {
+				s.records.deleteUser(*e.UserID)
+			}
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return errors.Wrapf(err, "failed to read session store %s", s.path)
+	}
+
+	if info, err2 := f.Stat(); err2 == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err2 = f.ReadAt(last, info.Size()-1); err2 == nil {
+			s.torn = last[0] != '\n'
+		}
+	}
+
+	s.records.sweep(time.Now())
+	return nil
+}
+
+// compact rewrites log with live sessions only when it grown too much
+func (s *fileStore) compact() error {
+	if s.entries <= compactMinEntries || s.entries <= compactRatio*len(s.records.byID) {
+		return nil
+	}
+
+	s.records.sweep(time.Now())
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return errors.Wrap(err, "failed to compact session store")
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, r := range s.records.byID {
+		err = enc.Encode(entry{Op: opPut, Record: &r})
+		if err != nil {
+			_ = tmp.Close()
+			return errors.Wrap(err, "failed to compact session store")
+		}
+	}
+	err = w.Flush()
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to compact session store")
+	}
+
+	err = os.Chmod(tmp.Name(), 0o600)
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path)
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to compact session store")
+	}
+
+	_ = s.file.Close()
+	s.file = nil
+	s.entries = len(s.records.byID)
+
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return errors.Wrap(err, "failed to open session store")
+	}
+	s.file = f
+	return nil
+}
+
+func (s *fileStore) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"type": "file",
+		"path": s.path,
+	})
+}
@@ -0,0 +1,163 @@
+package sessionstore
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/pkg/errors"
+
+	"guardian/internal/guardian/app/session"
+)
+
+func newRecord(id string, userID uuid.UUID) session.Record {
+	now := time.Now().Truncate(time.Second)
+	return session.Record{
+		ID:           id,
+		UserID:       userID,
+		CreatedAt:    now,
+		LastAccessAt: now,
+		ExpiresAt:    now.Add(time.Hour),
+	}
+}
+
+func newTestFileStore(t *testing.T, path string) session.Store {
+	t.Helper()
+
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestFileStoreReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.log")
+	alice := uuid.Must(uuid.NewV4())
+	bob := uuid.Must(uuid.NewV4())
+
+	s := newTestFileStore(t, path)
+	for _, r := range []session.Record{
+		newRecord("kept", alice),
+		newRecord("deleted", alice),
+		newRecord("bob-1", bob),
+		newRecord("bob-2", bob),
+	} {
+		if err := s.Create(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expired := newRecord("expired", alice)
+	expired.ExpiresAt = time.Now().Add(-time.Second)
+	if err := s.Create(expired); err != nil {
+		t.Fatal(err)
+	}
+
+	touchedAt := time.Now().Add(time.Minute).Truncate(time.Second)
+	if err := s.Touch("kept", touchedAt); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("deleted"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.DeleteUser(bob); err != nil || n != 2 {
+		t.Fatalf("deleted %d sessions of user, %v", n, err)
+	}
+
+	// last entry torn by crash
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"op":"delete","id":"ke`)
+	_ = f.Close()
+
+	restored := newTestFileStore(t, path)
+	r, err := restored.Get("kept")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.LastAccessAt.Equal(touchedAt) || r.UserID != alice {
+		t.Errorf("unexpected restored record %+v", r)
+	}
+	for _, id := range []string{"deleted", "bob-1", "bob-2", "expired"} {
+		if _, err = restored.Get(id); !errors.Is(err, session.ErrNotFound) {
+			t.Errorf("session %s restored: %v", id, err)
+		}
+	}
+
+	// entry after torn one isn't lost
+	if err = restored.Create(newRecord("after-crash", alice)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = newTestFileStore(t, path).Get("after-crash"); err != nil {
+		t.Errorf("session created after crash lost: %v", err)
+	}
+}
+
+func TestFileStoreCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.log")
+	userID := uuid.Must(uuid.NewV4())
+
+	s := newTestFileStore(t, path)
+	if err := s.Create(newRecord("kept", userID)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < compactMinEntries; i++ {
+		id := fmt.Sprintf("short-%d", i)
+		if err := s.Create(newRecord(id, userID)); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Delete(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := countLines(t, path); n > compactMinEntries {
+		t.Errorf("log not compacted, %d entries", n)
+	}
+
+	// store keeps appending after compaction
+	if err := s.Create(newRecord("after", userID)); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := newTestFileStore(t, path)
+	for _, id := range []string{"kept", "after"} {
+		if _, err := restored.Get(id); err != nil {
+			t.Errorf("session %s lost: %v", id, err)
+		}
+	}
+	if _, err := restored.Get("short-0"); !errors.Is(err, session.ErrNotFound) {
+		t.Errorf("deleted session restored: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("compacted log has mode %o", perm)
+	}
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	n := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,137 @@
+package sessionstore
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/pkg/errors"
+
+	"guardian/internal/guardian/app/session"
+)
+
+// sweepInterval is how often expired records removed
+const sweepInterval = time.Minute
+
+// NewMemoryStore returns store keeping sessions in memory, sessions lost on restart
+func NewMemoryStore() session.Store {
+	return &memoryStore{
+		records: newRecords(),
+	}
+}
+
+type memoryStore struct {
+	mu      sync.Mutex
+	records *records
+}
+
+func (s *memoryStore) Create(r session.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records.sweep(time.Now())
+	s.records.put(r)
+	return nil
+}
+
+func (s *memoryStore) Get(id string) (session.Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.records.get(id)
+}
+
+func (s *memoryStore) Touch(id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.records.touch(id, at)
+}
+
+func (s *memoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.records.delete(id)
+}
+
+func (s *memoryStore) DeleteUser(userID uuid.UUID) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.records.deleteUser(userID), nil
+}
+
+func (s *memoryStore) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"type": "memory",
+	})
+}
+
+func newRecords() *records {
+	return &records{
+		byID: map[string]session.Record{},
+	}
+}
+
+// records indexes sessions by ID, callers synchronize access
+type records struct {
+	byID      map[string]session.Record
+	lastSweep time.Time
+}
+
+func (rs *records) get(id string) (session.Record, error) {
+	r, ok := rs.byID[id]
+	if !ok {
+		return session.Record{}, errors.WithStack(session.ErrNotFound)
+	}
+	return r, nil
+}
+
+func (rs *records) put(r session.Record) {
+	rs.byID[r.ID] = r
+}
+
+func (rs *records) touch(id string, at time.Time) error {
+	r, ok := rs.byID[id]
+	if !ok {
+		return errors.WithStack(session.ErrNotFound)
+	}
+	r.LastAccessAt = at
+	rs.byID[id] = r
+	return nil
+}
+
+func (rs *records) delete(id string) error {
+	if _, ok := rs.byID[id]; !ok {
+		return errors.WithStack(session.ErrNotFound)
+	}
+	delete(rs.byID, id)
+	return nil
+}
+
+func (rs *records) deleteUser(userID uuid.UUID) int {
+	n := 0
+	for id, r := range rs.byID {
+		if r.UserID == userID {
+			delete(rs.byID, id)
+			n++
+		}
+	}
+	return n
+}
+
+// sweep removes expired records at most once per sweepInterval
+func (rs *records) sweep(now time.Time) {
+	if now.Sub(rs.lastSweep) < sweepInterval {
+		return
+	}
+	rs.lastSweep = now
+
+	for id, r := range rs.byID {
+		if !now.Before(r.ExpiresAt) {
+			delete(rs.byID, id)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package static
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/pkg/errors"
+
+	"guardian/internal/common/infrastructure/filewatch"
+	"guardian/internal/guardian/app/user"
+	"guardian/internal/guardian/infrastructure/htpasswd"
+)
+
+type User struct {
+	ID          uuid.UUID         `json:"id"`
+	Username    string            `json:"username"`
+	Email       string            `json:"email,omitempty"`
+	DisplayName string            `json:"displayName,omitempty"`
+	Groups      []string          `json:"groups,omitempty"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	// PasswordHash is bcrypt or {SHA} hash checked by Authenticate, user can't log in when empty
+	PasswordHash string `json:"passwordHash,omitempty"`
+}
+
+// CSV columns mapped to User fields, other columns are attributes
+const (
+	emailColumn       = "email"
+	displayNameColumn = "displayName"
+	// groupsColumn holds groups separated by groupsSeparator
+	groupsColumn       = "groups"
+	groupsSeparator    = ";"
+	passwordHashColumn = "passwordHash"
+)
+
+// NewUserProvider returns provider serving inline users and users from file.
+// File reloaded when changed, it may be JSON array of users or CSV with id, username, email, displayName, groups, passwordHash and attribute columns
+func NewUserProvider(users []User, file *filewatch.File[Users]) (user.Authenticator, error) {
+	inline, err := index(users)
+	if err != nil {
+		return nil, err
+	}
+
+	return &userProvider{
+		inline: inline,
+		file:   file,
+	}, nil
+}
+
+type userProvider struct {
+	inline Users
+	file   *filewatch.File[Users]
+}
+
+func (provider *userProvider) User(_ context.Context, token user.Token) (user.Descriptor, error) {
+	u, ok := provider.inline[token.ID]
+	if !ok && provider.file != nil {
+		u, ok = provider.file.Get()[token.ID]
+	}
+
+	if !ok {
+		return user.Descriptor{}, errors.Wrapf(user.ErrUserNotFound, "static: user %s", token.ID)
+	}
+
+	return u.descriptor(), nil
+}
+
+func (provider *userProvider) Authenticate(_ context.Context, username, password string) (user.Descriptor, error) {
+	u, ok := provider.inline.find(username)
+	if !ok && provider.file != nil {
+		u, ok = provider.file.Get().find(username)
+	}
+
+	// unknown users checked with empty hash too, so response time doesn't reveal them
+	if !htpasswd.CheckPassword(u.PasswordHash, password) || !ok {
+		return user.Descriptor{}, errors.Wrapf(user.ErrInvalidCredentials, "static: user %s", username)
+	}
+	return u.descriptor(), nil
+}
+
+func (u User) descriptor() user.Descriptor {
+	return user.Descriptor{
+		ID:          u.ID,
+		Username:    u.Username,
+		Email:       u.Email,
+		DisplayName: u.DisplayName,
+		Groups:      u.Groups,
+		Attributes:  u.Attributes,
+	}
+}
+
+func (provider *userProvider) MarshalJSON() ([]byte, error) {
+	res := map[string]any{
+		"type":  "static",
+		"users": len(provider.inline),
+	}
+	if provider.file != nil {
+		res["file"] = provider.file.Path()
+	}
+	return json.Marshal(res)
+}
+
+// Users indexed by ID
+type Users map[uuid.UUID]User
+
+// ParseUsersFile parses users file by its extension
+func ParseUsersFile(path string) func(data []byte) (Users, error) {
+	return func(data []byte) (Users, error) {
+		var users []User
+		var err error
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".json":
+			err = json.Unmarshal(data, &users)
+		case ".csv":
+			users, err = parseCSV(data)
+		default:
+			return nil, errors.Errorf("unknown users file format %s, json and csv supported", filepath.Ext(path))
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return index(users)
+	}
+}
+
+// parseCSV parses users from csv with header: id,username and optional user field or attribute columns
+func parseCSV(data []byte) ([]User, error) {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	header := records[0]
+	if len(header) < 2 || header[0] != "id" || header[1] != "username" {
+		return nil, errors.New("csv header must start with id,username")
+	}
+
+	users := make([]User, 0, len(records)-1)
+	for i, record := range records[1:] {
+		id, err2 := uuid.FromString(record[0])
+		if err2 != nil {
+			return nil, errors.Wrapf(err2, "invalid id at line %d", i+2)
+		}
+
+		u := User{
+			ID:         id,
+			Username:   record[1],
+			Attributes: map[string]string{},
+		}
+		for j, name := range header[2:] {
+			v := record[j+2]
+			if v == "" {
+				continue
+			}
+
+			switch name {
+			case emailColumn:
+				u.Email = v
+			case displayNameColumn:
+				u.DisplayName = v
+			case groupsColumn:
+				u.Groups = strings.Split(v, groupsSeparator)
+			case passwordHashColumn:
+				u.PasswordHash = v
+			default:
+				u.Attributes[name] = v
+			}
+		}
+
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+func (users Users) find(username string) (User, bool) {
+	for _, u := range users {
+		if u.Username == username {
+			return u, true
+		}
+	}
+	return User{}, false
+}
+
+func index(users []User) (Users, error) {
+	res := make(Users, len(users))
+	for _, u := range users {
+		if _, ok := res[u.ID]; ok {
+			return nil, errors.Errorf("user %s defined twice", u.ID)
+		}
+		if u.PasswordHash != "" && !htpasswd.IsSupportedHash(u.PasswordHash) {
+			return nil, errors.Errorf("unsupported password hash of user %s, bcrypt and {SHA} supported", u.ID)
+		}
+		res[u.ID] = u
+	}
+	return res, nil
+}
@@ -0,0 +1,176 @@
+package static
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+
+	"guardian/internal/common/infrastructure/filewatch"
+	"guardian/internal/guardian/app/user"
+)
+
+var (
+	aliceID = uuid.Must(uuid.NewV4())
+	bobID   = uuid.Must(uuid.NewV4())
+	carolID = uuid.Must(uuid.NewV4())
+)
+
+// writeUsers writes users file with explicit mod time, so changes within same tick detected
+func writeUsers(t *testing.T, path, data string, modTime time.Time) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUserProviderUser(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.csv")
+	start := time.Now().Add(-time.Hour)
+	writeUsers(t, path, "id,username,email,groups,team\n"+bobID.String()+",bob,bob@example.com,staff;ops,platform\n", start)
+
+	file, err := filewatch.NewFile(path, 0, ParseUsersFile(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider, err := NewUserProvider([]User{{ID: aliceID, Username: "alice"}}, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		id    uuid.UUID
+		check func(t *testing.T, d user.Descriptor)
+		err   error
+	}{
+		{
+			name: "inline",
+			id:   aliceID,
+			check: func(t *testing.T, d user.Descriptor) {
+				if d.Username != "alice" {
+					t.Errorf("unexpected descriptor %+v", d)
+				}
+			},
+		},
+		{
+			name: "from file",
+			id:   bobID,
+			check: func(t *testing.T, d user.Descriptor) {
+				if d.Username != "bob" || d.Email != "bob@example.com" || strings.Join(d.Groups, ",") != "staff,ops" ||
+					d.Attributes["team"] != "platform" {
+					t.Errorf("unexpected descriptor %+v", d)
+				}
+			},
+		},
+		{name: "unknown", id: carolID, err: user.ErrUserNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err2 := provider.User(context.Background(), user.Token{ID: tt.id})
+			if tt.err != nil {
+				if !errors.Is(err2, tt.err) {
+					t.Errorf("got %v, want %v", err2, tt.err)
+				}
+				return
+			}
+			if err2 != nil {
+				t.Fatal(err2)
+			}
+			if d.ID != tt.id {
+				t.Errorf("got ID %s, want %s", d.ID, tt.id)
+			}
+			tt.check(t, d)
+		})
+	}
+
+	// file reloaded lazily on next lookup
+	writeUsers(t, path, "id,username\n"+carolID.String()+",carol\n", start.Add(time.Second))
+	if d, err2 := provider.User(context.Background(), user.Token{ID: carolID}); err2 != nil || d.Username != "carol" {
+		t.Errorf("added user not found: %+v, %v", d, err2)
+	}
+	if _, err2 := provider.User(context.Background(), user.Token{ID: bobID}); !errors.Is(err2, user.ErrUserNotFound) {
+		t.Errorf("removed user found: %v", err2)
+	}
+
+	// broken file keeps previous users
+	writeUsers(t, path, "id,username\nnot-uuid,dave\n", start.Add(2*time.Second))
+	if _, err2 := provider.User(context.Background(), user.Token{ID: carolID}); err2 != nil {
+		t.Errorf("users of previous version lost: %v", err2)
+	}
+}
+
+func TestUserProviderAuthenticate(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "users.json")
+	writeUsers(t, path, `[{"id": "`+bobID.String()+`", "username": "bob", "passwordHash": "`+string(hash)+`"}]`, time.Now())
+	file, err := filewatch.NewFile(path, time.Hour, ParseUsersFile(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider, err := NewUserProvider([]User{
+		{ID: aliceID, Username: "alice", PasswordHash: string(hash)},
+		// user without hash can't log in
+		{ID: carolID, Username: "carol"},
+	}, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		username string
+		password string
+		id       uuid.UUID
+	}{
+		{"alice", "secret", aliceID},
+		{"bob", "secret", bobID},
+		{"alice", "wrong", uuid.Nil},
+		{"carol", "", uuid.Nil},
+		{"dave", "secret", uuid.Nil},
+	} {
+		d, err2 := provider.Authenticate(context.Background(), tt.username, tt.password)
+		if tt.id == uuid.Nil {
+			if !errors.Is(err2, user.ErrInvalidCredentials) {
+				t.Errorf("%s/%s: got %v, want %v", tt.username, tt.password, err2, user.ErrInvalidCredentials)
+			}
+			continue
+		}
+		if err2 != nil || d.ID != tt.id {
+			t.Errorf("%s/%s: got %+v, %v", tt.username, tt.password, d, err2)
+		}
+	}
+}
+
+func TestParseUsersFile(t *testing.T) {
+	for name, tt := range map[string]struct {
+		path string
+		data string
+	}{
+		"unknown format":     {"users.yaml", "- id: x"},
+		"csv header":         {"users.csv", "username,id\nalice," + aliceID.String()},
+		"csv invalid id":     {"users.csv", "id,username\nalice,alice"},
+		"json duplicate":     {"users.json", `[{"id": "` + aliceID.String() + `"}, {"id": "` + aliceID.String() + `"}]`},
+		"unsupported hash":   {"users.json", `[{"id": "` + aliceID.String() + `", "passwordHash": "plain"}]`},
+		"json not array":     {"users.json", `{}`},
+		"csv ragged records": {"users.csv", "id,username\n" + aliceID.String()},
+	} {
+		if _, err := ParseUsersFile(tt.path)([]byte(tt.data)); err == nil {
+			t.Errorf("%s: invalid file parsed", name)
+		}
+	}
+}
@@ -1,28 +1,120 @@
 package tcpproxy
 
 import (
-	"context"
 	stderrors "errors"
+	"net"
+	"sync"
+	"sync/atomic"
 
 	"github.com/inetaf/tcpproxy"
+	"github.com/pkg/errors"
 
 	"guardian/internal/guardian/app/config"
 )
 
-type Proxy struct{}
+func NewProxy() *Proxy {
+	return &Proxy{
+		routes: map[string]*route{},
+	}
+}
+
+// Proxy serves tcp proxies and allows to change them without restart.
+// Each source address served by own listener, so adding or removing proxy does not affect others
+type Proxy struct {
+	mu     sync.Mutex
+	routes map[string]*route
+}
+
+type route struct {
+	proxy  *tcpproxy.Proxy
+	target *target
+}
+
+// Update applies proxies: starts listeners for new source addresses,
+// closes listeners for removed ones and switches destination for existing ones.
+// When listener for new address can't be started no changes applied
+func (proxy *Proxy) Update(proxies []config.TCPProxy) error {
+	proxy.mu.Lock()
+	defer proxy.mu.Unlock()
 
-func (proxy Proxy) Proxy(ctx context.Context, proxies []config.TCPProxy) (err error) {
-	var p tcpproxy.Proxy
+	actual := map[string]config.TCPProxy{}
+	for _, p := range proxies {
+		actual[p.SrcAddress] = p
+	}
+
+	started := map[string]*route{}
+	for src, p := range actual {
+		if _, ok := proxy.routes[src]; ok {
+			continue
+		}
+
+		r := &route{
+			proxy:  &tcpproxy.Proxy{},
+			target: newTarget(p.DstAddress),
+		}
+		r.proxy.AddRoute(src, r.target)
+
+		err := r.proxy.Start()
+		if err != nil {
+			for _, s := range started {
+				_ = s.proxy.Close()
+			}
+			return errors.Wrapf(err, "failed to start tcp proxy on %s", src)
+		}
 
-	for _, tcpProxy := range proxies {
-		p.AddRoute(tcpProxy.SrcAddress, tcpproxy.To(tcpProxy.DstAddress))
+		started[src] = r
 	}
 
-	go func() {
-		err = p.Run()
-	}()
+	var err error
+	for src, r := range proxy.routes {
+		p, ok := actual[src]
+		if !ok {
+			err = stderrors.Join(err, r.proxy.Close())
+			delete(proxy.routes, src)
+			continue
+		}
 
-	<-ctx.Done()
+		r.target.set(p.DstAddress)
+	}
+
+	for src, r := range started {
+		proxy.routes[src] = r
+	}
+
+	return err
+}
+
+func (proxy *Proxy) Close() error {
+	proxy.mu.Lock()
+	defer proxy.mu.Unlock()
+
+	var err error
+	for src, r := range proxy.routes {
+		err = stderrors.Join(err, r.proxy.Close())
+		delete(proxy.routes, src)
+	}
+	return err
+}
+
+func newTarget(dst string) *target {
+	t := &target{}
+	t.set(dst)
+	return t
+}
+
+// target passes connections to destination which can be changed at runtime.
+// Already established connections stay with previous destination
+type target struct {
+	dial atomic.Pointer[tcpproxy.DialProxy]
+}
+
+func (t *target) set(dst string) {
+	if cur := t.dial.Load(); cur != nil && cur.Addr == dst {
+		return
+	}
+	t.dial.Store(tcpproxy.To(dst))
+}
 
-	return stderrors.Join(err, p.Close())
+func (t *target) HandleConn(conn net.Conn) {
+	t.dial.Load().HandleConn(conn)
 }
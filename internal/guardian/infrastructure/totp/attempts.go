@@ -0,0 +1,58 @@
+package totp
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+)
+
+// NewAttempts returns empty attempts
+func NewAttempts() *Attempts {
+	return &Attempts{
+		failures: map[uuid.UUID]failures{},
+		used:     map[uuid.UUID]uint64{},
+	}
+}
+
+// Attempts tracks codes checked for users, they're kept between config reloads,
+// so lockout and replay protection survive reload
+type Attempts struct {
+	mu       sync.Mutex
+	failures map[uuid.UUID]failures
+	// used holds last accepted counter of user, so code can't be replayed
+	used map[uuid.UUID]uint64
+}
+
+type failures struct {
+	count int
+	since time.Time
+}
+
+// Verify validates code unless user locked out by failures, accepted code can't be used again
+func (a *Attempts) Verify(userID uuid.UUID, key []byte, code string, now time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	fails := a.failures[userID]
+	if now.Sub(fails.since) >= LockoutDuration {
+		fails = failures{}
+	}
+	if fails.count >= MaxFailures {
+		return false
+	}
+
+	counter, ok := Validate(key, code, now)
+	if !ok || counter <= a.used[userID] {
+		if fails.count == 0 {
+			fails.since = now
+		}
+		fails.count++
+		a.failures[userID] = fails
+		return false
+	}
+
+	delete(a.failures, userID)
+	a.used[userID] = counter
+	return true
+}
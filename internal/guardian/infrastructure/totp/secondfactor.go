@@ -0,0 +1,280 @@
+package totp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"guardian/internal/common/infrastructure/filewatch"
+	"guardian/internal/guardian/app/proxy/downstream"
+	"guardian/internal/guardian/app/session"
+	"guardian/internal/guardian/app/user"
+)
+
+const (
+	DefaultPath   = "/mfa"
+	DefaultCookie = "guardian_mfa"
+	DefaultWindow = 12 * time.Hour
+
+	// MaxFailures of user locks challenge for LockoutDuration
+	MaxFailures     = 5
+	LockoutDuration = 5 * time.Minute
+
+	csrfSuffix  = "_csrf"
+	csrfTTL     = time.Hour
+	maxFormSize = 16 << 10
+)
+
+//go:embed challenge.html
+var defaultTemplate string
+
+// DefaultTemplate renders built-in challenge page
+var DefaultTemplate = template.Must(template.New("challenge").Parse(defaultTemplate))
+
+// ParseTemplate parses challenge page template, it's executed with TemplateData
+func ParseTemplate(data []byte) (*template.Template, error) {
+	return template.New("challenge").Parse(string(data))
+}
+
+// TemplateData passed to challenge page template
+type TemplateData struct {
+	// Action is path form posted to
+	Action    string
+	ReturnTo  string
+	Username  string
+	Error     string
+	CSRFToken string
+}
+
+type Config struct {
+	// Path serves challenge page on GET and checks code on POST
+	Path string
+	// ReturnToParam of query with URL user redirected to after check
+	ReturnToParam string
+	// Window is how long passed check is valid
+	Window time.Duration
+
+	// Cookie holds session recording passed check
+	Cookie string
+	Codec  session.Codec
+
+	// Secrets of enrolled users, reloaded on change
+	Secrets *filewatch.File[Secrets]
+	// Template overrides DefaultTemplate, reloaded on change
+	Template *filewatch.File[*template.Template]
+	// Attempts shared with second factor of previous config, new ones created when nil
+	Attempts *Attempts
+}
+
+// NewSecondFactor returns second factor checking TOTP codes of enrolled users
+func NewSecondFactor(config Config) downstream.SecondFactor {
+	if config.Path == "" {
+		config.Path = DefaultPath
+	}
+	if config.ReturnToParam == "" {
+		config.ReturnToParam = downstream.DefaultReturnToParam
+	}
+	if config.Window <= 0 {
+		config.Window = DefaultWindow
+	}
+	if config.Cookie == "" {
+		config.Cookie = DefaultCookie
+	}
+	if config.Attempts == nil {
+		config.Attempts = NewAttempts()
+	}
+
+	return &secondFactor{config: config}
+}
+
+type secondFactor struct {
+	config Config
+}
+
+// Verify accepts pass issued to user within session of first factor, so new login requires new check
+func (f *secondFactor) Verify(r http.Request, descriptor user.Descriptor, sessionID string) error {
+	c, err := r.Cookie(f.config.Cookie)
+	if err == nil && c.Value != "" && sessionID != "" {
+		s, err2 := f.config.Codec.Decode(c.Value)
+		if err2 == nil && s.User.ID == descriptor.ID &&
+			subtle.ConstantTimeCompare([]byte(s.Binding), []byte(sessionID)) == 1 {
+			return nil
+		}
+	}
+
+	err = errors.Wrapf(downstream.ErrSecondFactorRequired, "user %s", descriptor.ID)
+
+	challenge := downstream.WithReturnTo(&url.URL{Path: f.config.Path}, f.config.ReturnToParam, r)
+	if downstream.AcceptsHTML(r) {
+		return &downstream.ErrRedirect{URL: challenge}
+	}
+
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	encoder.SetEscapeHTML(false)
+	if encodeErr := encoder.Encode(map[string]string{
+		"error":        "second_factor_required",
+		"challengeURL": challenge,
+	}); encodeErr != nil {
+		return err
+	}
+
+	return &downstream.ErrResponse{
+		StatusCode: http.StatusUnauthorized,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       body.Bytes(),
+		Err:        err,
+	}
+}
+
+func (f *secondFactor) Challenges(r *http.Request) bool {
+	return r.URL.Path == f.config.Path
+}
+
+func (f *secondFactor) ServeChallenge(w http.ResponseWriter, r *http.Request, descriptor user.Descriptor, sessionID string) error {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		f.page(w, r, TemplateData{
+			ReturnTo: r.URL.Query().Get(f.config.ReturnToParam),
+			Username: descriptor.Username,
+		}, http.StatusOK)
+		return nil
+	case http.MethodPost:
+		return f.check(w, r, descriptor, sessionID)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return nil
+	}
+}
+
+// check verifies posted code and records passed check in session
+func (f *secondFactor) check(w http.ResponseWriter, r *http.Request, descriptor user.Descriptor, sessionID string) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormSize)
+	err := r.ParseForm()
+	if err != nil {
+		return errors.Wrapf(downstream.ErrAuthDataInvalid, "challenge form: %s", err)
+	}
+
+	data := TemplateData{
+		ReturnTo: r.PostForm.Get("return_to"),
+		Username: descriptor.Username,
+	}
+
+	c, err := r.Cookie(f.config.Cookie + csrfSuffix)
+	if err != nil || c.Value == "" ||
+		subtle.ConstantTimeCompare([]byte(c.Value), []byte(r.PostForm.Get("csrf_token"))) != 1 {
+		data.Error = "Your session expired, please try again."
+		f.page(w, r, data, http.StatusForbidden)
+		return nil
+	}
+
+	if sessionID == "" {
+		data.Error = "Two-factor authentication requires login session, sign in again."
+		f.page(w, r, data, http.StatusForbidden)
+		return nil
+	}
+
+	enrollment, ok := f.config.Secrets.Get()[descriptor.ID]
+	if !ok {
+		data.Error = "Two-factor authentication isn't enrolled, contact administrator."
+		f.page(w, r, data, http.StatusForbidden)
+		return nil
+	}
+
+	now := time.Now()
+	if !f.config.Attempts.Verify(descriptor.ID, enrollment.key, r.PostForm.Get("code"), now) {
+		data.Error = "Invalid code or too many attempts, try again later."
+		f.page(w, r, data, http.StatusUnauthorized)
+		return nil
+	}
+
+	value, err := f.config.Codec.Encode(session.Session{
+		Binding:   sessionID,
+		User:      user.Descriptor{ID: descriptor.ID},
+		IssuedAt:  now,
+		ExpiresAt: now.Add(f.config.Window),
+	})
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, f.cookie(r, f.config.Cookie, value, now.Add(f.config.Window)))
+	http.SetCookie(w, f.expiredCookie(r, f.config.Cookie+csrfSuffix))
+	http.Redirect(w, r, downstream.ReturnTo(r, data.ReturnTo), http.StatusSeeOther)
+	return nil
+}
+
+// page renders challenge form with new csrf token
+func (f *secondFactor) page(w http.ResponseWriter, r *http.Request, data TemplateData, status int) {
+	data.Action = f.config.Path
+	data.CSRFToken = randomString()
+
+	t := DefaultTemplate
+	if f.config.Template != nil {
+		t = f.config.Template.Get()
+	}
+
+	var buf bytes.Buffer
+	err := t.Execute(&buf, data)
+	if err != nil {
+		http.Error(w, "failed to render challenge page", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, f.cookie(r, f.config.Cookie+csrfSuffix, data.CSRFToken, time.Now().Add(csrfTTL)))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(status)
+	_, _ = w.Write(buf.Bytes())
+}
+
+func (f *secondFactor) cookie(r *http.Request, name, value string, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		Secure:   r.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+func (f *secondFactor) expiredCookie(r *http.Request, name string) *http.Cookie {
+	c := f.cookie(r, name, "", time.Unix(0, 0))
+	c.MaxAge = -1
+	return c
+}
+
+func (f *secondFactor) MarshalJSON() ([]byte, error) {
+	res := map[string]any{
+		"type":          "totp",
+		"path":          f.config.Path,
+		"returnToParam": f.config.ReturnToParam,
+		"window":        f.config.Window.String(),
+		"cookie":        f.config.Cookie,
+		"session":       f.config.Codec,
+		"secrets":       f.config.Secrets.Path(),
+	}
+	if f.config.Template != nil {
+		res["template"] = f.config.Template.Path()
+	}
+	return json.Marshal(res)
+}
+
+func randomString() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,181 @@
+package totp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/pkg/errors"
+
+	"guardian/internal/common/infrastructure/filewatch"
+	"guardian/internal/guardian/app/proxy/downstream"
+	"guardian/internal/guardian/app/user"
+	"guardian/internal/guardian/infrastructure/sessioncookie"
+)
+
+const primarySession = "primary-session"
+
+func TestSecondFactorFlow(t *testing.T) {
+	f, alice, key := newTestSecondFactor(t, NewAttempts())
+
+	// not passed yet
+	err := f.Verify(request("GET", "/admin?x=1", "text/html"), alice, primarySession)
+	var redirect *downstream.ErrRedirect
+	if !errors.As(err, &redirect) || !strings.HasPrefix(redirect.URL, "/mfa?return_to=") {
+		t.Fatalf("expected redirect to challenge, got %v", err)
+	}
+	err = f.Verify(request("GET", "/api", "application/json"), alice, primarySession)
+	var response *downstream.ErrResponse
+	if !errors.As(err, &response) || response.StatusCode != http.StatusUnauthorized ||
+		!errors.Is(err, downstream.ErrSecondFactorRequired) {
+		t.Fatalf("expected 401 response, got %v", err)
+	}
+
+	// challenge page issues csrf token
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/mfa?return_to=/admin", nil)
+	if err = f.ServeChallenge(w, r, alice, primarySession); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("challenge page: %d, %v", w.Code, err)
+	}
+	csrf := cookie(w, DefaultCookie+csrfSuffix)
+	if csrf == nil {
+		t.Fatal("csrf cookie not set")
+	}
+
+	tests := []struct {
+		name      string
+		csrf      string
+		code      string
+		sessionID string
+		status    int
+	}{
+		{"bad csrf", "wrong", Code(key, Counter(time.Now())), primarySession, http.StatusForbidden},
+		{"without session", csrf.Value, Code(key, Counter(time.Now())), "", http.StatusForbidden},
+		{"wrong code", csrf.Value, "000000", primarySession, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postCode(t, f, alice, tt.sessionID, csrf, tt.csrf, tt.code)
+			if w.Code != tt.status {
+				t.Errorf("got %d, want %d", w.Code, tt.status)
+			}
+			if cookie(w, DefaultCookie) != nil {
+				t.Error("pass issued")
+			}
+		})
+	}
+
+	w = postCode(t, f, alice, primarySession, csrf, csrf.Value, Code(key, Counter(time.Now())))
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/admin" {
+		t.Fatalf("expected redirect back, got %d %s", w.Code, w.Header().Get("Location"))
+	}
+	pass := cookie(w, DefaultCookie)
+	if pass == nil {
+		t.Fatal("pass not issued")
+	}
+
+	withPass := func(r http.Request) http.Request {
+		r.AddCookie(pass)
+		return r
+	}
+	if err = f.Verify(withPass(request("GET", "/admin", "text/html")), alice, primarySession); err != nil {
+		t.Errorf("pass rejected: %v", err)
+	}
+
+	// pass of logged out session isn't accepted by new one
+	err = f.Verify(withPass(request("GET", "/admin", "text/html")), alice, "new-session")
+	if !errors.Is(err, downstream.ErrSecondFactorRequired) && !errors.As(err, &redirect) {
+		t.Errorf("pass accepted by other session: %v", err)
+	}
+	err = f.Verify(withPass(request("GET", "/admin", "text/html")), alice, "")
+	if err == nil {
+		t.Error("pass accepted without session")
+	}
+	bob := user.Descriptor{ID: uuid.Must(uuid.NewV4()), Username: "bob"}
+	if err = f.Verify(withPass(request("GET", "/admin", "text/html")), bob, primarySession); err == nil {
+		t.Error("pass accepted for other user")
+	}
+}
+
+func TestSecondFactorAttemptsSurviveReload(t *testing.T) {
+	attempts := NewAttempts()
+	before, alice, key := newTestSecondFactor(t, attempts)
+	code := Code(key, Counter(time.Now()))
+
+	csrf := &http.Cookie{Name: DefaultCookie + csrfSuffix, Value: "token"}
+	if w := postCode(t, before, alice, primarySession, csrf, csrf.Value, code); w.Code != http.StatusSeeOther {
+		t.Fatalf("code rejected: %d", w.Code)
+	}
+
+	after, _, _ := newTestSecondFactor(t, attempts)
+	after.(*secondFactor).config.Secrets = before.(*secondFactor).config.Secrets
+	if w := postCode(t, after, alice, primarySession, csrf, csrf.Value, code); w.Code != http.StatusUnauthorized {
+		t.Errorf("code replayed after reload: %d", w.Code)
+	}
+}
+
+func newTestSecondFactor(t *testing.T, attempts *Attempts) (downstream.SecondFactor, user.Descriptor, []byte) {
+	t.Helper()
+
+	alice := user.Descriptor{ID: uuid.Must(uuid.NewV4()), Username: "alice"}
+	path := filepath.Join(t.TempDir(), "totp.json")
+	e, err := Enroll(path, alice.ID, alice.Username, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := DecodeSecret(e.Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secrets, err := filewatch.NewFile(path, filewatch.DefaultInterval, ParseSecrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	codec, err := sessioncookie.NewCodec([]sessioncookie.Key{{ID: "k1", Secret: []byte("0123456789abcdef0123456789abcdef")}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewSecondFactor(Config{
+		Codec:    codec,
+		Secrets:  secrets,
+		Attempts: attempts,
+	}), alice, key
+}
+
+func request(method, target, accept string) http.Request {
+	r := httptest.NewRequest(method, target, nil)
+	r.Header.Set("Accept", accept)
+	return *r
+}
+
+func postCode(t *testing.T, f downstream.SecondFactor, descriptor user.Descriptor, sessionID string, csrf *http.Cookie, token, code string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	form := url.Values{"csrf_token": {token}, "code": {code}, "return_to": {"/admin"}}
+	r := httptest.NewRequest("POST", "/mfa", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.AddCookie(csrf)
+
+	w := httptest.NewRecorder()
+	err := f.ServeChallenge(w, r, descriptor, sessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func cookie(w *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == name && c.Value != "" {
+			return c
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package totp
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/pkg/errors"
+)
+
+// Enrollment is secret of user
+type Enrollment struct {
+	// Secret is base32 key shared with authenticator app
+	Secret string `json:"secret"`
+	// Username is informational, user identified by ID
+	Username   string    `json:"username,omitempty"`
+	EnrolledAt time.Time `json:"enrolledAt"`
+
+	key []byte
+}
+
+// Secrets holds enrollments by user ID, stored as JSON object in file
+type Secrets map[uuid.UUID]Enrollment
+
+// ParseSecrets parses and validates JSON object of enrollments
+func ParseSecrets(data []byte) (Secrets, error) {
+	secrets := Secrets{}
+	if len(data) == 0 {
+		return secrets, nil
+	}
+
+	err := json.Unmarshal(data, &secrets)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	for id, e := range secrets {
+		e.key, err = DecodeSecret(e.Secret)
+		if err != nil {
+			return nil, errors.Wrapf(err, "user %s", id)
+		}
+		secrets[id] = e
+	}
+	return secrets, nil
+}
+
+// Enroll generates secret of user and writes it to file, existing enrollment replaced only when replace set
+func Enroll(path string, userID uuid.UUID, username string, replace bool) (Enrollment, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return Enrollment{}, errors.WithStack(err)
+	}
+
+	secrets, err := ParseSecrets(data)
+	if err != nil {
+		return Enrollment{}, errors.Wrapf(err, "failed to parse %s", path)
+	}
+	if _, ok := secrets[userID]; ok && !replace {
+		return Enrollment{}, errors.Errorf("user %s already enrolled", userID)
+	}
+
+	secret, err := GenerateSecret()
+	if err != nil {
+		return Enrollment{}, err
+	}
+	e := Enrollment{
+		Secret:     secret,
+		Username:   username,
+		EnrolledAt: time.Now().UTC().Truncate(time.Second),
+	}
+	secrets[userID] = e
+
+	data, err = json.MarshalIndent(secrets, "", "    ")
+	if err != nil {
+		return Enrollment{}, errors.WithStack(err)
+	}
+
+	// file replaced atomically, so proxy watching it never reads partial file
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return Enrollment{}, errors.WithStack(err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	_, err = tmp.Write(append(data, '\n'))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o600)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		return Enrollment{}, errors.Wrapf(err, "failed to write %s", path)
+	}
+	return e, nil
+}
@@ -0,0 +1,100 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec // RFC 6238 default supported by every authenticator app
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// Period of code
+	Period = 30 * time.Second
+	// Digits of code
+	Digits = 6
+	// Skew is number of periods before and after current one codes accepted from, tolerates clock drift
+	Skew = 1
+
+	secretSize = 20
+	// modulo is 10^Digits
+	modulo = 1_000_000
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns random base32 secret
+func GenerateSecret() (string, error) {
+	b := make([]byte, secretSize)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return encoding.EncodeToString(b), nil
+}
+
+// DecodeSecret decodes base32 secret, spaces and padding ignored
+func DecodeSecret(secret string) ([]byte, error) {
+	s := strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	key, err := encoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return nil, errors.Wrap(err, "secret must be base32")
+	}
+	if len(key) == 0 {
+		return nil, errors.New("empty secret")
+	}
+	return key, nil
+}
+
+// Code returns code of counter as in RFC 4226
+func Code(key []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", Digits, value%modulo)
+}
+
+// Counter returns number of period at t
+func Counter(t time.Time) uint64 {
+	return uint64(t.Unix() / int64(Period/time.Second))
+}
+
+// Validate finds counter of code within Skew periods around t
+func Validate(key []byte, code string, t time.Time) (uint64, bool) {
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != Digits {
+		return 0, false
+	}
+
+	current := Counter(t)
+	for i := -Skew; i <= Skew; i++ {
+		counter := current + uint64(i)
+		if subtle.ConstantTimeCompare([]byte(Code(key, counter)), []byte(code)) == 1 {
+			return counter, true
+		}
+	}
+	return 0, false
+}
+
+// URI returns otpauth URI of secret, authenticator apps enroll it from QR code
+func URI(issuer, account, secret string) string {
+	label := url.PathEscape(issuer + ":" + account)
+	query := url.Values{
+		"secret": {secret},
+		"issuer": {issuer},
+	}
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
@@ -0,0 +1,132 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+)
+
+// rfcKey is SHA-1 key of RFC 6238 test vectors
+var rfcKey = []byte("12345678901234567890")
+
+func TestCode(t *testing.T) {
+	// RFC 6238 appendix B, last 6 of 8 digits
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		if code := Code(rfcKey, Counter(time.Unix(tt.unix, 0))); code != tt.code {
+			t.Errorf("time %d: got %s, want %s", tt.unix, code, tt.code)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	counter := Counter(now)
+
+	tests := []struct {
+		name string
+		code string
+		ok   bool
+	}{
+		{"current", Code(rfcKey, counter), true},
+		{"with spaces", "005 924", true},
+		{"previous period", Code(rfcKey, counter-1), true},
+		{"next period", Code(rfcKey, counter+1), true},
+		{"outside skew", Code(rfcKey, counter-2), false},
+		{"short", "00592", false},
+		{"empty", "", false},
+		{"wrong", "000000", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := Validate(rfcKey, tt.code, now); ok != tt.ok {
+				t.Errorf("got %v, want %v", ok, tt.ok)
+			}
+		})
+	}
+}
+
+func TestSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := DecodeSecret(strings.ToLower(secret[:8]) + " " + secret[8:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(key) != secretSize {
+		t.Errorf("got key of %d bytes", len(key))
+	}
+
+	padded := base32.StdEncoding.EncodeToString(rfcKey)
+	if _, err = DecodeSecret(padded); err != nil {
+		t.Errorf("padded secret: %v", err)
+	}
+	if _, err = DecodeSecret("not base32!"); err == nil {
+		t.Error("invalid secret accepted")
+	}
+	if _, err = DecodeSecret(""); err == nil {
+		t.Error("empty secret accepted")
+	}
+}
+
+func TestAttempts(t *testing.T) {
+	userID := uuid.Must(uuid.NewV4())
+	now := time.Unix(1234567890, 0)
+	code := Code(rfcKey, Counter(now))
+
+	a := NewAttempts()
+	if !a.Verify(userID, rfcKey, code, now) {
+		t.Fatal("valid code rejected")
+	}
+	if a.Verify(userID, rfcKey, code, now) {
+		t.Error("replayed code accepted")
+	}
+	if a.Verify(userID, rfcKey, Code(rfcKey, Counter(now)-1), now) {
+		t.Error("code older than accepted one accepted")
+	}
+
+	next := now.Add(Period)
+	for i := 0; i < MaxFailures; i++ {
+		a.Verify(userID, rfcKey, "000000", next)
+	}
+	if a.Verify(userID, rfcKey, Code(rfcKey, Counter(next)), next) {
+		t.Error("valid code accepted while locked out")
+	}
+
+	later := next.Add(LockoutDuration)
+	if !a.Verify(userID, rfcKey, Code(rfcKey, Counter(later)), later) {
+		t.Error("valid code rejected after lockout")
+	}
+}
+
+func TestParseSecrets(t *testing.T) {
+	secrets, err := ParseSecrets(nil)
+	if err != nil || len(secrets) != 0 {
+		t.Fatalf("empty file: %v, %v", secrets, err)
+	}
+
+	_, err = ParseSecrets([]byte(`{"6f1c0c2e-3a52-4c4b-9d0e-6f0f4b0e2a11": {"secret": "!!"}}`))
+	if err == nil {
+		t.Error("invalid secret accepted")
+	}
+	_, err = ParseSecrets([]byte(`{"not-uuid": {"secret": "GEZDGNBV"}}`))
+	if err == nil {
+		t.Error("invalid user id accepted")
+	}
+}